@@ -11,6 +11,9 @@ type Config struct {
 	Redis    RedisConfig
 	Logger   Logger
 	Metrics  Metrics
+	Storage  StorageConfig
+	Media    MediaConfig
+	Chat     ChatConfig
 }
 
 // Server config struct
@@ -36,6 +39,45 @@ type Metrics struct {
 	ServiceName string `env:"METRICS_SERVICE_NAME"`
 }
 
+// Storage config
+type StorageConfig struct {
+	Driver      string `env:"STORAGE_DRIVER"`
+	LocalDir    string `env:"STORAGE_LOCAL_DIR"`
+	S3Endpoint  string `env:"STORAGE_S3_ENDPOINT"`
+	S3Region    string `env:"STORAGE_S3_REGION"`
+	S3Bucket    string `env:"STORAGE_S3_BUCKET"`
+	S3AccessKey string `env:"STORAGE_S3_ACCESS_KEY"`
+	S3SecretKey string `env:"STORAGE_S3_SECRET_KEY"`
+	S3UseSSL    bool   `env:"STORAGE_S3_USE_SSL"`
+	SigningKey  string `env:"STORAGE_SIGNING_KEY"`
+	URLExpiry   int    `env:"STORAGE_URL_EXPIRY"`
+}
+
+// Media config
+type MediaConfig struct {
+	VoicemailMaxSizeMB     int      `env:"VOICEMAIL_MAX_SIZE_MB"`
+	VoicemailMaxDuration   int      `env:"VOICEMAIL_MAX_DURATION"`
+	AttachmentMaxSizeMB    int      `env:"ATTACHMENT_MAX_SIZE_MB"`
+	AttachmentAllowedTypes []string `env:"ATTACHMENT_ALLOWED_TYPES" envSeparator:","`
+	ThumbnailSizes         []int    `env:"MEDIA_THUMBNAIL_SIZES" envSeparator:","`
+	Workers                int      `env:"MEDIA_WORKERS"`
+	FFmpegPath             string   `env:"FFMPEG_PATH"`
+	FFprobePath            string   `env:"FFPROBE_PATH"`
+	VoiceMaxSizeMB         int      `env:"VOICE_MAX_SIZE_MB"`
+	VoiceMaxDuration       int      `env:"VOICE_MAX_DURATION"`
+}
+
+// Chat config
+type ChatConfig struct {
+	WriterWorkers     int `env:"CHAT_WRITER_WORKERS"`
+	WriterBatchSize   int `env:"CHAT_WRITER_BATCH_SIZE"`
+	WriterMaxAttempts int `env:"CHAT_WRITER_MAX_ATTEMPTS"`
+	// DefaultRetentionHours applies to conversations without a retention policy; 0 keeps messages forever
+	DefaultRetentionHours int `env:"CHAT_DEFAULT_RETENTION_HOURS"`
+	PurgeBatchSize        int `env:"CHAT_PURGE_BATCH_SIZE"`
+	MaxPinnedMessages     int `env:"CHAT_MAX_PINNED_MESSAGES"`
+}
+
 // Logger config
 type Logger struct {
 	Development       bool   `env:"LOGGER_DEVELOPMENT"`
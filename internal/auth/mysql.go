@@ -13,6 +13,7 @@ type Repository interface {
 	Login(ctx context.Context, user *models.User) (*models.User, error)
 	GetUserByID(ctx context.Context, userId string) (*models.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
 	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
 	GetRefreshTokenByToken(ctx context.Context, token string) (*models.RefreshToken, error)
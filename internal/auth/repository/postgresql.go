@@ -68,6 +68,18 @@ func (r *repo) GetUserByUsername(ctx context.Context, username string) (*models.
 	return &user, nil
 }
 
+// GetUsersByUsernames implements auth.Repository.
+func (r *repo) GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error) {
+	var users []*models.User
+	if len(usernames) == 0 {
+		return users, nil
+	}
+	if err := r.db.WithContext(ctx).Where("username IN ?", usernames).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 // GetUserByEmail implements auth.Repository.
 func (r *repo) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
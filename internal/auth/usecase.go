@@ -14,6 +14,8 @@ type UseCase interface {
 	Login(ctx context.Context, user *models.User) (*models.User, error)
 	GetUserByID(ctx context.Context, userId string) (*models.User, error)
 	GetUsers(ctx context.Context) ([]*models.User, error)
+	// GetUsersByUsernames retrieves the users with the given usernames; unknown usernames are skipped
+	GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error)
 
 	// Refresh token methods
 	GenerateRefreshToken(ctx context.Context, userID string) (string, time.Time, error)
@@ -140,3 +140,8 @@ func (u *usecase) Register(ctx context.Context, user *models.User) (*models.User
 func (u *usecase) GetUsers(ctx context.Context) ([]*models.User, error) {
 	return u.repo.GetUsers(ctx)
 }
+
+// GetUsersByUsernames implements auth.UseCase.
+func (u *usecase) GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error) {
+	return u.repo.GetUsersByUsernames(ctx, usernames)
+}
@@ -0,0 +1,25 @@
+package chat
+
+import (
+	"io"
+
+	"video-call/internal/models"
+)
+
+// AttachmentUpload is a file uploaded to a conversation.
+type AttachmentUpload struct {
+	Reader   io.Reader
+	Size     int64 // as announced by the client; the limit is enforced on the bytes read
+	FileName string
+}
+
+// AttachmentDownload gives access to the content of an attachment or one of its variants.
+// Either Body is set and must be closed by the caller, or the content is served from RedirectURL.
+type AttachmentDownload struct {
+	Attachment  *models.Attachment
+	FileName    string
+	ContentType string
+	Size        int64 // -1 when unknown
+	Body        io.ReadCloser
+	RedirectURL string
+}
@@ -1,6 +1,26 @@
 //go:generate mockgen -source cache.go -destination mock/redis_repository_mock.go -package mock
 package chat
 
+import (
+	"context"
+	"time"
+
+	"video-call/internal/models"
+)
+
 // RedisRepository defines the interface for chat-related cache operations.
 type RedisRepository interface {
+	// SetConnectionPresence records the state of one WebSocket connection of a user
+	// Connections that stop refreshing their state expire after ttl
+	SetConnectionPresence(ctx context.Context, userID, connectionID string, status models.PresenceStatus, ttl time.Duration) error
+
+	// RemoveConnectionPresence forgets a closed connection and stores the user's last seen time
+	RemoveConnectionPresence(ctx context.Context, userID, connectionID string, lastSeen time.Time) error
+
+	// GetPresence aggregates the presence of a user over their live connections on every node
+	GetPresence(ctx context.Context, userID string) (*models.UserPresence, error)
+
+	// ClaimExpiredPresences returns up to limit users whose connections all stopped refreshing
+	// their state before at, e.g. because their node went away. Each user is handed to one caller only
+	ClaimExpiredPresences(ctx context.Context, at time.Time, limit int) ([]string, error)
 }
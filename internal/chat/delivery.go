@@ -7,11 +7,44 @@ import (
 // Auth HTTP Handlers interface
 type Handlers interface {
 	CreateConversation(c *gin.Context)
+	GetOrCreateDirectConversation(c *gin.Context)
 	GetConversation(c *gin.Context)
 	GetConversations(c *gin.Context)
 	UpdateConversation(c *gin.Context)
 	DeleteConversation(c *gin.Context)
+	MarkConversationRead(c *gin.Context)
+	UpdateConversationSettings(c *gin.Context)
+	UpdateRetention(c *gin.Context)
+
+	GetMembers(c *gin.Context)
+	AddMembers(c *gin.Context)
+	RemoveMember(c *gin.Context)
+	LeaveConversation(c *gin.Context)
+	UpdateMemberRole(c *gin.Context)
+	TransferOwnership(c *gin.Context)
 
 	GetMessages(c *gin.Context)
+	SyncMessages(c *gin.Context)
 	SendMessage(c *gin.Context)
+	GetThread(c *gin.Context)
+	EditMessage(c *gin.Context)
+	DeleteMessage(c *gin.Context)
+	GetMessageRevisions(c *gin.Context)
+	GetMessageStatuses(c *gin.Context)
+	AddReaction(c *gin.Context)
+	RemoveReaction(c *gin.Context)
+	PinMessage(c *gin.Context)
+	UnpinMessage(c *gin.Context)
+	GetPinnedMessages(c *gin.Context)
+	ForwardMessage(c *gin.Context)
+
+	SearchMessages(c *gin.Context)
+	GetMentions(c *gin.Context)
+
+	UploadAttachment(c *gin.Context)
+	SendVoiceMessage(c *gin.Context)
+	GetAttachment(c *gin.Context)
+	DownloadAttachment(c *gin.Context)
+
+	GetPresence(c *gin.Context)
 }
@@ -1,26 +1,37 @@
 package http
 
 import (
+	"context"
 	"errors"
+	"mime"
 	"net/http"
+	"strings"
+	"time"
 
+	"video-call/config"
 	"video-call/internal/chat"
 	"video-call/internal/models"
 	"video-call/pkg/logger"
 	"video-call/pkg/response"
 	"video-call/pkg/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// multipartOverhead leaves room for multipart headers and form fields on top of the file itself.
+const multipartOverhead = 1 << 20
+
 // Handler handles HTTP requests for chat features
 type Handler struct {
+	cfg    *config.Config
 	chatUC chat.UseCase
 	logger logger.Logger
 }
 
 // NewHandler creates a new chat HTTP handler
-func NewHandler(chatUC chat.UseCase, logger logger.Logger) *Handler {
+func NewHandler(cfg *config.Config, chatUC chat.UseCase, logger logger.Logger) *Handler {
 	return &Handler{
+		cfg:    cfg,
 		chatUC: chatUC,
 		logger: logger,
 	}
@@ -74,37 +85,54 @@ func (h *Handler) CreateConversation(c *gin.Context) {
 		return
 	}
 
-	// MemberIDs are already strings in the request
+	// A direct conversation is unique per pair of users
+	if !req.IsGroup {
+		if len(req.MemberIDs) != 1 {
+			response.WithMappedError(c, chat.ErrInvalidMembers, chat.MapError)
+			return
+		}
+		conversation, err := h.chatUC.GetOrCreateDirectConversation(c.Request.Context(), userID, req.MemberIDs[0])
+		if err != nil {
+			h.logger.Errorf(c.Request.Context(), "Failed to create direct conversation: %v", err)
+			response.WithMappedError(c, err, chat.MapError)
+			return
+		}
+		response.WithCode(c, http.StatusCreated, toConversationResponse(conversation))
+		return
+	}
 
-	// Create conversation
+	// The creator owns the group
 	name := req.Name // Create a copy to take address of
 	conversation := &models.Conversation{
-		IsGroup: req.IsGroup,
-		Name:    &name,
+		IsGroup:   true,
+		Name:      &name,
+		CreatedBy: &userID,
+	}
+	if err := h.chatUC.CreateConversation(c.Request.Context(), conversation, req.MemberIDs); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to create conversation: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
 	}
 
-	// Set the creator as the first participant
-	conversation.CreatedBy = userID
+	response.WithCode(c, http.StatusCreated, toConversationResponse(conversation))
+}
 
-	// Create conversation
-	if err := h.chatUC.CreateConversation(c.Request.Context(), conversation); err != nil {
-		h.logger.Errorf(c.Request.Context(), "Failed to create conversation: %v", err)
+// GetOrCreateDirectConversation returns the direct conversation with another user, creating it if needed
+func (h *Handler) GetOrCreateDirectConversation(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
 		response.WithError(c, err)
 		return
 	}
 
-	// Add members to conversation
-	if len(req.MemberIDs) > 0 {
-		h.logger.Warnf(c.Request.Context(), "Adding participants is not yet implemented")
-		// TODO: Implement AddParticipants in use case and repository
-		/*
-		if err := h.chatUC.AddParticipants(c.Request.Context(), conversation.ID, req.MemberIDs); err != nil {
-			h.logger.Errorf(c.Request.Context(), "Failed to add participants: %v", err)
-		}
-		*/
+	conversation, err := h.chatUC.GetOrCreateDirectConversation(c.Request.Context(), userID, c.Param("userId"))
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to get direct conversation: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
 	}
 
-	response.WithCode(c, http.StatusCreated, toConversationResponse(conversation))
+	response.WithData(c, http.StatusOK, toConversationResponse(conversation))
 }
 
 // GetConversations gets all conversations for the current user
@@ -115,21 +143,128 @@ func (h *Handler) GetConversations(c *gin.Context) {
 		return
 	}
 
-	// Get conversations for the user
-	conversations, err := h.chatUC.GetConversationsByUserID(c.Request.Context(), userID)
+	var req GetConversationsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to bind query parameters: %v", err)
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	// Get conversations for the user with their unread count and last message
+	summaries, err := h.chatUC.GetConversationSummaries(c.Request.Context(), userID, chat.ConversationFilter{Archived: req.Archived})
 	if err != nil {
 		h.logger.Errorf(c.Request.Context(), "Failed to get user conversations: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toConversationSummaryResponses(summaries))
+}
+
+// UpdateConversationSettings mutes, pins or archives a conversation for the current user
+func (h *Handler) UpdateConversationSettings(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	var req UpdateConversationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to bind request body: %v", err)
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	participant, err := h.chatUC.UpdateConversationSettings(c.Request.Context(), conversationID, userID, &chat.SettingsUpdate{
+		Muted:      req.Muted,
+		MutedUntil: req.MutedUntil,
+		Pinned:     req.Pinned,
+		Archived:   req.Archived,
+	})
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to update conversation settings: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toConversationSettingsResponse(participant.IsMuted(time.Now()), participant.MutedUntil, participant.PinnedAt, participant.ArchivedAt))
+}
+
+// UpdateRetention sets how long the messages of a conversation are kept
+func (h *Handler) UpdateRetention(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	var req UpdateRetentionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to bind request body: %v", err)
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	retention, err := h.chatUC.UpdateRetention(c.Request.Context(), conversationID, userID, req.Policy)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to update retention: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toRetentionResponse(retention))
+}
+
+// MarkConversationRead moves the read position of the current user in a conversation
+func (h *Handler) MarkConversationRead(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
 		response.WithError(c, err)
 		return
 	}
 
-	// Convert to response models
-	responseConvs := make([]ConversationResponse, len(conversations))
-	for i, conv := range conversations {
-		responseConvs[i] = toConversationResponse(conv)
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	// An empty body marks the whole conversation as read
+	var req MarkConversationReadRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.logger.Errorf(c.Request.Context(), "Failed to bind request body: %v", err)
+			response.WithError(c, response.ErrInvalidRequest)
+			return
+		}
+	}
+
+	if err := h.chatUC.MarkConversationRead(c.Request.Context(), conversationID, userID, req.MessageID); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to mark conversation as read: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
 	}
 
-	response.WithData(c, http.StatusOK, responseConvs)
+	c.Status(http.StatusNoContent)
 }
 
 // GetConversation gets a conversation by ID
@@ -198,8 +333,9 @@ func (h *Handler) UpdateConversation(c *gin.Context) {
 		conversation.Name = req.Name
 	}
 
-	if err := h.chatUC.UpdateConversation(c.Request.Context(), conversation); err != nil {
-		response.WithError(c, err)
+	if err := h.chatUC.UpdateConversation(c.Request.Context(), conversation, userID); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to update conversation: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
 		return
 	}
 
@@ -222,12 +358,185 @@ func (h *Handler) DeleteConversation(c *gin.Context) {
 		return
 	}
 
-	if err := h.chatUC.DeleteConversation(c.Request.Context(), conversationID); err != nil {
+	if err := h.chatUC.DeleteConversation(c.Request.Context(), conversationID, userID); err != nil {
 		h.logger.Errorf(c.Request.Context(), "Failed to delete conversation: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetMembers lists the members of a conversation with their roles
+func (h *Handler) GetMembers(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	members, err := h.chatUC.GetMembers(c.Request.Context(), conversationID)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to get members: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toMemberResponses(members))
+}
+
+// AddMembers adds users to a group
+func (h *Handler) AddMembers(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	var req AddMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to bind request body: %v", err)
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	added, err := h.chatUC.AddMembers(c.Request.Context(), conversationID, userID, req.UserIDs)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to add members: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toMemberResponses(added))
+}
+
+// RemoveMember removes a member from a group
+func (h *Handler) RemoveMember(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	if err := h.chatUC.RemoveMember(c.Request.Context(), conversationID, userID, c.Param("userId")); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to remove member: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LeaveConversation removes the current user from a group
+func (h *Handler) LeaveConversation(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	if err := h.chatUC.LeaveConversation(c.Request.Context(), conversationID, userID); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to leave conversation: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UpdateMemberRole promotes a member to admin or demotes an admin
+func (h *Handler) UpdateMemberRole(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	var req UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to bind request body: %v", err)
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	if err := h.chatUC.UpdateMemberRole(c.Request.Context(), conversationID, userID, c.Param("userId"), req.Role); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to update member role: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// TransferOwnership hands the ownership of a group to another member
+func (h *Handler) TransferOwnership(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
 		response.WithError(c, err)
 		return
 	}
 
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	var req TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to bind request body: %v", err)
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	if err := h.chatUC.TransferOwnership(c.Request.Context(), conversationID, userID, req.UserID); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to transfer ownership: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
 	c.Status(http.StatusNoContent)
 }
 
@@ -264,30 +573,60 @@ func (h *Handler) SendMessage(c *gin.Context) {
 		response.WithError(c, err)
 		return
 	}
+	if strings.TrimSpace(req.Content) == "" && len(req.AttachmentIDs) == 0 {
+		response.WithMappedError(c, chat.ErrEmptyContent, chat.MapError)
+		return
+	}
 
 	// Create message
 	message := &models.Message{
+		ID:             uuid.New().String(),
 		ConversationID: conversationID,
 		SenderID:       userID,
 		Content:        req.Content,
+		MessageType:    models.MessageTypeText,
+		ReplyToID:      req.ReplyToID,
+		CreatedAt:      time.Now(),
+	}
+	if req.ClientMessageID != "" {
+		message.ClientMessageID = &req.ClientMessageID
+	}
+	if len(req.AttachmentIDs) > 0 {
+		attachments, err := h.chatUC.ValidateAttachments(c.Request.Context(), conversationID, userID, req.AttachmentIDs)
+		if err != nil {
+			response.WithMappedError(c, err, chat.MapError)
+			return
+		}
+		message.Attachments = attachments
+		message.MessageType = attachments[0].MessageType()
 	}
 
-	if err := h.chatUC.CreateMessage(c.Request.Context(), *message); err != nil {
+	stored, err := h.chatUC.CreateMessage(c.Request.Context(), *message)
+	if err != nil {
 		h.logger.Errorf(c.Request.Context(), "Failed to send message: %v", err)
-		response.WithError(c, err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+	if stored.ID != message.ID {
+		// Retried send; the message was stored by an earlier attempt
+		response.WithCode(c, http.StatusOK, toMessageResponse(stored))
 		return
 	}
 
-	response.WithCode(c, http.StatusCreated, toMessageResponse(message))
+	response.WithCode(c, http.StatusCreated, toMessageResponse(stored))
 }
 
 // GetMessagesRequest represents the query parameters for getting messages
+// At most one of Cursor, Before, After and Around may be set.
 type GetMessagesRequest struct {
-	Limit  int `form:"limit,default=20"` // Number of messages to return (default: 20, max: 100)
-	Offset int `form:"offset,default=0"` // Offset for pagination (default: 0)
+	Limit  int    `form:"limit,default=20"` // Number of messages to return (default: 20, max: 100)
+	Cursor string `form:"cursor"`           // Opaque next_cursor/prev_cursor from a previous page
+	Before string `form:"before"`           // Message ID; return messages older than it
+	After  string `form:"after"`            // Message ID; return messages newer than it
+	Around string `form:"around"`           // Message ID; return messages surrounding it
 }
 
-// GetMessages gets messages for a conversation with pagination
+// GetMessages gets a page of messages for a conversation, newest first
 func (h *Handler) GetMessages(c *gin.Context) {
 	userID, err := h.getUserIDFromContext(c)
 	if err != nil {
@@ -306,25 +645,653 @@ func (h *Handler) GetMessages(c *gin.Context) {
 	// Parse query parameters
 	var req GetMessagesRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
-		response.WithError(c, err)
+		response.WithError(c, response.ErrInvalidRequest)
 		return
 	}
 
 	// Validate limit (max 100 messages per request)
-	if req.Limit <= 0 || req.Limit > 100 {
+	if req.Limit <= 0 || req.Limit > chat.MaxMessagePageSize {
 		req.Limit = 20
 	}
-	if req.Offset < 0 {
-		req.Offset = 0
-	}
 
 	// Get messages from use case
-	messages, err := h.chatUC.GetMessages(c.Request.Context(), conversationID, req.Limit, req.Offset)
+	page, err := h.chatUC.GetMessages(c.Request.Context(), conversationID, &chat.MessagePageQuery{
+		Cursor:   req.Cursor,
+		BeforeID: req.Before,
+		AfterID:  req.After,
+		AroundID: req.Around,
+		Limit:    req.Limit,
+		ViewerID: userID,
+	})
 	if err != nil {
 		h.logger.Errorf(c.Request.Context(), "Failed to get messages: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toMessagePageResponse(page))
+}
+
+// SyncMessagesRequest represents the query parameters for catching up on a conversation
+type SyncMessagesRequest struct {
+	AfterSeq int64 `form:"after_seq"`        // Last sequence number the client has seen
+	Limit    int   `form:"limit,default=50"` // Number of messages to return (default: 50, max: 100)
+}
+
+// SyncMessages gets the messages stored after a sequence number, oldest first
+func (h *Handler) SyncMessages(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
 		response.WithError(c, err)
 		return
 	}
 
-	response.WithData(c, http.StatusOK, messages)
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	var req SyncMessagesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	page, err := h.chatUC.SyncMessages(c.Request.Context(), conversationID, userID, req.AfterSeq, req.Limit)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to sync messages: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toSyncResponse(page))
+}
+
+// GetThreadRequest represents the query parameters for getting a thread
+type GetThreadRequest struct {
+	Limit  int    `form:"limit,default=20"` // Number of replies to return (default: 20, max: 100)
+	Cursor string `form:"cursor"`           // Opaque next_cursor from a previous page
+}
+
+// GetThread gets a root message with a page of its replies, oldest first
+func (h *Handler) GetThread(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	var req GetThreadRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	thread, err := h.chatUC.GetThread(c.Request.Context(), conversationID, c.Param("messageId"), userID, req.Cursor, req.Limit)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to get thread: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toThreadResponse(thread))
+}
+
+// EditMessage replaces the content of a message
+func (h *Handler) EditMessage(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	var req EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to bind request body: %v", err)
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	message, err := h.chatUC.EditMessage(c.Request.Context(), conversationID, c.Param("messageId"), userID, req.Content)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to edit message: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toMessageResponse(message))
+}
+
+// DeleteMessage deletes a message, leaving a tombstone
+func (h *Handler) DeleteMessage(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	if err := h.chatUC.DeleteMessage(c.Request.Context(), conversationID, c.Param("messageId"), userID); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to delete message: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SearchMessagesRequest represents the query parameters for searching messages
+type SearchMessagesRequest struct {
+	Query          string     `form:"q" binding:"required"`
+	ConversationID string     `form:"conversation_id" binding:"omitempty,uuid"`
+	SenderID       string     `form:"sender_id" binding:"omitempty,uuid"`
+	MessageType    string     `form:"type" binding:"omitempty,oneof=text image video file voice"`
+	From           *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"` // Inclusive, RFC 3339
+	To             *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`   // Exclusive, RFC 3339
+	Cursor         string     `form:"cursor"`                                       // Opaque next_cursor from a previous page
+	Limit          int        `form:"limit,default=20"`                             // Number of hits to return (default: 20, max: 50)
+}
+
+// SearchMessages finds messages matching a full-text query across the user's conversations
+func (h *Handler) SearchMessages(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	var req SearchMessagesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	result, err := h.chatUC.SearchMessages(c.Request.Context(), &chat.SearchQuery{
+		UserID:         userID,
+		Text:           req.Query,
+		ConversationID: req.ConversationID,
+		SenderID:       req.SenderID,
+		MessageType:    models.MessageType(req.MessageType),
+		From:           req.From,
+		To:             req.To,
+		Cursor:         req.Cursor,
+		Limit:          req.Limit,
+	})
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to search messages: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toSearchResponse(result))
+}
+
+// GetMentionsRequest represents the query parameters of the mention list
+type GetMentionsRequest struct {
+	Cursor string `form:"cursor"`          // Opaque next_cursor from a previous page
+	Limit  int    `form:"limit,default=20"` // Number of mentions to return (default: 20, max: 50)
+}
+
+// GetMentions lists the recent messages mentioning the user, newest first
+func (h *Handler) GetMentions(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	var req GetMentionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	page, err := h.chatUC.GetMentions(c.Request.Context(), userID, req.Cursor, req.Limit)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to get mentions: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toMentionPageResponse(page))
+}
+
+// UploadAttachment stores a file to be shared by a following message of the conversation
+// The request is multipart/form-data with a "file" part
+func (h *Handler) UploadAttachment(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	if mb := h.cfg.Media.AttachmentMaxSizeMB; mb > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(mb)<<20+multipartOverhead)
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to read attachment upload: %v", err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.WithMappedError(c, chat.ErrAttachmentTooLarge, chat.MapError)
+			return
+		}
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.chatUC.UploadAttachment(c.Request.Context(), conversationID, userID, &chat.AttachmentUpload{
+		Reader:   file,
+		Size:     fileHeader.Size,
+		FileName: fileHeader.Filename,
+	})
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to upload attachment: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithCode(c, http.StatusCreated, toAttachmentResponse(attachment))
+}
+
+// SendVoiceMessage posts an Ogg Opus recording as a voice message
+// The request is multipart/form-data with a "file" part and an optional "reply_to_id" field
+func (h *Handler) SendVoiceMessage(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	if mb := h.cfg.Media.VoiceMaxSizeMB; mb > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(mb)<<20+multipartOverhead)
+	}
+
+	var replyToID *string
+	if id := c.PostForm("reply_to_id"); id != "" {
+		if _, err := uuid.Parse(id); err != nil {
+			response.WithMappedError(c, chat.ErrInvalidReplyTo, chat.MapError)
+			return
+		}
+		replyToID = &id
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to read voice message upload: %v", err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.WithMappedError(c, chat.ErrAttachmentTooLarge, chat.MapError)
+			return
+		}
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+	defer file.Close()
+
+	message, err := h.chatUC.SendVoiceMessage(c.Request.Context(), conversationID, userID, &chat.AttachmentUpload{
+		Reader:   file,
+		Size:     fileHeader.Size,
+		FileName: fileHeader.Filename,
+	}, replyToID)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to send voice message: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithCode(c, http.StatusCreated, toMessageResponse(message))
+}
+
+// GetAttachment gets an attachment with a fresh signed download URL
+func (h *Handler) GetAttachment(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	attachment, err := h.chatUC.GetAttachment(c.Request.Context(), conversationID, c.Param("attachmentId"), userID)
+	if err != nil {
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toAttachmentResponse(attachment))
+}
+
+// DownloadAttachmentRequest represents the query parameters of a signed download URL
+type DownloadAttachmentRequest struct {
+	Variant   string `form:"variant"`
+	UserID    string `form:"user_id" binding:"required"`
+	Expires   int64  `form:"expires" binding:"required"`
+	Signature string `form:"signature" binding:"required"`
+}
+
+// DownloadAttachment serves the content of an attachment from a signed URL
+// The URL itself authenticates the request so that it also works in <img> and <video> tags
+func (h *Handler) DownloadAttachment(c *gin.Context) {
+	var req DownloadAttachmentRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.WithMappedError(c, chat.ErrInvalidDownloadLink, chat.MapError)
+		return
+	}
+
+	download, err := h.chatUC.OpenAttachment(c.Request.Context(), c.Param("id"), req.Variant, req.UserID, req.Expires, req.Signature)
+	if err != nil {
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+	if download.RedirectURL != "" {
+		c.Redirect(http.StatusFound, download.RedirectURL)
+		return
+	}
+	defer download.Body.Close()
+
+	c.DataFromReader(http.StatusOK, download.Size, download.ContentType, download.Body, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": download.FileName}),
+		"Cache-Control":          "private, max-age=300",
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// AddReaction reacts to a message with the emoji in the path
+// Repeating the request is harmless
+func (h *Handler) AddReaction(c *gin.Context) {
+	h.changeReaction(c, h.chatUC.AddReaction)
+}
+
+// RemoveReaction withdraws the reaction with the emoji in the path
+// Removing a reaction that does not exist succeeds
+func (h *Handler) RemoveReaction(c *gin.Context) {
+	h.changeReaction(c, h.chatUC.RemoveReaction)
+}
+
+func (h *Handler) changeReaction(c *gin.Context, change func(ctx context.Context, conversationID, messageID, userID, emoji string) error) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	if err := change(c.Request.Context(), conversationID, c.Param("messageId"), userID, c.Param("emoji")); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to change reaction: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PinMessage pins a message to the conversation
+// Pinning a pinned message succeeds and returns the existing pin
+func (h *Handler) PinMessage(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	pin, err := h.chatUC.PinMessage(c.Request.Context(), conversationID, c.Param("messageId"), userID)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to pin message: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toPinResponse(pin))
+}
+
+// UnpinMessage removes the pin of a message
+func (h *Handler) UnpinMessage(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	if err := h.chatUC.UnpinMessage(c.Request.Context(), conversationID, c.Param("messageId"), userID); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to unpin message: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetPinnedMessages lists the pinned messages of a conversation, most recently pinned first
+func (h *Handler) GetPinnedMessages(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	pinned, err := h.chatUC.GetPinnedMessages(c.Request.Context(), conversationID, userID)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to get pinned messages: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toPinnedMessageResponses(pinned))
+}
+
+// ForwardMessage copies a message with its attachments to other conversations of the user
+// The new messages are returned in the order of the requested conversations
+func (h *Handler) ForwardMessage(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	var req ForwardMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to bind request body: %v", err)
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	messages, err := h.chatUC.ForwardMessage(c.Request.Context(), conversationID, c.Param("messageId"), userID, req.ConversationIDs, req.IncludeSender)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to forward message: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	responses := make([]MessageResponse, len(messages))
+	for i, message := range messages {
+		responses[i] = toMessageResponse(message)
+	}
+	response.WithCode(c, http.StatusCreated, responses)
+}
+
+// GetMessageRevisions gets the edit history of a message
+func (h *Handler) GetMessageRevisions(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	revisions, err := h.chatUC.GetMessageRevisions(c.Request.Context(), conversationID, c.Param("messageId"))
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to get message revisions: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toMessageRevisionResponses(revisions))
+}
+
+// GetMessageStatuses gets the per-recipient delivery status of a message
+func (h *Handler) GetMessageStatuses(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	conversationID := c.Param("id")
+	if ok, err := h.validateConversationAccess(c, userID, conversationID); !ok {
+		if err != nil {
+			response.WithError(c, err)
+		}
+		return
+	}
+
+	statuses, err := h.chatUC.GetMessageStatuses(c.Request.Context(), conversationID, c.Param("messageId"))
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to get message statuses: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toMessageStatusResponses(statuses))
+}
+
+// GetPresenceRequest represents the query parameters for getting presence
+type GetPresenceRequest struct {
+	UserIDs string `form:"user_ids" binding:"required"` // Comma separated user IDs (max 100)
+}
+
+// GetPresence gets the online/away/offline state and last seen time of users
+// Users who share no conversation with the current user are left out
+func (h *Handler) GetPresence(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+
+	var req GetPresenceRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	userIDs := strings.Split(req.UserIDs, ",")
+	if len(userIDs) > 100 {
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	presences, err := h.chatUC.GetPresence(c.Request.Context(), userID, userIDs)
+	if err != nil {
+		h.logger.Errorf(c.Request.Context(), "Failed to get presence: %v", err)
+		response.WithMappedError(c, err, chat.MapError)
+		return
+	}
+
+	response.WithData(c, http.StatusOK, toPresenceResponses(presences))
 }
@@ -3,7 +3,9 @@ package http
 import (
 	"time"
 
+	"video-call/internal/chat"
 	"video-call/internal/models"
+	"gorm.io/datatypes"
 )
 
 // Request and Response models
@@ -16,6 +18,53 @@ type (
 		MemberIDs []string `json:"member_ids"`
 	}
 
+	// AddMembersRequest represents the request body for adding members to a group
+	AddMembersRequest struct {
+		UserIDs []string `json:"user_ids" binding:"required,min=1,max=100,dive,uuid"`
+	}
+
+	// UpdateMemberRoleRequest represents the request body for changing the role of a member
+	UpdateMemberRoleRequest struct {
+		Role models.ParticipantRole `json:"role" binding:"required,oneof=admin member"`
+	}
+
+	// TransferOwnershipRequest represents the request body for handing a group to another member
+	TransferOwnershipRequest struct {
+		UserID string `json:"user_id" binding:"required,uuid"`
+	}
+
+	// GetConversationsRequest represents the query parameters of the conversation list
+	GetConversationsRequest struct {
+		Archived bool `form:"archived"`
+	}
+
+	// UpdateConversationSettingsRequest represents the request body for changing the settings of a conversation
+	// Omitted fields are left unchanged; muting requires muted_until
+	UpdateConversationSettingsRequest struct {
+		Muted      *bool      `json:"muted"`
+		MutedUntil *time.Time `json:"muted_until"`
+		Pinned     *bool      `json:"pinned"`
+		Archived   *bool      `json:"archived"`
+	}
+
+	// MarkConversationReadRequest represents the request body for moving the read position
+	// Without a message ID the conversation is read up to its latest message
+	MarkConversationReadRequest struct {
+		MessageID string `json:"message_id" binding:"omitempty,uuid"`
+	}
+
+	// UpdateRetentionRequest represents the request body for setting how long messages of a conversation are kept
+	// "default" follows the tenant default and "off" keeps messages forever
+	UpdateRetentionRequest struct {
+		Policy string `json:"policy" binding:"required,oneof=24h 7d 90d off default"`
+	}
+
+	// RetentionResponse represents the retention policy of a conversation
+	RetentionResponse struct {
+		Policy           string `json:"policy"`
+		RetentionSeconds int64  `json:"retention_seconds"` // 0 keeps messages forever
+	}
+
 	// UpdateConversationRequest represents the request body for updating a conversation
 	UpdateConversationRequest struct {
 		Name string `json:"name"`
@@ -23,28 +72,197 @@ type (
 
 	// SendMessageRequest represents the request body for sending a message
 	SendMessageRequest struct {
+		Content       string   `json:"content"`
+		ReplyToID     *string  `json:"reply_to_id" binding:"omitempty,uuid"`
+		AttachmentIDs []string `json:"attachment_ids" binding:"omitempty,max=10,dive,uuid"`
+		// ClientMessageID makes retries of the send idempotent
+		ClientMessageID string `json:"client_message_id" binding:"omitempty,max=64,printascii"`
+	}
+
+	// EditMessageRequest represents the request body for editing a message
+	EditMessageRequest struct {
 		Content string `json:"content" binding:"required"`
 	}
 
+	// ForwardMessageRequest represents the request body for forwarding a message to other conversations
+	ForwardMessageRequest struct {
+		ConversationIDs []string `json:"conversation_ids" binding:"required,min=1,max=10,dive,uuid"`
+		// IncludeSender credits the sender of the original in the forwarded_from metadata
+		IncludeSender bool `json:"include_sender"`
+	}
+
 	// ConversationResponse represents the API response for a conversation
 	ConversationResponse struct {
 		ID          string    `json:"id"`
-		Name        string    `json:
-ame"`
+		Name        string    `json:"name"`
 		IsGroup     bool      `json:"is_group"`
 		CreatedAt   time.Time `json:"created_at"`
 		UpdatedAt   time.Time `json:"updated_at"`
 		MemberCount int       `json:"member_count"`
+		Retention   string    `json:"retention"` // Retention policy, such as "7d", "off" or "default"
+	}
+
+	// ConversationSummaryResponse represents a conversation in the conversation list of the current user
+	ConversationSummaryResponse struct {
+		ConversationResponse
+		Role              string               `json:"role"`
+		UnreadCount       int                  `json:"unread_count"`
+		LastMessage       *chat.MessagePreview `json:"last_message,omitempty"`
+		LastReadMessageID *string              `json:"last_read_message_id,omitempty"`
+		LastReadAt        *time.Time           `json:"last_read_at,omitempty"`
+		LastActivityAt    time.Time            `json:"last_activity_at"`
+		ConversationSettingsResponse
+	}
+
+	// ConversationSettingsResponse represents the settings the current user has for a conversation
+	ConversationSettingsResponse struct {
+		Muted      bool       `json:"muted"`
+		MutedUntil *time.Time `json:"muted_until,omitempty"`
+		Pinned     bool       `json:"pinned"`
+		PinnedAt   *time.Time `json:"pinned_at,omitempty"`
+		Archived   bool       `json:"archived"`
+		ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	}
+
+	// MemberResponse represents a member of a conversation
+	MemberResponse struct {
+		UserID   string    `json:"user_id"`
+		Role     string    `json:"role"`
+		JoinedAt time.Time `json:"joined_at"`
 	}
 
 	// MessageResponse represents the API response for a message
 	MessageResponse struct {
-		ID           string    `json:"id"`
-		Content      string    `json:"content"`
-		SenderID     string    `json:"sender_id"`
-		ConversationID string  `json:"conversation_id"`
-		CreatedAt    time.Time `json:"created_at"`
-		UpdatedAt    time.Time `json:"updated_at"`
+		ID             string         `json:"id"`
+		Seq            int64          `json:"seq"`
+		Content        string         `json:"content"`
+		SenderID       string         `json:"sender_id"`
+		ConversationID string         `json:"conversation_id"`
+		MessageType    string         `json:"message_type"`
+		Metadata       datatypes.JSON `json:"metadata,omitempty"`
+		CreatedAt      time.Time      `json:"created_at"`
+		UpdatedAt      time.Time      `json:"updated_at"`
+		EditedAt       *time.Time     `json:"edited_at,omitempty"`
+		DeletedAt      *time.Time     `json:"deleted_at,omitempty"`
+
+		ReplyToID   *string          `json:"reply_to_id,omitempty"`
+		ReplyTo     *MessageResponse `json:"reply_to,omitempty"`
+		ReplyCount  int              `json:"reply_count"`
+		LastReplyAt *time.Time       `json:"last_reply_at,omitempty"`
+
+		Reactions   []ReactionResponse   `json:"reactions"`
+		Attachments []AttachmentResponse `json:"attachments,omitempty"`
+	}
+
+	// AttachmentResponse represents an uploaded file
+	// URL is a signed download link for the requesting user and expires after a while
+	AttachmentResponse struct {
+		ID          string    `json:"id"`
+		MessageID   *string   `json:"message_id,omitempty"`
+		FileName    string    `json:"file_name"`
+		ContentType string    `json:"content_type"`
+		SizeBytes   int64     `json:"size_bytes"`
+		URL         string    `json:"url,omitempty"`
+		CreatedAt   time.Time `json:"created_at"`
+
+		Width      int                   `json:"width,omitempty"`
+		Height     int                   `json:"height,omitempty"`
+		DurationMs int64                 `json:"duration_ms,omitempty"`
+		BlurHash   string                `json:"blurhash,omitempty"`
+		Variants   []models.MediaVariant `json:"variants,omitempty"`
+		Processed  bool                  `json:"processed"`
+	}
+
+	// SearchHitResponse represents a message matching a search
+	// Snippet is HTML escaped, with the matching words wrapped in <mark> tags
+	SearchHitResponse struct {
+		Message MessageResponse `json:"message"`
+		Snippet string          `json:"snippet"`
+		Rank    float64         `json:"rank"`
+	}
+
+	// SearchResponse represents a page of search hits, most relevant first
+	SearchResponse struct {
+		Items      []SearchHitResponse `json:"items"`
+		NextCursor string              `json:"next_cursor,omitempty"`
+	}
+
+	// PinResponse represents the pin of a message
+	PinResponse struct {
+		MessageID string    `json:"message_id"`
+		PinnedBy  *string   `json:"pinned_by"`
+		PinnedAt  time.Time `json:"pinned_at"`
+	}
+
+	// PinnedMessageResponse represents a pinned message with who pinned it and when
+	PinnedMessageResponse struct {
+		Message  MessageResponse `json:"message"`
+		PinnedBy *string         `json:"pinned_by"`
+		PinnedAt time.Time       `json:"pinned_at"`
+	}
+
+	// MentionResponse represents a message mentioning the current user
+	MentionResponse struct {
+		Message     MessageResponse `json:"message"`
+		Everyone    bool            `json:"everyone"` // Mentioned by @all rather than by name
+		MentionedAt time.Time       `json:"mentioned_at"`
+	}
+
+	// MentionPageResponse represents a page of mentions, newest first
+	MentionPageResponse struct {
+		Items      []MentionResponse `json:"items"`
+		NextCursor string            `json:"next_cursor,omitempty"`
+	}
+
+	// ReactionResponse represents the reactions with one emoji on a message
+	ReactionResponse struct {
+		Emoji   string `json:"emoji"`
+		Count   int    `json:"count"`
+		Reacted bool   `json:"reacted"`
+	}
+
+	// ThreadResponse represents a root message with a page of its replies, oldest first
+	ThreadResponse struct {
+		Root       MessageResponse   `json:"root"`
+		Items      []MessageResponse `json:"items"`
+		NextCursor string            `json:"next_cursor,omitempty"`
+	}
+
+	// MessageRevisionResponse represents a previous content of an edited message
+	MessageRevisionResponse struct {
+		ID        string    `json:"id"`
+		Content   string    `json:"content"`
+		EditedBy  string    `json:"edited_by"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// MessagePageResponse represents a page of messages, newest first
+	// NextCursor continues with older messages and PrevCursor with newer ones
+	MessagePageResponse struct {
+		Items      []MessageResponse `json:"items"`
+		NextCursor string            `json:"next_cursor,omitempty"`
+		PrevCursor string            `json:"prev_cursor,omitempty"`
+	}
+
+	// SyncResponse represents the messages stored after a sequence number, oldest first
+	// With HasMore set the next page starts after the seq of the last item
+	SyncResponse struct {
+		Items   []MessageResponse `json:"items"`
+		HasMore bool              `json:"has_more"`
+	}
+
+	// MessageStatusResponse represents the delivery status of a message for one recipient
+	MessageStatusResponse struct {
+		UserID    string    `json:"user_id"`
+		Status    string    `json:"status"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// PresenceResponse represents the aggregated presence of a user
+	PresenceResponse struct {
+		UserID   string     `json:"user_id"`
+		Status   string     `json:"status"`
+		LastSeen *time.Time `json:"last_seen,omitempty"`
 	}
 
 	// ConversationListResponse represents a paginated list of conversations
@@ -78,17 +296,247 @@ func toConversationResponse(conv *models.Conversation) ConversationResponse {
 		IsGroup:     conv.IsGroup,
 		CreatedAt:   conv.CreatedAt,
 		MemberCount: 1, // TODO: Get actual participant count
+		Retention:   chat.RetentionPolicyOf(conv.RetentionSeconds),
+	}
+}
+
+func toConversationSummaryResponses(summaries []*chat.ConversationSummary) []ConversationSummaryResponse {
+	now := time.Now()
+	responses := make([]ConversationSummaryResponse, len(summaries))
+	for i, summary := range summaries {
+		responses[i] = ConversationSummaryResponse{
+			ConversationResponse:         toConversationResponse(summary.Conversation),
+			Role:                         string(summary.Role),
+			UnreadCount:                  summary.UnreadCount,
+			LastMessage:                  summary.LastMessage,
+			LastReadMessageID:            summary.LastReadMessageID,
+			LastReadAt:                   summary.LastReadAt,
+			LastActivityAt:               summary.LastActivityAt(),
+			ConversationSettingsResponse: toConversationSettingsResponse(summary.IsMuted(now), summary.MutedUntil, summary.PinnedAt, summary.ArchivedAt),
+		}
+	}
+	return responses
+}
+
+// toConversationSettingsResponse leaves out a mute that has expired.
+func toConversationSettingsResponse(muted bool, mutedUntil, pinnedAt, archivedAt *time.Time) ConversationSettingsResponse {
+	if !muted {
+		mutedUntil = nil
+	}
+	return ConversationSettingsResponse{
+		Muted:      muted,
+		MutedUntil: mutedUntil,
+		Pinned:     pinnedAt != nil,
+		PinnedAt:   pinnedAt,
+		Archived:   archivedAt != nil,
+		ArchivedAt: archivedAt,
+	}
+}
+
+func toMemberResponses(members []*models.ConversationParticipant) []MemberResponse {
+	responses := make([]MemberResponse, len(members))
+	for i, member := range members {
+		responses[i] = MemberResponse{
+			UserID:   member.UserID,
+			Role:     string(member.Role),
+			JoinedAt: member.JoinedAt,
+		}
 	}
+	return responses
 }
 
 func toMessageResponse(msg *models.Message) MessageResponse {
+	var replyTo *MessageResponse
+	if msg.ReplyTo != nil {
+		quoted := toMessageResponse(msg.ReplyTo)
+		replyTo = &quoted
+	}
+
 	return MessageResponse{
-		ID:            msg.ID,
-		Content:       msg.Content,
-		SenderID:      msg.SenderID,
+		ID:             msg.ID,
+		Seq:            msg.Seq,
+		Content:        msg.Content,
+		SenderID:       msg.SenderID,
 		ConversationID: msg.ConversationID,
-		CreatedAt:     msg.CreatedAt,
+		MessageType:    string(msg.MessageType),
+		Metadata:       msg.Metadata,
+		CreatedAt:      msg.CreatedAt,
+		EditedAt:       msg.EditedAt,
+		DeletedAt:      msg.DeletedAt,
+		ReplyToID:      msg.ReplyToID,
+		ReplyTo:        replyTo,
+		ReplyCount:     msg.ReplyCount,
+		LastReplyAt:    msg.LastReplyAt,
+		Reactions:      toReactionResponses(msg.Reactions),
+		Attachments:    toAttachmentResponses(msg.Attachments),
+	}
+}
+
+func toAttachmentResponse(attachment *models.Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		ID:          attachment.ID,
+		MessageID:   attachment.MessageID,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		SizeBytes:   attachment.SizeBytes,
+		URL:         attachment.URL,
+		CreatedAt:   attachment.CreatedAt,
+		Width:       attachment.Width,
+		Height:      attachment.Height,
+		DurationMs:  attachment.DurationMs,
+		BlurHash:    attachment.BlurHash,
+		Variants:    attachment.Variants,
+		Processed:   attachment.ProcessedAt != nil,
+	}
+}
+
+func toAttachmentResponses(attachments []*models.Attachment) []AttachmentResponse {
+	if len(attachments) == 0 {
+		return nil
+	}
+	responses := make([]AttachmentResponse, len(attachments))
+	for i, attachment := range attachments {
+		responses[i] = toAttachmentResponse(attachment)
+	}
+	return responses
+}
+
+func toSearchResponse(result *chat.SearchResult) SearchResponse {
+	items := make([]SearchHitResponse, len(result.Hits))
+	for i, hit := range result.Hits {
+		items[i] = SearchHitResponse{
+			Message: toMessageResponse(hit.Message),
+			Snippet: hit.Snippet,
+			Rank:    hit.Rank,
+		}
+	}
+	return SearchResponse{Items: items, NextCursor: result.NextCursor}
+}
+
+func toPinResponse(pin *models.PinnedMessage) PinResponse {
+	return PinResponse{
+		MessageID: pin.MessageID,
+		PinnedBy:  pin.PinnedBy,
+		PinnedAt:  pin.PinnedAt,
+	}
+}
+
+func toPinnedMessageResponses(pinned []*chat.PinnedMessage) []PinnedMessageResponse {
+	responses := make([]PinnedMessageResponse, len(pinned))
+	for i, item := range pinned {
+		responses[i] = PinnedMessageResponse{
+			Message:  toMessageResponse(item.Message),
+			PinnedBy: item.Pin.PinnedBy,
+			PinnedAt: item.Pin.PinnedAt,
+		}
+	}
+	return responses
+}
+
+func toRetentionResponse(retention *chat.Retention) RetentionResponse {
+	return RetentionResponse{
+		Policy:           retention.Policy,
+		RetentionSeconds: int64(retention.Period / time.Second),
+	}
+}
+
+func toMentionPageResponse(page *chat.MentionPage) MentionPageResponse {
+	items := make([]MentionResponse, len(page.Mentions))
+	for i, mention := range page.Mentions {
+		items[i] = MentionResponse{
+			Message:     toMessageResponse(mention.Message),
+			Everyone:    mention.Mention.Everyone,
+			MentionedAt: mention.Mention.CreatedAt,
+		}
+	}
+	return MentionPageResponse{Items: items, NextCursor: page.NextCursor}
+}
+
+func toReactionResponses(counts []models.ReactionCount) []ReactionResponse {
+	reactions := make([]ReactionResponse, len(counts))
+	for i, count := range counts {
+		reactions[i] = ReactionResponse{
+			Emoji:   count.Emoji,
+			Count:   count.Count,
+			Reacted: count.Reacted,
+		}
+	}
+	return reactions
+}
+
+func toThreadResponse(thread *chat.Thread) ThreadResponse {
+	items := make([]MessageResponse, len(thread.Replies))
+	for i, reply := range thread.Replies {
+		items[i] = toMessageResponse(reply)
+	}
+
+	return ThreadResponse{
+		Root:       toMessageResponse(thread.Root),
+		Items:      items,
+		NextCursor: thread.NextCursor,
+	}
+}
+
+func toMessageRevisionResponses(revisions []*models.MessageRevision) []MessageRevisionResponse {
+	items := make([]MessageRevisionResponse, len(revisions))
+	for i, revision := range revisions {
+		items[i] = MessageRevisionResponse{
+			ID:        revision.ID,
+			Content:   revision.Content,
+			EditedBy:  revision.EditedBy,
+			CreatedAt: revision.CreatedAt,
+		}
+	}
+	return items
+}
+
+func toMessagePageResponse(page *chat.MessagePage) MessagePageResponse {
+	items := make([]MessageResponse, len(page.Messages))
+	for i, msg := range page.Messages {
+		items[i] = toMessageResponse(msg)
+	}
+
+	return MessagePageResponse{
+		Items:      items,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+}
+
+func toSyncResponse(page *chat.SyncPage) SyncResponse {
+	items := make([]MessageResponse, len(page.Messages))
+	for i, msg := range page.Messages {
+		items[i] = toMessageResponse(msg)
+	}
+
+	return SyncResponse{
+		Items:   items,
+		HasMore: page.HasMore,
+	}
+}
+
+func toMessageStatusResponses(statuses []*models.MessageStatus) []MessageStatusResponse {
+	items := make([]MessageStatusResponse, len(statuses))
+	for i, status := range statuses {
+		items[i] = MessageStatusResponse{
+			UserID:    status.UserID,
+			Status:    string(status.Status),
+			UpdatedAt: status.UpdatedAt,
+		}
+	}
+	return items
+}
+
+func toPresenceResponses(presences []*models.UserPresence) []PresenceResponse {
+	items := make([]PresenceResponse, len(presences))
+	for i, presence := range presences {
+		items[i] = PresenceResponse{
+			UserID:   presence.UserID,
+			Status:   string(presence.Status),
+			LastSeen: presence.LastSeen,
+		}
 	}
+	return items
 }
 
 func toConversationListResponse(convs []*models.Conversation, total, page, pageSize int) ConversationListResponse {
@@ -8,13 +8,56 @@ import (
 
 // Map news routes
 func MapRoutes(group *gin.RouterGroup, h chat.Handlers, mw *middleware.MiddlewareManager) {
+	// Signed download URLs authenticate themselves and must work without a bearer token
+	group.GET("/attachments/:id/content", h.DownloadAttachment)
+
+	group.Use(mw.AuthJWTMiddleware())
+
 	group.POST("/conversations", h.CreateConversation)
+	group.POST("/direct/:userId", h.GetOrCreateDirectConversation)
 	group.GET("/conversations", h.GetConversations)
 	group.GET("/conversations/:id", h.GetConversation)
 	group.PUT("/conversations/:id", h.UpdateConversation)
 	group.DELETE("/conversations/:id", h.DeleteConversation)
+	group.POST("/conversations/:id/read", h.MarkConversationRead)
+	group.PATCH("/conversations/:id/settings", h.UpdateConversationSettings)
+	group.PUT("/conversations/:id/retention", h.UpdateRetention)
+
+	// Membership of group conversations
+	group.GET("/conversations/:id/members", h.GetMembers)
+	group.POST("/conversations/:id/members", h.AddMembers)
+	group.DELETE("/conversations/:id/members/:userId", h.RemoveMember)
+	group.PUT("/conversations/:id/members/:userId/role", h.UpdateMemberRole)
+	group.POST("/conversations/:id/leave", h.LeaveConversation)
+	group.POST("/conversations/:id/owner", h.TransferOwnership)
 
 	// Message routes within a conversation
 	group.GET("/conversations/:id/messages", h.GetMessages)
+	group.GET("/conversations/:id/sync", h.SyncMessages)
 	group.POST("/conversations/:id/messages", h.SendMessage)
+	group.POST("/conversations/:id/messages/voice", h.SendVoiceMessage)
+	group.PATCH("/conversations/:id/messages/:messageId", h.EditMessage)
+	group.DELETE("/conversations/:id/messages/:messageId", h.DeleteMessage)
+	group.GET("/conversations/:id/messages/:messageId/revisions", h.GetMessageRevisions)
+	group.GET("/conversations/:id/messages/:messageId/thread", h.GetThread)
+	group.GET("/conversations/:id/messages/:messageId/status", h.GetMessageStatuses)
+	group.PUT("/conversations/:id/messages/:messageId/reactions/:emoji", h.AddReaction)
+	group.DELETE("/conversations/:id/messages/:messageId/reactions/:emoji", h.RemoveReaction)
+	group.PUT("/conversations/:id/messages/:messageId/pin", h.PinMessage)
+	group.DELETE("/conversations/:id/messages/:messageId/pin", h.UnpinMessage)
+	group.GET("/conversations/:id/pins", h.GetPinnedMessages)
+	group.POST("/conversations/:id/messages/:messageId/forward", h.ForwardMessage)
+
+	// Attachments are uploaded first and then shared by a message
+	group.POST("/conversations/:id/attachments", h.UploadAttachment)
+	group.GET("/conversations/:id/attachments/:attachmentId", h.GetAttachment)
+
+	// Full-text search across the user's conversations
+	group.GET("/search", h.SearchMessages)
+
+	// Recent mentions of the user across conversations
+	group.GET("/mentions", h.GetMentions)
+
+	// Presence of users
+	group.GET("/presence", h.GetPresence)
 }
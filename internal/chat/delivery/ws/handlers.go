@@ -2,11 +2,13 @@
 package delivery
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
-	"strconv"
+	"sync/atomic"
+	"time"
 
 	"video-call/internal/chat"
 	"video-call/internal/models"
@@ -15,9 +17,22 @@ import (
 	ws "video-call/pkg/websocket"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+var errConnectionClosed = errors.New("connection closed")
+
+const (
+	// deliveryQueueSize is how many delivery receipts a connection holds before dropping them;
+	// it takes a full replay of missed messages
+	deliveryQueueSize = chat.MaxCatchUpMessages
+	// deliveryBatchSize is the most delivery receipts stored at once
+	deliveryBatchSize = 100
+	// deliveryTimeout bounds storing a batch of delivery receipts
+	deliveryTimeout = 5 * time.Second
+)
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -43,33 +58,44 @@ func NewWsHandler(hub *ws.RedisHub, chatUC chat.UseCase, writer usecase.MessageW
 }
 
 // ServeWs handles WebSocket requests for Gin.
+// One connection per user carries all of the user's conversations: it is subscribed to each of them
+// and follows the user into conversations joined later. Clients may subscribe and unsubscribe explicitly.
 func (h *WsHandler) ServeWs(c *gin.Context) {
-	// Get user ID from JWT token context
-	userID, err := utils.GetUserIDFromCtx(c.Request.Context())
+	// Get user from JWT token context
+	user, err := utils.GetUserFromCtx(c.Request.Context())
 	if err != nil {
 		c.String(http.StatusUnauthorized, "Unauthorized: missing or invalid token")
 		return
 	}
+	userID := user.ID
 
-	// Get conversation ID from query parameter
+	// conversation_id is optional; it is the conversation of frames that do not name one
 	conversationID := c.Query("conversation_id")
-	if conversationID == "" {
-		c.String(http.StatusBadRequest, "Missing conversation_id")
-		return
+	if conversationID != "" {
+		// Check if user is a participant in the conversation
+		isParticipant, err := h.chatUc.IsUserInConversation(c.Request.Context(), userID, conversationID)
+		if err != nil {
+			log.Printf("Failed to check conversation participation: %v", err)
+			c.String(http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		if !isParticipant {
+			c.String(http.StatusForbidden, "Forbidden: not a participant in this conversation")
+			return
+		}
 	}
 
-	// Convert user ID to string
-	userIDStr := strconv.FormatUint(userID, 10)
-
-	// Check if user is a participant in the conversation
-	isParticipant, err := h.chatUc.IsUserInConversation(c.Request.Context(), userIDStr, conversationID)
+	conversations, err := h.chatUc.GetConversationsByUserID(c.Request.Context(), userID)
 	if err != nil {
-		log.Printf("Failed to check conversation participation: %v", err)
+		log.Printf("Failed to get conversations of user %s: %v", userID, err)
 		c.String(http.StatusInternalServerError, "Internal server error")
 		return
 	}
-	if !isParticipant {
-		c.String(http.StatusForbidden, "Forbidden: not a participant in this conversation")
+
+	// last_seq=<conversation_id>:<seq> replays what the client missed before live frames of the conversation
+	cursors, err := parseSyncCursors(c.QueryArray("last_seq"), conversations)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -79,48 +105,443 @@ func (h *WsHandler) ServeWs(c *gin.Context) {
 		return
 	}
 
-	onMessage := func(message []byte) {
-		var req CreateMessageRequest
-		if err := json.Unmarshal(message, &req); err != nil {
-			log.Printf("Failed to unmarshal message: %v", err)
+	s := &session{
+		userID:                userID,
+		connectionID:          uuid.New().String(),
+		defaultConversationID: conversationID,
+		presence:              &atomic.Value{},
+		done:                  make(chan struct{}),
+		typing:                make(map[string]*typingIndicator),
+		removed:               make(map[string]bool),
+		syncing:               make(map[string][][]byte),
+		delivered:             make(chan string, deliveryQueueSize),
+	}
+	s.presence.Store(models.PresenceOnline)
+
+	s.client = ws.NewClient(h.hub, conn, userID, func(message []byte) {
+		h.handleFrame(s, message)
+	})
+	s.client.Accept = s.accept
+	s.client.OnDelivered = func(message []byte) {
+		h.handleDelivered(s, message)
+		h.followMembership(s, message)
+	}
+	s.client.OnClose = func() {
+		close(s.done)
+		for conversationID, typing := range s.typingIndicators() {
+			typing.stop(func() { h.publishTyping(conversationID, userID, false) })
+		}
+		if err := h.chatUc.RemovePresence(context.Background(), userID, s.connectionID); err != nil {
+			log.Printf("Failed to remove presence of user %s: %v", userID, err)
+		}
+	}
+
+	h.hub.SubscribeClient(s.client, chat.UserTopic(userID))
+	for _, conversation := range conversations {
+		h.hub.SubscribeClient(s.client, chat.ConversationTopic(conversation.ID))
+	}
+	for _, cursor := range cursors {
+		s.beginSync(chat.ConversationTopic(cursor.ConversationID))
+	}
+	h.hub.Register(s.client)
+
+	go h.keepPresence(userID, s.connectionID, s.presence, s.done)
+	go h.recordDeliveries(s)
+	go s.client.WritePump()
+	go s.client.ReadPump()
+	go func() {
+		for _, cursor := range cursors {
+			h.catchUp(s, cursor.ConversationID, cursor.LastSeq)
+		}
+	}()
+
+}
+
+// handleFrame dispatches a frame received from the client on its type.
+func (h *WsHandler) handleFrame(s *session, message []byte) {
+	var frame inboundFrame
+	if err := json.Unmarshal(message, &frame); err != nil {
+		log.Printf("Failed to unmarshal message: %v", err)
+		return
+	}
+	conversationID := frame.ConversationID
+	if conversationID == "" {
+		conversationID = s.defaultConversationID
+	}
+
+	switch frame.Type {
+	case frameSubscribe:
+		h.handleSubscribe(s, frame.ConversationID)
+	case frameUnsubscribe:
+		h.unsubscribe(s, frame.ConversationID)
+		h.sendFrame(s.client, subscriptionFrame{Type: frameUnsubscribed, ConversationID: frame.ConversationID})
+	case frameSync:
+		h.handleSync(s, message)
+	case frameMessageRead:
+		h.handleReadReceipt(s.userID, message)
+	case frameTypingStart:
+		if !h.hub.IsSubscribed(s.client, chat.ConversationTopic(conversationID)) {
+			return
+		}
+		s.typingIndicator(conversationID).start(
+			func() { h.publishTyping(conversationID, s.userID, true) },
+			func() { h.publishTyping(conversationID, s.userID, false) },
+		)
+	case frameTypingStop:
+		h.stopTyping(s, conversationID)
+	case framePresenceUpdate:
+		h.handlePresenceUpdate(s.userID, s.connectionID, s.presence, message)
+	case "", frameMessageSend:
+		h.stopTyping(s, conversationID)
+		h.handleSendMessage(s.client, conversationID, message)
+	default:
+		log.Printf("Unknown frame type: %s", frame.Type)
+	}
+}
+
+// handleSubscribe subscribes the connection to a conversation of the user.
+func (h *WsHandler) handleSubscribe(s *session, conversationID string) {
+	isParticipant, err := h.chatUc.IsUserInConversation(context.Background(), s.userID, conversationID)
+	if err != nil || !isParticipant {
+		if err == nil {
+			err = chat.ErrNotAllowed
+		}
+		log.Printf("Refused subscription of user %s to conversation %s: %v", s.userID, conversationID, err)
+		h.sendFrame(s.client, errorFrame{Type: frameError, ConversationID: conversationID, Error: err.Error()})
+		return
+	}
+
+	topic := chat.ConversationTopic(conversationID)
+	s.resubscribed(topic)
+	h.hub.SubscribeClient(s.client, topic)
+	h.sendFrame(s.client, subscriptionFrame{Type: frameSubscribed, ConversationID: conversationID})
+}
+
+// unsubscribe stops the events of a conversation and ends the typing indicator of the user there.
+func (h *WsHandler) unsubscribe(s *session, conversationID string) {
+	h.hub.UnsubscribeClient(s.client, chat.ConversationTopic(conversationID))
+	h.stopTyping(s, conversationID)
+}
+
+// stopTyping ends the typing indicator of the user in a conversation, if there is one.
+func (h *WsHandler) stopTyping(s *session, conversationID string) {
+	if typing := s.takeTypingIndicator(conversationID); typing != nil {
+		typing.stop(func() { h.publishTyping(conversationID, s.userID, false) })
+	}
+}
+
+// handleSync replays the messages of a subscribed conversation the client missed.
+func (h *WsHandler) handleSync(s *session, message []byte) {
+	var req SyncRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		log.Printf("Failed to unmarshal sync request: %v", err)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		log.Printf("Invalid sync request format: %v", err)
+		h.sendFrame(s.client, errorFrame{Type: frameError, ConversationID: req.ConversationID, Error: err.Error()})
+		return
+	}
+
+	topic := chat.ConversationTopic(req.ConversationID)
+	if !h.hub.IsSubscribed(s.client, topic) {
+		h.sendFrame(s.client, errorFrame{Type: frameError, ConversationID: req.ConversationID, Error: chat.ErrNotAllowed.Error()})
+		return
+	}
+	if !s.beginSync(topic) {
+		return // already catching up
+	}
+	h.catchUp(s, req.ConversationID, req.LastSeq)
+}
+
+// catchUp replays the messages of a conversation stored after afterSeq as message.new frames,
+// followed by a sync.done frame. Live frames of the conversation are held back meanwhile
+// and resume afterwards, so the client receives everything in order.
+func (h *WsHandler) catchUp(s *session, conversationID string, afterSeq int64) {
+	topic := chat.ConversationTopic(conversationID)
+	lastSeq, hasMore, err := h.replayMessages(s, conversationID, afterSeq)
+	if err != nil {
+		log.Printf("Failed to replay conversation %s for user %s: %v", conversationID, s.userID, err)
+		h.sendFrame(s.client, errorFrame{Type: frameError, ConversationID: conversationID, Error: "failed to sync"})
+	}
+
+	payload, err := json.Marshal(syncDoneFrame{
+		Type:           frameSyncDone,
+		ConversationID: conversationID,
+		LastSeq:        lastSeq,
+		HasMore:        hasMore,
+	})
+	if err == nil && !s.client.SendWait(payload, s.done) {
+		return
+	}
+	if !s.endSync(topic, lastSeq) {
+		log.Printf("Closing lagging connection of user %s after sync", s.userID)
+		s.client.Close(websocket.CloseTryAgainLater, "too many pending frames")
+	}
+}
+
+// replayMessages sends the messages after afterSeq, up to chat.MaxCatchUpMessages.
+// It returns the sequence number of the last one sent and whether more are left.
+func (h *WsHandler) replayMessages(s *session, conversationID string, afterSeq int64) (lastSeq int64, hasMore bool, err error) {
+	lastSeq = afterSeq
+	for sent := 0; sent < chat.MaxCatchUpMessages; {
+		page, err := h.chatUc.SyncMessages(context.Background(), conversationID, s.userID, lastSeq, chat.MaxMessagePageSize)
+		if err != nil {
+			return lastSeq, false, err
+		}
+		for _, message := range page.Messages {
+			payload, err := json.Marshal(chat.NewMessageEvent(message))
+			if err != nil {
+				return lastSeq, false, err
+			}
+			// Wait for the write pump instead of dropping frames of a long gap
+			if !s.client.SendWait(payload, s.done) {
+				return lastSeq, false, errConnectionClosed
+			}
+			lastSeq = message.Seq
+		}
+		sent += len(page.Messages)
+		if !page.HasMore {
+			return lastSeq, false, nil
+		}
+	}
+	return lastSeq, true, nil
+}
+
+// followMembership keeps the subscriptions in line with the memberships of the user once the
+// frame changing them was delivered: removals unsubscribe and additions subscribe.
+func (h *WsHandler) followMembership(s *session, message []byte) {
+	frame, ok := parseMembershipFrame(message)
+	if !ok || frame.ConversationID == "" {
+		return
+	}
+	switch {
+	case frame.endsMembership(s.userID):
+		h.unsubscribe(s, frame.ConversationID)
+	case frame.addsMember(s.userID):
+		if !h.hub.IsSubscribed(s.client, chat.ConversationTopic(frame.ConversationID)) {
+			// Do not block the write pump on the database.
+			go h.handleSubscribe(s, frame.ConversationID)
+		}
+	}
+}
+
+// keepPresence refreshes the presence of the connection until done is closed.
+func (h *WsHandler) keepPresence(userID, connectionID string, presence *atomic.Value, done <-chan struct{}) {
+	ticker := time.NewTicker(chat.PresenceHeartbeat)
+	defer ticker.Stop()
+	for {
+		status := presence.Load().(models.PresenceStatus)
+		if err := h.chatUc.UpdatePresence(context.Background(), userID, connectionID, status); err != nil {
+			log.Printf("Failed to update presence of user %s: %v", userID, err)
+		}
+		select {
+		case <-done:
 			return
+		case <-ticker.C:
 		}
+	}
+}
+
+// handlePresenceUpdate switches the connection between online and away.
+func (h *WsHandler) handlePresenceUpdate(userID, connectionID string, presence *atomic.Value, message []byte) {
+	var req PresenceUpdateRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		log.Printf("Failed to unmarshal presence update: %v", err)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		log.Printf("Invalid presence update format: %v", err)
+		return
+	}
+	presence.Store(req.Status)
+	if err := h.chatUc.UpdatePresence(context.Background(), userID, connectionID, req.Status); err != nil {
+		log.Printf("Failed to update presence of user %s: %v", userID, err)
+	}
+}
 
-		if err := h.validator.Struct(req); err != nil {
-			log.Printf("Invalid message format: %v", err)
+// publishTyping publishes a typing.start or typing.stop event for the user.
+func (h *WsHandler) publishTyping(conversationID, userID string, typing bool) {
+	var err error
+	if typing {
+		err = h.chatUc.StartTyping(context.Background(), conversationID, userID)
+	} else {
+		err = h.chatUc.StopTyping(context.Background(), conversationID, userID)
+	}
+	if err != nil {
+		log.Printf("Failed to publish typing state of user %s: %v", userID, err)
+	}
+}
+
+// handleSendMessage queues a new message for persistence; the usecase publishes it once stored.
+// Nothing of the client frame is forwarded as is. The sending connection is answered
+// with an ack once the message is stored, or with a nack.
+func (h *WsHandler) handleSendMessage(client *ws.Client, conversationID string, message []byte) {
+	userID := client.ID
+
+	var req CreateMessageRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		log.Printf("Failed to unmarshal message: %v", err)
+		h.sendNack(client, "", nackInvalidFrame, err)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.Printf("Invalid message format: %v", err)
+		h.sendNack(client, req.ClientMessageID, nackInvalidFrame, err)
+		return
+	}
+
+	// Subscriptions are authorized, so they also tell where the user may post.
+	if !h.hub.IsSubscribed(client, chat.ConversationTopic(conversationID)) {
+		h.sendNack(client, req.ClientMessageID, nackNotAllowed, chat.ErrNotAllowed)
+		return
+	}
+
+	// The DB write is asynchronous, so reject replies to foreign messages before queueing.
+	if req.ReplyToID != nil {
+		if err := h.chatUc.ValidateReplyTo(context.Background(), conversationID, *req.ReplyToID); err != nil {
+			log.Printf("Invalid reply target %s: %v", *req.ReplyToID, err)
+			h.sendNack(client, req.ClientMessageID, nackReason(err), err)
 			return
 		}
+	}
 
-		msg := models.Message{
-			SenderID:       userIDStr,
-			ConversationID: conversationID,
-			Content:        req.Content,
-			MessageType:    req.MessageType,
-			Metadata:       req.Metadata,
+	var attachments []*models.Attachment
+	if len(req.AttachmentIDs) > 0 {
+		var err error
+		attachments, err = h.chatUc.ValidateAttachments(context.Background(), conversationID, userID, req.AttachmentIDs)
+		if err != nil {
+			log.Printf("Invalid attachments of user %s: %v", userID, err)
+			h.sendNack(client, req.ClientMessageID, nackReason(err), err)
+			return
 		}
+	}
 
-		// Đẩy vào hàng đợi DB
-		h.writer.Enqueue(msg)
+	msg := models.Message{
+		ID:             uuid.New().String(),
+		SenderID:       userID,
+		ConversationID: conversationID,
+		Content:        req.Content,
+		MessageType:    req.MessageType,
+		Metadata:       req.Metadata,
+		ReplyToID:      req.ReplyToID,
+		CreatedAt:      time.Now(),
+		Attachments:    attachments,
+	}
+	if req.ClientMessageID != "" {
+		msg.ClientMessageID = &req.ClientMessageID
+	}
 
-		// Publish lên Redis
-		topic := fmt.Sprintf("conversation_%s", conversationID)
-		err := h.hub.Publish(topic, message)
+	// Đẩy vào hàng đợi DB
+	err := h.writer.Enqueue(msg, func(stored *models.Message, err error) {
 		if err != nil {
-			log.Printf("Failed to publish message to Redis: %v", err)
+			h.sendNack(client, req.ClientMessageID, nackReason(err), err)
+			return
 		}
+		// The usecase publishes the message once stored; a retry only gets the original ack
+		h.sendAck(client, req.ClientMessageID, stored)
+	})
+	if err != nil {
+		log.Printf("Failed to queue message of user %s: %v", userID, err)
+		h.sendNack(client, req.ClientMessageID, nackInternal, err)
 	}
+}
+
+// sendAck tells the sending connection that its message was stored.
+func (h *WsHandler) sendAck(client *ws.Client, clientMessageID string, stored *models.Message) {
+	h.sendFrame(client, ackFrame{
+		Type:            frameAck,
+		ClientMessageID: clientMessageID,
+		ID:              stored.ID,
+		CreatedAt:       stored.CreatedAt,
+	})
+}
 
-	client := ws.NewClient(h.hub, conn, userIDStr, onMessage)
+// sendNack tells the sending connection that its message was rejected.
+func (h *WsHandler) sendNack(client *ws.Client, clientMessageID, reason string, err error) {
+	h.sendFrame(client, nackFrame{
+		Type:            frameNack,
+		ClientMessageID: clientMessageID,
+		Reason:          reason,
+		Error:           err.Error(),
+	})
+}
 
-	h.hub.Register(client)
-	topic := fmt.Sprintf("conversation_%s", conversationID)
-	h.hub.SubscribeTopic(topic)
-	if client.Topics == nil {
-		client.Topics = make(map[string]bool)
+// sendFrame writes a frame to a single connection.
+func (h *WsHandler) sendFrame(client *ws.Client, frame any) {
+	payload, err := json.Marshal(frame)
+	if err != nil {
+		log.Printf("Failed to marshal frame: %v", err)
+		return
+	}
+	if !client.Send(payload) {
+		log.Printf("Dropped %s frame for lagging client %s", payload, client.ID)
 	}
-	client.Topics[topic] = true
+}
 
-	go client.WritePump()
-	go client.ReadPump()
+// handleReadReceipt records that the user has read a message.
+func (h *WsHandler) handleReadReceipt(userID string, message []byte) {
+	var req ReadReceiptRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		log.Printf("Failed to unmarshal read receipt: %v", err)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		log.Printf("Invalid read receipt format: %v", err)
+		return
+	}
 
+	if err := h.chatUc.MarkMessageRead(context.Background(), req.MessageID, userID); err != nil {
+		log.Printf("Failed to mark message %s as read: %v", req.MessageID, err)
+	}
+}
+
+// handleDelivered queues a delivery receipt for new message frames written to the user's socket.
+// It runs on the write pump, so receipts that do not fit the queue are dropped rather than waited for;
+// reading the message records it later anyway.
+func (h *WsHandler) handleDelivered(s *session, message []byte) {
+	var frame outboundMessageFrame
+	if err := json.Unmarshal(message, &frame); err != nil {
+		return
+	}
+	// Messages of the user reach their other connections too, but are not delivered to anyone there
+	if frame.Type != chat.EventMessageNew || frame.ID == "" || frame.SenderID == s.userID {
+		return
+	}
+
+	select {
+	case s.delivered <- frame.ID:
+	default:
+		log.Printf("Delivery receipts of user %s are backed up, dropping message %s", s.userID, frame.ID)
+	}
+}
+
+// recordDeliveries stores the queued delivery receipts of a connection in batches until it closes.
+func (h *WsHandler) recordDeliveries(s *session) {
+	for {
+		var messageID string
+		select {
+		case messageID = <-s.delivered:
+		case <-s.done:
+			return
+		}
+
+		batch := []string{messageID}
+	drain:
+		for len(batch) < deliveryBatchSize {
+			select {
+			case messageID = <-s.delivered:
+				batch = append(batch, messageID)
+			default:
+				break drain
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+		if err := h.chatUc.MarkMessagesDelivered(ctx, batch, s.userID); err != nil {
+			log.Printf("Failed to mark %d messages as delivered for user %s: %v", len(batch), s.userID, err)
+		}
+		cancel()
+	}
 }
@@ -1,13 +1,226 @@
 package delivery
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"video-call/internal/chat"
 	"video-call/internal/models"
 	"gorm.io/datatypes"
 )
 
+// Inbound frame types sent by clients over the chat WebSocket.
+const (
+	frameMessageSend    = "message.send"
+	frameMessageRead    = "message.read"
+	frameTypingStart    = "typing.start"
+	frameTypingStop     = "typing.stop"
+	framePresenceUpdate = "presence.update"
+	frameSubscribe      = "subscribe"
+	frameUnsubscribe    = "unsubscribe"
+	frameSync           = "sync"
+)
+
+// Outbound frame types answering subscribe and unsubscribe frames.
+const (
+	frameSubscribed   = "subscribed"
+	frameUnsubscribed = "unsubscribed"
+	frameError        = "error"
+	frameSyncDone     = "sync.done"
+)
+
+// Outbound frame types answering a message.send to the sending connection only.
+const (
+	frameAck  = "ack"
+	frameNack = "nack"
+)
+
+// Reasons of a nack frame.
+const (
+	nackInvalidFrame      = "invalid_frame"
+	nackInvalidReply      = "invalid_reply"
+	nackInvalidAttachment = "invalid_attachment"
+	nackNotAllowed        = "not_allowed"
+	nackInternal          = "internal_error"
+)
+
+// inboundFrame is used to dispatch an incoming WebSocket frame on its type.
+// Frames without a type are treated as message.send, and frames without a conversation
+// ID go to the conversation the connection was opened for, for backward compatibility.
+type inboundFrame struct {
+	Type           string `json:"type"`
+	ConversationID string `json:"conversation_id"`
+}
+
+// subscriptionFrame confirms a subscribe or unsubscribe frame.
+type subscriptionFrame struct {
+	Type           string `json:"type"`
+	ConversationID string `json:"conversation_id"`
+}
+
+// SyncRequest asks for the messages of a conversation stored after the last one the client has seen.
+type SyncRequest struct {
+	ConversationID string `json:"conversation_id" validate:"required,uuid"`
+	LastSeq        int64  `json:"last_seq" validate:"min=0"`
+}
+
+// syncCursor is the last sequence number a client has seen in a conversation.
+type syncCursor struct {
+	ConversationID string
+	LastSeq        int64
+}
+
+// parseSyncCursors parses last_seq query values of the form <conversation_id>:<seq>.
+// Every conversation must be one of the user's and appear once.
+func parseSyncCursors(values []string, conversations []*models.Conversation) ([]syncCursor, error) {
+	member := make(map[string]bool, len(conversations))
+	for _, conversation := range conversations {
+		member[conversation.ID] = true
+	}
+
+	seen := make(map[string]bool, len(values))
+	cursors := make([]syncCursor, 0, len(values))
+	for _, value := range values {
+		conversationID, seq, ok := strings.Cut(value, ":")
+		lastSeq, err := strconv.ParseInt(seq, 10, 64)
+		if !ok || err != nil || lastSeq < 0 {
+			return nil, fmt.Errorf("invalid last_seq %q", value)
+		}
+		if !member[conversationID] {
+			return nil, fmt.Errorf("not a participant in conversation %s", conversationID)
+		}
+		if seen[conversationID] {
+			return nil, fmt.Errorf("duplicate last_seq for conversation %s", conversationID)
+		}
+		seen[conversationID] = true
+		cursors = append(cursors, syncCursor{ConversationID: conversationID, LastSeq: lastSeq})
+	}
+	return cursors, nil
+}
+
+// syncDoneFrame ends the replay of missed messages; live frames of the conversation follow.
+// With HasMore set the client pages through the rest of the gap with the sync endpoint.
+type syncDoneFrame struct {
+	Type           string `json:"type"`
+	ConversationID string `json:"conversation_id"`
+	LastSeq        int64  `json:"last_seq"`
+	HasMore        bool   `json:"has_more"`
+}
+
+// errorFrame tells the client that a frame was refused.
+type errorFrame struct {
+	Type           string `json:"type"`
+	ConversationID string `json:"conversation_id,omitempty"`
+	Error          string `json:"error"`
+}
+
 // CreateMessageRequest defines the expected structure for incoming WebSocket messages.
 type CreateMessageRequest struct {
-	Content     string             `json:"content" validate:"required"`
-	MessageType models.MessageType `json:"message_type" validate:"required"`
-	Metadata    datatypes.JSON     `json:"metadata,omitempty"`
+	Content       string             `json:"content" validate:"required_without=AttachmentIDs"`
+	MessageType   models.MessageType `json:"message_type" validate:"required"`
+	Metadata      datatypes.JSON     `json:"metadata,omitempty"`
+	ReplyToID     *string            `json:"reply_to_id,omitempty" validate:"omitempty,uuid"`
+	AttachmentIDs []string           `json:"attachment_ids,omitempty" validate:"max=10,dive,uuid"`
+	// ClientMessageID is echoed in the ack or nack; retries with the same ID get the original ack
+	ClientMessageID string `json:"client_message_id,omitempty" validate:"omitempty,max=64,printascii"`
+}
+
+// ackFrame tells the sender that a message was stored, with its server assigned ID and timestamp.
+type ackFrame struct {
+	Type            string    `json:"type"`
+	ClientMessageID string    `json:"client_message_id,omitempty"`
+	ID              string    `json:"id"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// nackFrame tells the sender that a message was rejected and will not be stored.
+type nackFrame struct {
+	Type            string `json:"type"`
+	ClientMessageID string `json:"client_message_id,omitempty"`
+	Reason          string `json:"reason"`
+	Error           string `json:"error"`
+}
+
+// nackReason maps an error of a send to the reason of its nack frame.
+func nackReason(err error) string {
+	switch {
+	case errors.Is(err, chat.ErrInvalidReplyTo):
+		return nackInvalidReply
+	case errors.Is(err, chat.ErrInvalidAttachment):
+		return nackInvalidAttachment
+	case errors.Is(err, chat.ErrNotAllowed):
+		return nackNotAllowed
+	default:
+		return nackInternal
+	}
+}
+
+// ReadReceiptRequest acknowledges that the client has read a message.
+type ReadReceiptRequest struct {
+	MessageID string `json:"message_id" validate:"required,uuid"`
+}
+
+// PresenceUpdateRequest switches the connection between online and away.
+type PresenceUpdateRequest struct {
+	Status models.PresenceStatus `json:"status" validate:"required,oneof=online away"`
+}
+
+// membershipFrame is the part of an outgoing membership frame needed to follow the memberships of the user.
+type membershipFrame struct {
+	Type           string `json:"type"`
+	ConversationID string `json:"conversation_id"`
+	Data           struct {
+		UserID  string `json:"user_id"`
+		Members []struct {
+			UserID string `json:"user_id"`
+		} `json:"members"`
+	} `json:"data"`
+}
+
+// parseMembershipFrame decodes an outgoing frame; ok is false for frames that are not JSON objects.
+func parseMembershipFrame(message []byte) (frame *membershipFrame, ok bool) {
+	frame = &membershipFrame{}
+	if err := json.Unmarshal(message, frame); err != nil {
+		return nil, false
+	}
+	return frame, true
+}
+
+// endsMembership reports whether the frame removes the user from its conversation,
+// either by removing the user or by deleting the conversation.
+func (f *membershipFrame) endsMembership(userID string) bool {
+	switch f.Type {
+	case chat.EventConversationDeleted:
+		return true
+	case chat.EventMemberRemoved:
+		return f.Data.UserID == userID
+	default:
+		return false
+	}
+}
+
+// addsMember reports whether the frame adds the user to its conversation.
+func (f *membershipFrame) addsMember(userID string) bool {
+	if f.Type != chat.EventMemberAdded {
+		return false
+	}
+	for _, member := range f.Data.Members {
+		if member.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// outboundMessageFrame is the part of an outgoing message frame needed to record its delivery
+// and to drop duplicates of replayed messages.
+type outboundMessageFrame struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	Seq      int64  `json:"seq"`
+	SenderID string `json:"sender_id"`
 }
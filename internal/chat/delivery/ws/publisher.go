@@ -0,0 +1,47 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+	ws "video-call/pkg/websocket"
+)
+
+// publisher implements chat.Publisher on top of the Redis backed hub.
+type publisher struct {
+	hub *ws.RedisHub
+}
+
+// NewPublisher is the constructor for publisher.
+func NewPublisher(hub *ws.RedisHub) chat.Publisher {
+	return &publisher{hub: hub}
+}
+
+// PublishMessage implements chat.Publisher.
+func (p *publisher) PublishMessage(ctx context.Context, message *models.Message) error {
+	payload, err := json.Marshal(chat.NewMessageEvent(message))
+	if err != nil {
+		return err
+	}
+	return p.hub.Publish(chat.ConversationTopic(message.ConversationID), payload)
+}
+
+// PublishToConversation implements chat.Publisher.
+func (p *publisher) PublishToConversation(ctx context.Context, conversationID string, event *chat.Event) error {
+	return p.publish(chat.ConversationTopic(conversationID), event)
+}
+
+// PublishToUser implements chat.Publisher.
+func (p *publisher) PublishToUser(ctx context.Context, userID string, event *chat.Event) error {
+	return p.publish(chat.UserTopic(userID), event)
+}
+
+func (p *publisher) publish(topic string, event *chat.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.hub.PublishExcept(topic, payload, event.SenderID)
+}
@@ -0,0 +1,130 @@
+package delivery
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"video-call/internal/chat"
+	ws "video-call/pkg/websocket"
+)
+
+// session is the state of one chat connection of a user.
+// The connection is subscribed to the conversations of the user and its own user topic.
+type session struct {
+	client       *ws.Client
+	userID       string
+	connectionID string
+	// defaultConversationID is used for frames without a conversation_id, as sent by
+	// clients of the former per-conversation socket
+	defaultConversationID string
+	presence              *atomic.Value
+	done                  chan struct{}
+	// delivered queues the IDs of new messages written to the connection for delivery receipts
+	delivered chan string
+
+	mu sync.Mutex
+	// typing holds an indicator per conversation the user typed in
+	typing map[string]*typingIndicator
+	// removed holds the conversation topics the user was removed from;
+	// nothing else of them may reach the connection until it subscribes again
+	removed map[string]bool
+	// syncing holds back the live frames of conversation topics whose missed messages are being replayed
+	syncing map[string][][]byte
+}
+
+// typingIndicator returns the typing indicator of a conversation, creating it if there is none.
+// Only conversations the connection is subscribed to may get one.
+func (s *session) typingIndicator(conversationID string) *typingIndicator {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	typing, ok := s.typing[conversationID]
+	if !ok {
+		typing = &typingIndicator{}
+		s.typing[conversationID] = typing
+	}
+	return typing
+}
+
+// takeTypingIndicator removes the typing indicator of a conversation and returns it,
+// or nil if the user did not type there.
+func (s *session) takeTypingIndicator(conversationID string) *typingIndicator {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	typing := s.typing[conversationID]
+	delete(s.typing, conversationID)
+	return typing
+}
+
+// typingIndicators returns the typing indicators of all conversations by conversation ID.
+func (s *session) typingIndicators() map[string]*typingIndicator {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	typing := make(map[string]*typingIndicator, len(s.typing))
+	for conversationID, indicator := range s.typing {
+		typing[conversationID] = indicator
+	}
+	return typing
+}
+
+// accept implements ws.Client.Accept. Frames of a conversation stop at the one
+// removing the user from it, which is still delivered.
+func (s *session) accept(topic string, message []byte) bool {
+	if topic == chat.UserTopic(s.userID) {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.removed[topic] {
+		return false
+	}
+	if frame, ok := parseMembershipFrame(message); ok && frame.endsMembership(s.userID) {
+		s.removed[topic] = true
+	}
+	if held, ok := s.syncing[topic]; ok {
+		s.syncing[topic] = append(held, message)
+		return false
+	}
+	return true
+}
+
+// beginSync holds back the live frames of a conversation topic until endSync.
+// It reports false if the topic is already being synced.
+func (s *session) beginSync(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.syncing[topic]; ok {
+		return false
+	}
+	s.syncing[topic] = nil
+	return true
+}
+
+// endSync resumes the live frames of a conversation topic, starting with the ones held back
+// except for messages up to lastSeq, which were replayed. It reports false if the client
+// lags too far behind to take them.
+func (s *session) endSync(topic string, lastSeq int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held := s.syncing[topic]
+	delete(s.syncing, topic)
+
+	// The lock keeps newer live frames of the topic from overtaking the held ones
+	for _, message := range held {
+		var frame outboundMessageFrame
+		if err := json.Unmarshal(message, &frame); err == nil && frame.Type == chat.EventMessageNew && frame.Seq <= lastSeq {
+			continue
+		}
+		if !s.client.Send(message) {
+			return false
+		}
+	}
+	return true
+}
+
+// resubscribed clears the removal of the user from a conversation topic.
+func (s *session) resubscribed(topic string) {
+	s.mu.Lock()
+	delete(s.removed, topic)
+	s.mu.Unlock()
+}
@@ -0,0 +1,54 @@
+package delivery
+
+import (
+	"sync"
+	"time"
+
+	"video-call/internal/chat"
+)
+
+// typingIndicator tracks the typing state of one connection so that an
+// indicator the client never stops expires after chat.TypingTimeout.
+type typingIndicator struct {
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// start publishes a start and (re)arms the expiry timer, which publishes a stop when it fires.
+func (t *typingIndicator) start(publishStart, publishStop func()) {
+	t.mu.Lock()
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(chat.TypingTimeout, func() {
+		t.mu.Lock()
+		expired := t.timer == timer
+		if expired {
+			t.timer = nil
+		}
+		t.mu.Unlock()
+		if expired {
+			publishStop()
+		}
+	})
+	t.timer = timer
+	t.mu.Unlock()
+
+	publishStart()
+}
+
+// stop publishes a stop if the connection is currently typing.
+func (t *typingIndicator) stop(publishStop func()) {
+	t.mu.Lock()
+	active := t.timer != nil
+	if active {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.mu.Unlock()
+
+	if active {
+		publishStop()
+	}
+}
@@ -63,6 +63,72 @@ func MapError(err error) (status int, message string) {
 	}
 
 	switch {
+	case errors.Is(err, ErrConversationNotFound):
+		return http.StatusNotFound, ErrConversationNotFound.Error()
+	case errors.Is(err, ErrMessageNotFound):
+		return http.StatusNotFound, ErrMessageNotFound.Error()
+	case errors.Is(err, ErrInvalidConversationID):
+		return http.StatusBadRequest, ErrInvalidConversationID.Error()
+	case errors.Is(err, ErrInvalidMessageID):
+		return http.StatusBadRequest, ErrInvalidMessageID.Error()
+	case errors.Is(err, ErrInvalidUserID):
+		return http.StatusBadRequest, ErrInvalidUserID.Error()
+	case errors.Is(err, ErrInvalidCursor):
+		return http.StatusBadRequest, ErrInvalidCursor.Error()
+	case errors.Is(err, ErrInvalidSeq):
+		return http.StatusBadRequest, ErrInvalidSeq.Error()
+	case errors.Is(err, ErrInvalidReplyTo):
+		return http.StatusBadRequest, ErrInvalidReplyTo.Error()
+	case errors.Is(err, ErrDuplicateMessage):
+		return http.StatusConflict, ErrDuplicateMessage.Error()
+	case errors.Is(err, ErrAttachmentNotFound):
+		return http.StatusNotFound, ErrAttachmentNotFound.Error()
+	case errors.Is(err, ErrInvalidAttachment):
+		return http.StatusBadRequest, ErrInvalidAttachment.Error()
+	case errors.Is(err, ErrAttachmentTooLarge):
+		return http.StatusRequestEntityTooLarge, ErrAttachmentTooLarge.Error()
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType, ErrUnsupportedMediaType.Error()
+	case errors.Is(err, ErrInvalidVoiceMessage):
+		return http.StatusUnsupportedMediaType, ErrInvalidVoiceMessage.Error()
+	case errors.Is(err, ErrVoiceMessageTooLong):
+		return http.StatusBadRequest, ErrVoiceMessageTooLong.Error()
+	case errors.Is(err, ErrInvalidDownloadLink):
+		return http.StatusForbidden, ErrInvalidDownloadLink.Error()
+	case errors.Is(err, ErrInvalidEmoji):
+		return http.StatusBadRequest, ErrInvalidEmoji.Error()
+	case errors.Is(err, ErrInvalidSearchQuery):
+		return http.StatusBadRequest, ErrInvalidSearchQuery.Error()
+	case errors.Is(err, ErrInvalidDateRange):
+		return http.StatusBadRequest, ErrInvalidDateRange.Error()
+	case errors.Is(err, ErrParticipantNotFound):
+		return http.StatusNotFound, ErrParticipantNotFound.Error()
+	case errors.Is(err, ErrNotGroupConversation):
+		return http.StatusBadRequest, ErrNotGroupConversation.Error()
+	case errors.Is(err, ErrInvalidMembers):
+		return http.StatusBadRequest, ErrInvalidMembers.Error()
+	case errors.Is(err, ErrOwnerCannotLeave):
+		return http.StatusConflict, ErrOwnerCannotLeave.Error()
+	case errors.Is(err, ErrInvalidMuteUntil):
+		return http.StatusBadRequest, ErrInvalidMuteUntil.Error()
+	case errors.Is(err, ErrInvalidRetention):
+		return http.StatusBadRequest, ErrInvalidRetention.Error()
+	case errors.Is(err, ErrTooManyPins):
+		return http.StatusConflict, ErrTooManyPins.Error()
+	case errors.Is(err, ErrPinNotFound):
+		return http.StatusNotFound, ErrPinNotFound.Error()
+	case errors.Is(err, ErrInvalidForwardTarget):
+		return http.StatusBadRequest, ErrInvalidForwardTarget.Error()
+	case errors.Is(err, ErrNotForwardable):
+		return http.StatusBadRequest, ErrNotForwardable.Error()
+	case errors.Is(err, ErrMediaNotReady):
+		return http.StatusConflict, ErrMediaNotReady.Error()
+	case errors.Is(err, ErrEmptyContent):
+		return http.StatusBadRequest, ErrEmptyContent.Error()
+	case errors.Is(err, ErrMessageDeleted):
+		return http.StatusConflict, ErrMessageDeleted.Error()
+	case errors.Is(err, ErrNotAllowed):
+		return http.StatusForbidden, ErrNotAllowed.Error()
 	case errors.Is(err, ErrUserAlreadyExists):
 		return http.StatusConflict, errUserAlreadyExists
 	case errors.Is(err, ErrUserNotFound):
@@ -0,0 +1,119 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"video-call/internal/models"
+
+	"gorm.io/datatypes"
+)
+
+// Event types pushed to clients over the chat WebSocket.
+const (
+	EventMessageNew      = "message.new"
+	EventMessageStatus   = "message.status"
+	EventMessageUpdated  = "message.updated"
+	EventMessageDeleted  = "message.deleted"
+	EventMessagePinned   = "message.pinned"
+	EventMessageUnpinned = "message.unpinned"
+	EventReactionAdd     = "reaction.add"
+	EventReactionRemove  = "reaction.remove"
+	EventTypingStart     = "typing.start"
+	EventTypingStop      = "typing.stop"
+	EventPresenceUpdate  = "presence.update"
+
+	EventConversationRead      = "conversation.read"
+	EventConversationSettings  = "conversation.settings"
+	EventMessageNotification   = "notification.message"
+	EventMention               = "mention.new"
+	EventMemberAdded           = "member.added"
+	EventMemberRemoved         = "member.removed"
+	EventMemberRoleUpdated     = "member.role_updated"
+	EventConversationUpdated   = "conversation.updated"
+	EventConversationDeleted   = "conversation.deleted"
+	EventConversationRetention = "conversation.retention"
+)
+
+const (
+	// TypingTimeout is how long a typing indicator lives without being renewed.
+	TypingTimeout = 6 * time.Second
+	// PresenceHeartbeat is how often a connection refreshes its presence.
+	PresenceHeartbeat = 30 * time.Second
+)
+
+// Event is the envelope of a server originated frame on the chat WebSocket.
+// Connections of SenderID do not receive the event back, so it is only set on events that none of
+// the user's devices needs, such as typing indicators; message events reach all of them.
+type Event struct {
+	Type           string `json:"type"`
+	ConversationID string `json:"conversation_id,omitempty"`
+	SenderID       string `json:"sender_id,omitempty"`
+	Data           any    `json:"data,omitempty"`
+}
+
+// MessageEvent is the message.new frame, built by the server from the stored message.
+// Seq lets a reconnecting client ask for the messages it missed.
+// Download URLs of attachments are signed per user; recipients fetch theirs from the attachment endpoint.
+// The frame also reaches the connections of the sender, which show messages sent from their other
+// devices; the sending connection recognizes its own by ClientMessageID and has the ack already.
+type MessageEvent struct {
+	Type            string               `json:"type"`
+	ID              string               `json:"id"`
+	Seq             int64                `json:"seq"`
+	ConversationID  string               `json:"conversation_id"`
+	SenderID        string               `json:"sender_id"`
+	ClientMessageID *string              `json:"client_message_id,omitempty"`
+	Content         string               `json:"content"`
+	MessageType     models.MessageType   `json:"message_type"`
+	Metadata        datatypes.JSON       `json:"metadata,omitempty"`
+	ReplyToID       *string              `json:"reply_to_id,omitempty"`
+	Attachments     []*models.Attachment `json:"attachments,omitempty"`
+	CreatedAt       time.Time            `json:"created_at"`
+	// Set when a missed message is replayed after it was edited or deleted
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// NewMessageEvent builds the message.new frame of a stored message.
+func NewMessageEvent(message *models.Message) *MessageEvent {
+	return &MessageEvent{
+		Type:            EventMessageNew,
+		ID:              message.ID,
+		Seq:             message.Seq,
+		ConversationID:  message.ConversationID,
+		SenderID:        message.SenderID,
+		ClientMessageID: message.ClientMessageID,
+		Content:         message.Content,
+		MessageType:     message.MessageType,
+		Metadata:        message.Metadata,
+		ReplyToID:       message.ReplyToID,
+		Attachments:     message.Attachments,
+		CreatedAt:       message.CreatedAt,
+		EditedAt:        message.EditedAt,
+		DeletedAt:       message.DeletedAt,
+	}
+}
+
+// Publisher pushes events to connected clients on every node.
+type Publisher interface {
+	// PublishMessage delivers the message.new frame of a stored message to the subscribers of its conversation
+	PublishMessage(ctx context.Context, message *models.Message) error
+
+	// PublishToConversation delivers an event to every subscriber of a conversation
+	PublishToConversation(ctx context.Context, conversationID string, event *Event) error
+
+	// PublishToUser delivers an event to every connection of a single user
+	PublishToUser(ctx context.Context, userID string, event *Event) error
+}
+
+// ConversationTopic returns the hub topic carrying events of a conversation.
+func ConversationTopic(conversationID string) string {
+	return fmt.Sprintf("conversation_%s", conversationID)
+}
+
+// UserTopic returns the hub topic carrying events addressed to a single user.
+func UserTopic(userID string) string {
+	return fmt.Sprintf("user_%s", userID)
+}
@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"errors"
+	"time"
+
+	"video-call/internal/models"
+)
+
+const (
+	// PreviewLength is the number of characters of the last message shown in the conversation list.
+	PreviewLength = 100
+	// MaxUnreadCount caps the unread count of a conversation; clients show it as "999+".
+	MaxUnreadCount = 999
+)
+
+// ErrInvalidMuteUntil is returned when a conversation is muted without an end in the future
+var ErrInvalidMuteUntil = errors.New("muted_until must be in the future")
+
+// ConversationFilter selects the conversations of the conversation list.
+type ConversationFilter struct {
+	// Archived lists the archived conversations instead of the others
+	Archived bool
+}
+
+// SettingsUpdate changes the settings a user has for a conversation; nil fields are left unchanged.
+type SettingsUpdate struct {
+	// Muted false unmutes; Muted true mutes until MutedUntil
+	Muted      *bool
+	MutedUntil *time.Time
+	Pinned     *bool
+	Archived   *bool
+}
+
+// MessagePreview is the last message of a conversation as shown in the conversation list.
+type MessagePreview struct {
+	ID          string             `json:"id"`
+	SenderID    string             `json:"sender_id"`
+	Snippet     string             `json:"snippet"`
+	MessageType models.MessageType `json:"message_type"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Deleted     bool               `json:"deleted"`
+}
+
+// ConversationSummary is a conversation as listed in the inbox of one of its members.
+type ConversationSummary struct {
+	Conversation *models.Conversation
+	Role         models.ParticipantRole
+	// UnreadCount counts the messages of others after the read position, up to MaxUnreadCount
+	UnreadCount       int
+	LastMessage       *MessagePreview
+	LastReadMessageID *string
+	LastReadAt        *time.Time
+	MutedUntil        *time.Time
+	PinnedAt          *time.Time
+	ArchivedAt        *time.Time
+}
+
+// IsMuted reports whether the user muted the conversation at the given time.
+func (s *ConversationSummary) IsMuted(at time.Time) bool {
+	return s.MutedUntil != nil && s.MutedUntil.After(at)
+}
+
+// LastActivityAt is the time of the last message, or the creation of a conversation without messages.
+func (s *ConversationSummary) LastActivityAt() time.Time {
+	if s.LastMessage != nil {
+		return s.LastMessage.CreatedAt
+	}
+	return s.Conversation.CreatedAt
+}
@@ -0,0 +1,92 @@
+package chat
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"video-call/internal/models"
+)
+
+const (
+	// MentionAll is the name mentioning every member of a conversation.
+	MentionAll = "all"
+	// MaxMentionsPerMessage bounds the usernames looked up per message; further ones are ignored.
+	MaxMentionsPerMessage = 50
+	// DefaultMentionPageSize is used when no or an invalid limit is requested.
+	DefaultMentionPageSize = 20
+	// MaxMentionPageSize is the largest page GetMentions returns.
+	MaxMentionPageSize = 50
+)
+
+// mentionPattern matches @name at the start of the content or after a character that
+// cannot be part of a name, so that e-mail addresses are not taken for mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@-])@([\p{L}\p{N}_.-]+)`)
+
+// ParseMentions returns the distinct usernames mentioned in content in order of appearance,
+// and whether @all is among them. A trailing dot is taken for punctuation.
+func ParseMentions(content string) (usernames []string, all bool) {
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := strings.TrimRight(match[1], ".")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if name == MentionAll {
+			all = true
+			continue
+		}
+		if len(usernames) < MaxMentionsPerMessage {
+			usernames = append(usernames, name)
+		}
+	}
+	return usernames, all
+}
+
+// Mention is a mention of a user together with the message it was made in.
+type Mention struct {
+	Mention *models.MessageMention
+	Message *models.Message
+}
+
+// MentionPage is a page of mentions of a user, newest first.
+type MentionPage struct {
+	Mentions   []*Mention
+	NextCursor string
+}
+
+// MentionCursor anchors keyset pagination on (created_at, message_id), both descending.
+type MentionCursor struct {
+	CreatedAt time.Time `json:"t"`
+	MessageID string    `json:"id"`
+}
+
+// NewMentionCursor returns a cursor continuing after mention.
+func NewMentionCursor(mention *models.MessageMention) *MentionCursor {
+	return &MentionCursor{CreatedAt: mention.CreatedAt, MessageID: mention.MessageID}
+}
+
+// Encode returns the opaque string form of the cursor.
+func (c *MentionCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeMentionCursor parses a cursor produced by MentionCursor.Encode.
+func DecodeMentionCursor(s string) (*MentionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor MentionCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.MessageID == "" || cursor.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,28 @@
+package chat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		content   string
+		usernames []string
+		all       bool
+	}{
+		{"hello", nil, false},
+		{"@alice can you check?", []string{"alice"}, false},
+		{"ping @bob.smith and @carol_1.", []string{"bob.smith", "carol_1"}, false},
+		{"@all meeting in 5, @alice too", []string{"alice"}, true},
+		{"(@alice) @alice @alice", []string{"alice"}, false},
+		{"mail me at alice@example.com", nil, false},
+		{"@@alice @", nil, false},
+	}
+	for _, tt := range tests {
+		usernames, all := ParseMentions(tt.content)
+		if !reflect.DeepEqual(usernames, tt.usernames) || all != tt.all {
+			t.Errorf("ParseMentions(%q) = %v, %v; want %v, %v", tt.content, usernames, all, tt.usernames, tt.all)
+		}
+	}
+}
@@ -5,8 +5,11 @@ package chat
 import (
 	"context"
 	"errors"
+	"time"
 
 	"video-call/internal/models"
+
+	"gorm.io/datatypes"
 )
 
 var (
@@ -16,10 +19,73 @@ var (
 	ErrInvalidConversationID = errors.New("invalid conversation ID")
 	// ErrInvalidUserID is returned when an invalid user ID is provided
 	ErrInvalidUserID = errors.New("invalid user ID")
+	// ErrMessageNotFound is returned when a message is not found
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrInvalidMessageID is returned when an invalid message ID is provided
+	ErrInvalidMessageID = errors.New("invalid message ID")
+	// ErrMessageDeleted is returned when changing a message that has been deleted
+	ErrMessageDeleted = errors.New("message has been deleted")
+	// ErrNotAllowed is returned when the user may not perform an action in a conversation
+	ErrNotAllowed = errors.New("action not allowed")
+	// ErrParticipantNotFound is returned when a user is not a member of the conversation
+	ErrParticipantNotFound = errors.New("user is not a member of the conversation")
+	// ErrNotGroupConversation is returned when changing the membership of a direct conversation
+	ErrNotGroupConversation = errors.New("conversation is not a group")
+	// ErrInvalidMembers is returned when a direct conversation is not created with exactly one other user
+	ErrInvalidMembers = errors.New("a direct conversation needs exactly one other member")
+	// ErrOwnerCannotLeave is returned when the owner leaves a group that still has other members
+	ErrOwnerCannotLeave = errors.New("the owner must transfer ownership before leaving")
+	// ErrDuplicateMessage is returned when a sender reuses a client message ID
+	ErrDuplicateMessage = errors.New("message with this client message ID already exists")
+	// ErrEmptyContent is returned when a message edit has no content
+	ErrEmptyContent = errors.New("message content is required")
+	// ErrInvalidReplyTo is returned when a reply targets a message of another conversation
+	ErrInvalidReplyTo = errors.New("replied message does not belong to the conversation")
+	// ErrAttachmentNotFound is returned when an attachment is not found
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrInvalidAttachment is returned when a message shares an attachment
+	// uploaded by someone else, to another conversation or already shared
+	ErrInvalidAttachment = errors.New("invalid attachment")
+	// ErrAttachmentTooLarge is returned when an upload exceeds the size limit
+	ErrAttachmentTooLarge = errors.New("attachment is too large")
+	// ErrUnsupportedMediaType is returned when the type of an upload is not allowed
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	// ErrInvalidVoiceMessage is returned when a voice message is not a playable Ogg Opus recording
+	ErrInvalidVoiceMessage = errors.New("voice messages must be Ogg Opus recordings")
+	// ErrVoiceMessageTooLong is returned when a voice message exceeds the maximum duration
+	ErrVoiceMessageTooLong = errors.New("voice message is too long")
+	// ErrInvalidDownloadLink is returned when an attachment download link is forged or expired
+	ErrInvalidDownloadLink = errors.New("invalid or expired download link")
+	// ErrInvalidEmoji is returned when a reaction is not a single emoji token
+	ErrInvalidEmoji = errors.New("invalid reaction emoji")
+	// ErrInvalidRetention is returned when a conversation is set to an unknown retention policy
+	ErrInvalidRetention = errors.New("invalid retention policy")
+	// ErrTooManyPins is returned when pinning a message to a conversation that has the maximum of pins
+	ErrTooManyPins = errors.New("too many pinned messages")
+	// ErrPinNotFound is returned when unpinning a message that is not pinned
+	ErrPinNotFound = errors.New("message is not pinned")
+	// ErrInvalidForwardTarget is returned when a message is forwarded to no conversation, too many,
+	// or one that is not a valid conversation ID
+	ErrInvalidForwardTarget = errors.New("invalid forward target")
+	// ErrNotForwardable is returned when forwarding a message whose content only its participants may open,
+	// such as a voicemail
+	ErrNotForwardable = errors.New("message cannot be forwarded")
+	// ErrMediaNotReady is returned when forwarding images or videos whose metadata was not stripped
+	ErrMediaNotReady = errors.New("media has not been processed")
 )
 
 // Repository defines the interface for chat-related data access operations.
+// Queries returning messages leave out the ones past the retention of their conversation,
+// as if they were purged already.
 type Repository interface {
+	// Transaction runs fn in a database transaction. Repository and Outbox calls made with
+	// the context passed to fn take part in it; the transaction commits if fn returns nil.
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+
+	// AfterCommit runs hook once the transaction of ctx commits, or right away outside of one.
+	// Side effects of work done in a transaction, such as push notifications, go through it.
+	AfterCommit(ctx context.Context, hook func(ctx context.Context))
+
 	// CreateConversation creates a new conversation
 	CreateConversation(ctx context.Context, conversation *models.Conversation) error
 
@@ -30,19 +96,199 @@ type Repository interface {
 	// Results are ordered by last activity (newest first)
 	GetConversationsByUserID(ctx context.Context, userID string) ([]*models.Conversation, error)
 
-	// UpdateConversation updates an existing conversation
+	// GetConversationSummaries retrieves the conversations of a user matching the filter with their unread count
+	// and last message, pinned conversations first and then ordered by last activity (newest first)
+	GetConversationSummaries(ctx context.Context, userID string, filter ConversationFilter) ([]*ConversationSummary, error)
+
+	// UpdateParticipantSettings applies a settings update to the membership of a user
+	// Returns ErrParticipantNotFound if the user is not a member
+	UpdateParticipantSettings(ctx context.Context, conversationID, userID string, update *SettingsUpdate, now time.Time) (*models.ConversationParticipant, error)
+
+	// GetNotificationRecipients retrieves the members of a conversation other than senderID
+	// who have not muted it at the given time
+	GetNotificationRecipients(ctx context.Context, conversationID, senderID string, at time.Time) ([]string, error)
+
+	// MarkConversationRead moves the read position of a member forward to a message of the conversation
+	// and marks the earlier messages as read; advanced is false if the position already was at or after it.
+	// read holds the ID and sender of the messages whose status changed to read
+	MarkConversationRead(ctx context.Context, conversationID, userID, messageID string, readAt time.Time) (advanced bool, read []*models.Message, err error)
+
+	// UpdateConversation saves the name of an existing conversation
 	UpdateConversation(ctx context.Context, conversation *models.Conversation) error
 
+	// UpdateConversationRetention sets the retention of a conversation in seconds;
+	// nil follows the tenant default and 0 keeps messages forever
+	UpdateConversationRetention(ctx context.Context, conversationID string, seconds *int64) error
+
+	// PurgeExpiredMessages deletes up to limit messages older than the retention of their conversation
+	// at the given time, together with their statuses, reactions, attachments and the voicemails they announced
+	PurgeExpiredMessages(ctx context.Context, at time.Time, limit int) (*PurgedMessages, error)
+
+	// LockMessage keeps a message from being purged or changed until the transaction in ctx ends
+	// Returns ErrMessageNotFound if the message does not exist or has expired
+	LockMessage(ctx context.Context, messageID string) error
+
 	// DeleteConversation deletes a conversation by its ID
 	DeleteConversation(ctx context.Context, conversationID string) error
 
+	// CreateConversationWithParticipants creates a conversation and its participant rows in one transaction
+	// Returns ErrUserNotFound if one of the participants does not exist
+	CreateConversationWithParticipants(ctx context.Context, conversation *models.Conversation, participants []*models.ConversationParticipant) error
+
+	// FindDirectConversation retrieves the one-to-one conversation between two users
+	// Returns ErrConversationNotFound if the users have no direct conversation yet
+	FindDirectConversation(ctx context.Context, userA, userB string) (*models.Conversation, error)
+
+	// CreateDirectConversation creates the one-to-one conversation between two users with both as participants
+	// A unique key per pair makes it race-safe: if the conversation already exists it is returned with created false
+	// Returns ErrUserNotFound if one of the users does not exist
+	CreateDirectConversation(ctx context.Context, conversation *models.Conversation, userA, userB string) (*models.Conversation, bool, error)
+
 	// IsUserInConversation checks if a user is a participant in a conversation
 	IsUserInConversation(ctx context.Context, userID, conversationID string) (bool, error)
 
-	// CreateMessage creates a new message in a conversation
-	CreateMessage(ctx context.Context, message models.Message) error
+	// GetContactIDs returns those of userIDs who share at least one conversation with userID
+	GetContactIDs(ctx context.Context, userID string, userIDs []string) ([]string, error)
+
+	// GetParticipant retrieves the membership of a user in a conversation
+	// Returns ErrParticipantNotFound if the user is not a member
+	GetParticipant(ctx context.Context, conversationID, userID string) (*models.ConversationParticipant, error)
+
+	// GetParticipants retrieves the members of a conversation in joining order
+	GetParticipants(ctx context.Context, conversationID string) ([]*models.ConversationParticipant, error)
+
+	// AddParticipants adds users to a conversation with the given role, skipping those who are already members
+	// Returns the rows that were inserted, or ErrUserNotFound if one of the users does not exist
+	AddParticipants(ctx context.Context, conversationID string, userIDs []string, role models.ParticipantRole) ([]*models.ConversationParticipant, error)
+
+	// RemoveParticipant removes a user from a conversation
+	// removed is false if the user was not a member
+	RemoveParticipant(ctx context.Context, conversationID, userID string) (removed bool, err error)
+
+	// UpdateParticipantRole changes the role of a member other than the owner
+	// Returns ErrParticipantNotFound if the user is not a member or owns the conversation
+	UpdateParticipantRole(ctx context.Context, conversationID, userID string, role models.ParticipantRole) error
+
+	// TransferOwnership makes a member the owner of a conversation in one transaction, demoting the current owner to admin
+	// Returns ErrParticipantNotFound if either user is not a member or fromUserID is not the owner
+	TransferOwnership(ctx context.Context, conversationID, fromUserID, toUserID string) error
+
+	// CreateMessage creates a new message in a conversation and assigns its sequence number
+	// A "sent" status row is recorded for every other participant and message.Attachments are linked;
+	// returns ErrInvalidAttachment if one of them cannot be linked, or ErrDuplicateMessage if the sender
+	// already stored a message with the same client message ID
+	CreateMessage(ctx context.Context, message *models.Message) error
+
+	// GetMessagesAfterSeq retrieves up to limit messages of a conversation with a sequence number above afterSeq, in order
+	GetMessagesAfterSeq(ctx context.Context, conversationID string, afterSeq int64, limit int) ([]*models.Message, error)
+
+	// CreateMessages creates messages of any conversations in one transaction, like CreateMessage does for one
+	// Nothing is stored if one of them fails
+	CreateMessages(ctx context.Context, messages []*models.Message) error
+
+	// GetMessageByClientID retrieves the message a sender stored with a client message ID
+	GetMessageByClientID(ctx context.Context, senderID, clientMessageID string) (*models.Message, error)
+
+	// GetMessageByID retrieves a message by its ID
+	GetMessageByID(ctx context.Context, messageID string) (*models.Message, error)
+
+	// UpsertMessageStatus records a delivery status of a message for a recipient
+	// A status never moves backwards (read > delivered > sent); changed reports whether the row was written
+	UpsertMessageStatus(ctx context.Context, messageID, userID string, status models.MessageStatusType) (changed bool, err error)
+
+	// UpsertMessageStatuses records a delivery status of several messages for a recipient, like UpsertMessageStatus
+	// It returns the IDs of the messages whose row was written
+	UpsertMessageStatuses(ctx context.Context, messageIDs []string, userID string, status models.MessageStatusType) (changed []string, err error)
+
+	// GetMessageStatuses retrieves the per-recipient statuses of a message
+	GetMessageStatuses(ctx context.Context, messageID string) ([]*models.MessageStatus, error)
+
+	// GetMessagesByIDs retrieves the messages with the given IDs in no particular order
+	GetMessagesByIDs(ctx context.Context, messageIDs []string) ([]*models.Message, error)
+
+	// GetThreadSummaries counts the non-deleted replies of the given root messages
+	// Messages without replies are omitted
+	GetThreadSummaries(ctx context.Context, messageIDs []string) ([]*models.ThreadSummary, error)
+
+	// GetReplies retrieves up to limit replies of a message, oldest first,
+	// starting after the cursor if one is given
+	GetReplies(ctx context.Context, messageID string, cursor *MessageCursor, limit int) ([]*models.Message, error)
+
+	// UpdateMessageContent replaces the content of a message and records the previous content as a revision
+	// Returns ErrMessageDeleted if the message has been deleted
+	UpdateMessageContent(ctx context.Context, messageID, content, editedBy string, editedAt time.Time) (*models.Message, error)
+
+	// SoftDeleteMessage removes the content of a message, leaving a tombstone
+	// Returns ErrMessageDeleted if the message has already been deleted
+	SoftDeleteMessage(ctx context.Context, messageID string, deletedAt time.Time) (*models.Message, error)
+
+	// GetMessageRevisions retrieves the previous contents of a message, oldest first
+	GetMessageRevisions(ctx context.Context, messageID string) ([]*models.MessageRevision, error)
+
+	// CreateAttachment stores the record of an uploaded attachment
+	CreateAttachment(ctx context.Context, attachment *models.Attachment) error
+
+	// GetAttachmentByID retrieves an attachment by its ID
+	GetAttachmentByID(ctx context.Context, attachmentID string) (*models.Attachment, error)
+
+	// UpdateAttachmentMedia stores the results of processing an attachment
+	UpdateAttachmentMedia(ctx context.Context, attachment *models.Attachment) error
+
+	// ClaimUnprocessedAttachments claims up to limit shared attachments of the given media type prefixes
+	// that are neither processed nor failed, oldest first. Attachments attempted after retryBefore are left out;
+	// claiming one counts an attempt, so another node does not claim it too
+	ClaimUnprocessedAttachments(ctx context.Context, typePrefixes []string, retryBefore time.Time, limit int) ([]*models.Attachment, error)
+
+	// FailAttachmentMedia records that the media pipeline gave up on an attachment
+	FailAttachmentMedia(ctx context.Context, attachmentID string, failedAt time.Time) error
+
+	// MergeMessageMetadata merges the keys of metadata into the metadata of a message
+	// Returns ErrMessageDeleted if the message has been deleted
+	MergeMessageMetadata(ctx context.Context, messageID string, metadata datatypes.JSON) (*models.Message, error)
+
+	// GetAttachmentsByIDs retrieves the attachments with the given IDs in no particular order
+	GetAttachmentsByIDs(ctx context.Context, attachmentIDs []string) ([]*models.Attachment, error)
+
+	// GetAttachmentsByMessageIDs retrieves the attachments shared by the given messages in upload order
+	GetAttachmentsByMessageIDs(ctx context.Context, messageIDs []string) ([]*models.Attachment, error)
+
+	// AddReaction records a reaction of a user to a message
+	// added is false if the user had already reacted with the same emoji
+	AddReaction(ctx context.Context, reaction *models.MessageReaction) (added bool, err error)
+
+	// RemoveReaction removes a reaction of a user from a message
+	// removed is false if there was no such reaction
+	RemoveReaction(ctx context.Context, messageID, userID, emoji string) (removed bool, err error)
+
+	// GetReactionCounts aggregates the reactions of the given messages per emoji,
+	// flagging the emoji userID reacted with; ordered by first use of each emoji
+	GetReactionCounts(ctx context.Context, messageIDs []string, userID string) ([]*models.ReactionCount, error)
+
+	// PinMessage pins a message unless the conversation already has max pins.
+	// pinned is false if the message was pinned already, in which case the existing pin is returned
+	PinMessage(ctx context.Context, pin *models.PinnedMessage, max int) (current *models.PinnedMessage, pinned bool, err error)
+
+	// UnpinMessage removes the pin of a message
+	// unpinned is false if the message was not pinned
+	UnpinMessage(ctx context.Context, messageID string) (unpinned bool, err error)
+
+	// GetPinnedMessages retrieves the pins of a conversation, most recently pinned first
+	GetPinnedMessages(ctx context.Context, conversationID string) ([]*models.PinnedMessage, error)
+
+	// CreateMentions records the users mentioned by messages
+	CreateMentions(ctx context.Context, mentions []*models.MessageMention) error
+
+	// GetMentions retrieves up to limit mentions of a user in non-deleted messages of conversations
+	// the user is still a member of, newest first and starting after the cursor if one is given
+	GetMentions(ctx context.Context, userID string, cursor *MentionCursor, limit int) ([]*models.MessageMention, error)
+
+	// SearchMessages retrieves up to limit non-deleted messages matching a full-text query
+	// in the conversations of query.UserID, ordered by decreasing relevance and
+	// starting after the cursor if one is given
+	SearchMessages(ctx context.Context, query *SearchQuery, cursor *SearchCursor, limit int) ([]*SearchHit, error)
 
-	// GetMessages retrieves messages for a conversation with pagination
-	// Returns messages in descending order by creation time (newest first)
-	GetMessages(ctx context.Context, conversationID string, limit, offset int) ([]*models.Message, error)
+	// GetMessages retrieves up to limit messages of a conversation using keyset pagination on (created_at, id)
+	// Without a cursor or with a CursorBefore cursor messages are returned newest first;
+	// with a CursorAfter cursor they are returned oldest first, starting right after the anchor
+	GetMessages(ctx context.Context, conversationID string, cursor *MessageCursor, limit int) ([]*models.Message, error)
 }
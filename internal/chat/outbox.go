@@ -0,0 +1,25 @@
+package chat
+
+import (
+	"context"
+	"time"
+
+	"video-call/internal/models"
+)
+
+// Outbox stores events in the transaction of the change they describe, so that an event is
+// published if and only if its change is committed. The relay publishes the stored events later.
+type Outbox interface {
+	// Publisher stores the events instead of publishing them; inside Repository.Transaction
+	// they are committed or rolled back together with the change
+	Publisher
+
+	// Dispatch hands up to limit undispatched events to publish, oldest first, and marks the ones
+	// published as dispatched. It stops at the first event publish fails on and returns its error;
+	// that event is tried again on the next call. Only one caller dispatches at a time; the others
+	// get 0 right away.
+	Dispatch(ctx context.Context, limit int, publish func(event *models.OutboxEvent) error) (int, error)
+
+	// PurgeDispatched deletes the events dispatched before the given time
+	PurgeDispatched(ctx context.Context, before time.Time) (int64, error)
+}
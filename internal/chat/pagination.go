@@ -0,0 +1,108 @@
+package chat
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"video-call/internal/models"
+)
+
+const (
+	// DefaultMessagePageSize is used when no or an invalid limit is requested.
+	DefaultMessagePageSize = 50
+	// MaxMessagePageSize is the largest page GetMessages returns.
+	MaxMessagePageSize = 100
+	// MaxCatchUpMessages is the most missed messages a chat connection replays per conversation;
+	// clients page through a longer gap with SyncMessages.
+	MaxCatchUpMessages = 500
+)
+
+var (
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidSeq is returned when a sequence number is negative.
+	ErrInvalidSeq = errors.New("invalid sequence number")
+)
+
+// CursorDirection tells on which side of its anchor a cursor continues.
+type CursorDirection string
+
+const (
+	// CursorBefore continues with messages older than the anchor.
+	CursorBefore CursorDirection = "before"
+	// CursorAfter continues with messages newer than the anchor.
+	CursorAfter CursorDirection = "after"
+)
+
+// MessageCursor anchors keyset pagination on (created_at, id).
+type MessageCursor struct {
+	Direction CursorDirection `json:"d"`
+	CreatedAt time.Time       `json:"t"`
+	ID        string          `json:"id"`
+}
+
+// MessagePageQuery selects a page of messages. At most one anchor should be set;
+// without an anchor the newest messages are returned.
+type MessagePageQuery struct {
+	Cursor   string // opaque cursor from a previous page
+	BeforeID string // messages older than this message
+	AfterID  string // messages newer than this message
+	AroundID string // messages surrounding (and including) this message
+	Limit    int
+	ViewerID string // user listing the messages; flags their own reactions
+}
+
+// MessagePage is a page of messages ordered newest first.
+// NextCursor continues with older messages and PrevCursor with newer ones.
+type MessagePage struct {
+	Messages   []*models.Message
+	NextCursor string
+	PrevCursor string
+}
+
+// SyncPage is a page of the messages a client missed, ordered by sequence number.
+// HasMore tells that the next page starts after the sequence number of the last message.
+type SyncPage struct {
+	Messages []*models.Message
+	HasMore  bool
+}
+
+// Thread is a root message with a page of its replies, oldest first.
+// NextCursor continues with later replies.
+type Thread struct {
+	Root       *models.Message
+	Replies    []*models.Message
+	NextCursor string
+}
+
+// NewMessageCursor returns a cursor continuing from message in the given direction.
+func NewMessageCursor(direction CursorDirection, message *models.Message) *MessageCursor {
+	return &MessageCursor{Direction: direction, CreatedAt: message.CreatedAt, ID: message.ID}
+}
+
+// Encode returns the opaque string form of the cursor.
+func (c *MessageCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeMessageCursor parses a cursor produced by MessageCursor.Encode.
+func DecodeMessageCursor(s string) (*MessageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor MessageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Direction != CursorBefore && cursor.Direction != CursorAfter {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.ID == "" || cursor.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
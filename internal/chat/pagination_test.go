@@ -0,0 +1,33 @@
+package chat
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"video-call/internal/models"
+)
+
+func TestMessageCursorRoundTrip(t *testing.T) {
+	message := &models.Message{
+		ID:        "0b5e3c1a-6f7e-4c1b-9a55-1f1b2f3c4d5e",
+		CreatedAt: time.Date(2025, 7, 1, 10, 30, 0, 123456000, time.UTC),
+	}
+
+	encoded := NewMessageCursor(CursorBefore, message).Encode()
+	cursor, err := DecodeMessageCursor(encoded)
+	if err != nil {
+		t.Fatalf("DecodeMessageCursor: %v", err)
+	}
+	if cursor.Direction != CursorBefore || cursor.ID != message.ID || !cursor.CreatedAt.Equal(message.CreatedAt) {
+		t.Fatalf("unexpected cursor %+v", cursor)
+	}
+}
+
+func TestDecodeMessageCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "not-base64!", "e30", NewMessageCursor("sideways", &models.Message{ID: "x", CreatedAt: time.Now()}).Encode()} {
+		if _, err := DecodeMessageCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeMessageCursor(%q) = %v, want ErrInvalidCursor", s, err)
+		}
+	}
+}
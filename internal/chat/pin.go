@@ -0,0 +1,12 @@
+package chat
+
+import "video-call/internal/models"
+
+// DefaultMaxPinnedMessages is the number of pins a conversation may have unless configured otherwise.
+const DefaultMaxPinnedMessages = 50
+
+// PinnedMessage is a pin together with the message it pins.
+type PinnedMessage struct {
+	Pin     *models.PinnedMessage
+	Message *models.Message
+}
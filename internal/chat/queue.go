@@ -0,0 +1,78 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"video-call/internal/models"
+)
+
+// QueuedMessage is a message waiting in the write-ahead queue to be stored.
+type QueuedMessage struct {
+	// EntryID identifies the entry in the queue; set when the entry is read
+	EntryID string `json:"-"`
+	// Message carries the attachments by ID only; they are looked up again when it is stored
+	Message models.Message `json:"message"`
+	// Origin is the writer that queued the message; RequestID tells it who waits for the result
+	Origin    string `json:"origin"`
+	RequestID string `json:"request_id,omitempty"`
+	// Attempts counts the earlier deliveries of the entry, each a failed attempt to store it;
+	// set when the entry is claimed
+	Attempts int `json:"-"`
+}
+
+// WriteResult is the outcome of storing a queued message, reported to the writer that queued it.
+type WriteResult struct {
+	RequestID string          `json:"request_id"`
+	Message   *models.Message `json:"message,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// writeErrors are the errors a sender is told apart about after they crossed the queue.
+var writeErrors = []error{ErrInvalidReplyTo, ErrInvalidAttachment, ErrNotAllowed, ErrConversationNotFound, ErrEmptyContent}
+
+// Err returns the error of the result, or nil if the message was stored.
+func (r *WriteResult) Err() error {
+	if r.Error == "" {
+		return nil
+	}
+	for _, err := range writeErrors {
+		if err.Error() == r.Error {
+			return err
+		}
+	}
+	return errors.New(r.Error)
+}
+
+// MessageQueue is the durable write-ahead queue of messages waiting to be stored.
+// Each entry is delivered to one consumer of the writers' group and stays pending until acked;
+// an entry that failed for a transient reason is left pending and claimed again, in queue order.
+type MessageQueue interface {
+	// Add appends a message to the queue
+	Add(ctx context.Context, message *QueuedMessage) error
+
+	// Read returns up to count new entries for a consumer, waiting up to block for the first one
+	Read(ctx context.Context, consumer string, count int, block time.Duration) ([]*QueuedMessage, error)
+
+	// Claim takes over up to count entries left pending for longer than minIdle, e.g. by a writer that crashed
+	Claim(ctx context.Context, consumer string, minIdle time.Duration, count int) ([]*QueuedMessage, error)
+
+	// Ack marks entries as done
+	Ack(ctx context.Context, entryIDs ...string) error
+
+	// DeadLetter moves an entry that cannot be stored to the dead-letter stream
+	DeadLetter(ctx context.Context, message *QueuedMessage, reason string) error
+
+	// Stats returns the number of entries not read yet and of entries read but not acked
+	Stats(ctx context.Context) (lag, pending int64, err error)
+
+	// Trim drops the entries that have been acked and returns how many were dropped
+	Trim(ctx context.Context) (int64, error)
+
+	// PublishResult reports the result of a queued message to the writer that queued it
+	PublishResult(ctx context.Context, origin string, result *WriteResult) error
+
+	// SubscribeResults receives the results reported to a writer until ctx is done
+	SubscribeResults(ctx context.Context, origin string) (<-chan *WriteResult, error)
+}
@@ -1,8 +1,25 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"video-call/internal/chat"
+	"video-call/internal/models"
 	"video-call/pkg/cache/redis"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+const (
+	presencePrefix = "chat-presence:"
+	lastSeenPrefix = "chat-last-seen:"
+	// presenceExpiries scores each user with the time their last connection expires
+	presenceExpiries = "chat-presence-expiries"
 )
 
 // redisRepo implements the chat.RedisRepository interface.
@@ -14,3 +31,115 @@ type redisRepo struct {
 func NewRedisRepo(rdb redis.Client) chat.RedisRepository {
 	return &redisRepo{rdb: rdb}
 }
+
+// SetConnectionPresence implements chat.RedisRepository.
+// Each connection is a field of the user's presence hash holding "<status>|<expires unix>",
+// so a crashed node cannot keep a user online forever.
+func (r *redisRepo) SetConnectionPresence(ctx context.Context, userID, connectionID string, status models.PresenceStatus, ttl time.Duration) error {
+	key := presencePrefix + userID
+	expiresAt := time.Now().Add(ttl)
+	value := fmt.Sprintf("%s|%d", status, expiresAt.Unix())
+	if err := r.rdb.HSet(ctx, key, connectionID, value); err != nil {
+		return err
+	}
+	if err := r.rdb.Expire(ctx, key, ttl); err != nil {
+		return err
+	}
+	if err := r.rdb.ZAdd(ctx, presenceExpiries, float64(expiresAt.Unix()), userID); err != nil {
+		return err
+	}
+	return r.rdb.Set(ctx, lastSeenPrefix+userID, time.Now().Unix(), 0)
+}
+
+// RemoveConnectionPresence implements chat.RedisRepository.
+func (r *redisRepo) RemoveConnectionPresence(ctx context.Context, userID, connectionID string, lastSeen time.Time) error {
+	key := presencePrefix + userID
+	if err := r.rdb.HDel(ctx, key, connectionID); err != nil {
+		return err
+	}
+	// Once the last connection is gone there is nothing left to expire
+	connections, err := r.rdb.HGetAll(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(connections) == 0 {
+		if _, err := r.rdb.ZRem(ctx, presenceExpiries, userID); err != nil {
+			return err
+		}
+	}
+	return r.rdb.Set(ctx, lastSeenPrefix+userID, lastSeen.Unix(), 0)
+}
+
+// GetPresence implements chat.RedisRepository.
+func (r *redisRepo) GetPresence(ctx context.Context, userID string) (*models.UserPresence, error) {
+	key := presencePrefix + userID
+	connections, err := r.rdb.HGetAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	presence := &models.UserPresence{UserID: userID, Status: models.PresenceOffline}
+	now := time.Now().Unix()
+	var stale []string
+	for connectionID, value := range connections {
+		status, expiresAt, ok := parseConnectionPresence(value)
+		if !ok || expiresAt < now {
+			stale = append(stale, connectionID)
+			continue
+		}
+		switch {
+		case status == models.PresenceOnline:
+			presence.Status = models.PresenceOnline
+		case status == models.PresenceAway && presence.Status == models.PresenceOffline:
+			presence.Status = models.PresenceAway
+		}
+	}
+	if len(stale) > 0 {
+		if err := r.rdb.HDel(ctx, key, stale...); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := r.rdb.Get(ctx, lastSeenPrefix+userID)
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return nil, err
+	}
+	if sec, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+		lastSeen := time.Unix(sec, 0).UTC()
+		presence.LastSeen = &lastSeen
+	}
+
+	return presence, nil
+}
+
+// ClaimExpiredPresences implements chat.RedisRepository.
+// A user is claimed by removing them from the expiries; a connection refreshing later adds them again.
+func (r *redisRepo) ClaimExpiredPresences(ctx context.Context, at time.Time, limit int) ([]string, error) {
+	userIDs, err := r.rdb.ZRangeByScore(ctx, presenceExpiries, float64(at.Unix()), int64(limit))
+	if err != nil {
+		return nil, err
+	}
+	claimed := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		removed, err := r.rdb.ZRem(ctx, presenceExpiries, userID)
+		if err != nil {
+			return nil, err
+		}
+		if removed > 0 {
+			claimed = append(claimed, userID)
+		}
+	}
+	return claimed, nil
+}
+
+func parseConnectionPresence(value string) (models.PresenceStatus, int64, bool) {
+	status, expires, ok := strings.Cut(value, "|")
+	if !ok {
+		return "", 0, false
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return models.PresenceStatus(status), expiresAt, true
+}
@@ -2,22 +2,32 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 	"video-call/internal/chat"
 	"video-call/internal/models"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// messageInsertBatchSize is the number of rows per INSERT when storing messages in bulk.
+const messageInsertBatchSize = 100
+
 // repo implements the chat.Repository interface.
 type repo struct {
 	db *gorm.DB
+	// defaultRetention is the tenant default retention in seconds; 0 keeps messages forever
+	defaultRetention int64
 }
 
-// NewRepository is the constructor for repo.
-func NewRepository(db *gorm.DB) chat.Repository {
-	return &repo{db: db}
+// NewRepository is the constructor for repo. Conversations without a retention policy
+// keep their messages for defaultRetention, or forever if it is 0.
+func NewRepository(db *gorm.DB, defaultRetention time.Duration) chat.Repository {
+	return &repo{db: db, defaultRetention: int64(defaultRetention / time.Second)}
 }
 
 // CreateConversation implements chat.Repository.
@@ -28,13 +38,17 @@ func (r *repo) CreateConversation(ctx context.Context, conversation *models.Conv
 	if conversation.CreatedAt.IsZero() {
 		conversation.CreatedAt = time.Now()
 	}
-	return r.db.WithContext(ctx).Create(conversation).Error
+	return r.conn(ctx).Create(conversation).Error
 }
 
 // GetConversationByID implements chat.Repository.
 func (r *repo) GetConversationByID(ctx context.Context, conversationID string) (*models.Conversation, error) {
 	var conversation models.Conversation
-	if err := r.db.WithContext(ctx).First(&conversation, "id = ?", conversationID).Error; err != nil {
+	err := r.conn(ctx).First(&conversation, "id = ?", conversationID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, chat.ErrConversationNotFound
+	}
+	if err != nil {
 		return nil, err
 	}
 	return &conversation, nil
@@ -43,30 +57,352 @@ func (r *repo) GetConversationByID(ctx context.Context, conversationID string) (
 // GetConversationsByUserID implements chat.Repository.
 func (r *repo) GetConversationsByUserID(ctx context.Context, userID string) ([]*models.Conversation, error) {
 	var conversations []*models.Conversation
-	if err := r.db.WithContext(ctx).
+	if err := r.conn(ctx).
 		Joins("JOIN conversation_participants cp ON cp.conversation_id = conversations.id").
 		Where("cp.user_id = ?", userID).
-		Order("conversations.updated_at DESC").
+		Order("conversations.created_at DESC").
 		Find(&conversations).Error; err != nil {
 		return nil, err
 	}
 	return conversations, nil
 }
 
+// conversationSummaryRow is a row of the conversation list query.
+type conversationSummaryRow struct {
+	models.Conversation
+	Role              models.ParticipantRole
+	LastReadMessageID *string
+	LastReadAt        *time.Time
+	MutedUntil        *time.Time
+	PinnedAt          *time.Time
+	ArchivedAt        *time.Time
+	UnreadCount       int
+
+	LastMessageID        *string
+	LastMessageSenderID  *string
+	LastMessageSnippet   string
+	LastMessageType      models.MessageType
+	LastMessageCreatedAt *time.Time
+	LastMessageDeletedAt *time.Time
+}
+
+// GetConversationSummaries implements chat.Repository.
+// The last message and the capped unread count come from lateral subqueries
+// that walk the (conversation_id, created_at, id) index, so the list is one query.
+// Without a read position, the messages since the user joined are unread.
+func (r *repo) GetConversationSummaries(ctx context.Context, userID string, filter chat.ConversationFilter) ([]*chat.ConversationSummary, error) {
+	archived := "cp.archived_at IS NULL"
+	if filter.Archived {
+		archived = "cp.archived_at IS NOT NULL"
+	}
+
+	now := time.Now()
+	var rows []*conversationSummaryRow
+	err := r.conn(ctx).Raw(`
+		SELECT c.*, cp.role, cp.last_read_message_id, cp.last_read_at,
+			cp.muted_until, cp.pinned_at, cp.archived_at,
+			unread.count AS unread_count,
+			lm.id AS last_message_id, lm.sender_id AS last_message_sender_id,
+			CASE WHEN lm.deleted_at IS NULL THEN LEFT(COALESCE(lm.content, ''), ?) ELSE '' END AS last_message_snippet,
+			COALESCE(lm.message_type, '') AS last_message_type, lm.created_at AS last_message_created_at,
+			lm.deleted_at AS last_message_deleted_at
+		FROM conversation_participants cp
+		JOIN conversations c ON c.id = cp.conversation_id
+		LEFT JOIN messages lr ON lr.id = cp.last_read_message_id
+		LEFT JOIN LATERAL (
+			SELECT m.id, m.sender_id, m.content, m.message_type, m.created_at, m.deleted_at
+			FROM messages m
+			WHERE m.conversation_id = c.id AND ?
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT 1
+		) lm ON TRUE
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS count FROM (
+				SELECT 1
+				FROM messages m
+				WHERE m.conversation_id = c.id
+					AND m.deleted_at IS NULL
+					AND ?
+					AND m.sender_id IS DISTINCT FROM cp.user_id
+					AND CASE WHEN lr.id IS NULL THEN m.created_at > cp.joined_at
+						ELSE (m.created_at, m.id) > (lr.created_at, lr.id) END
+				LIMIT ?
+			) capped
+		) unread
+		WHERE cp.user_id = ? AND `+archived+`
+		ORDER BY cp.pinned_at IS NULL, COALESCE(lm.created_at, c.created_at) DESC, c.id DESC`,
+		chat.PreviewLength, r.retained("m", "c", now), r.retained("m", "c", now), chat.MaxUnreadCount, userID,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]*chat.ConversationSummary, len(rows))
+	for i, row := range rows {
+		conversation := row.Conversation
+		summary := &chat.ConversationSummary{
+			Conversation:      &conversation,
+			Role:              row.Role,
+			UnreadCount:       row.UnreadCount,
+			LastReadMessageID: row.LastReadMessageID,
+			LastReadAt:        row.LastReadAt,
+			MutedUntil:        row.MutedUntil,
+			PinnedAt:          row.PinnedAt,
+			ArchivedAt:        row.ArchivedAt,
+		}
+		if row.LastMessageID != nil {
+			summary.LastMessage = &chat.MessagePreview{
+				ID:          *row.LastMessageID,
+				Snippet:     row.LastMessageSnippet,
+				MessageType: row.LastMessageType,
+				CreatedAt:   *row.LastMessageCreatedAt,
+				Deleted:     row.LastMessageDeletedAt != nil,
+			}
+			if row.LastMessageSenderID != nil {
+				summary.LastMessage.SenderID = *row.LastMessageSenderID
+			}
+		}
+		summaries[i] = summary
+	}
+	return summaries, nil
+}
+
+// UpdateParticipantSettings implements chat.Repository.
+// Pinning or archiving again keeps the original time.
+func (r *repo) UpdateParticipantSettings(ctx context.Context, conversationID, userID string, update *chat.SettingsUpdate, now time.Time) (*models.ConversationParticipant, error) {
+	changes := map[string]any{}
+	if update.Muted != nil {
+		if *update.Muted {
+			changes["muted_until"] = update.MutedUntil
+		} else {
+			changes["muted_until"] = nil
+		}
+	}
+	if update.Pinned != nil {
+		if *update.Pinned {
+			changes["pinned_at"] = gorm.Expr("COALESCE(pinned_at, ?)", now)
+		} else {
+			changes["pinned_at"] = nil
+		}
+	}
+	if update.Archived != nil {
+		if *update.Archived {
+			changes["archived_at"] = gorm.Expr("COALESCE(archived_at, ?)", now)
+		} else {
+			changes["archived_at"] = nil
+		}
+	}
+
+	var participant models.ConversationParticipant
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(changes) > 0 {
+			result := tx.Model(&models.ConversationParticipant{}).
+				Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+				Updates(changes)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		err := tx.First(&participant, "conversation_id = ? AND user_id = ?", conversationID, userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return chat.ErrParticipantNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &participant, nil
+}
+
+// GetNotificationRecipients implements chat.Repository.
+func (r *repo) GetNotificationRecipients(ctx context.Context, conversationID, senderID string, at time.Time) ([]string, error) {
+	var userIDs []string
+	if err := r.conn(ctx).
+		Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id IS DISTINCT FROM ?", conversationID, senderID).
+		Where("muted_until IS NULL OR muted_until <= ?", at).
+		Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+// MarkConversationRead implements chat.Repository.
+func (r *repo) MarkConversationRead(ctx context.Context, conversationID, userID, messageID string, readAt time.Time) (bool, []*models.Message, error) {
+	var advanced bool
+	var read []*models.Message
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		var message models.Message
+		err := tx.Select("id", "seq").
+			First(&message, "id = ? AND conversation_id = ?", messageID, conversationID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return chat.ErrMessageNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		result := tx.Exec(`
+			UPDATE conversation_participants cp
+			SET last_read_message_id = ?, last_read_at = ?
+			WHERE cp.conversation_id = ? AND cp.user_id = ?
+				AND NOT EXISTS (
+					SELECT 1 FROM messages lr
+					WHERE lr.id = cp.last_read_message_id AND lr.seq >= ?
+				)`,
+			message.ID, readAt, conversationID, userID, message.Seq,
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		advanced = true
+
+		return tx.Raw(`
+			UPDATE message_status ms
+			SET status = ?, updated_at = ?
+			FROM messages m
+			WHERE ms.message_id = m.id AND ms.user_id = ? AND ms.status IS DISTINCT FROM ?
+				AND m.conversation_id = ? AND m.seq <= ?
+			RETURNING m.id, m.sender_id`,
+			models.MessageStatusRead, readAt, userID, models.MessageStatusRead,
+			conversationID, message.Seq,
+		).Scan(&read).Error
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return advanced, read, nil
+}
+
 // UpdateConversation implements chat.Repository.
 func (r *repo) UpdateConversation(ctx context.Context, conversation *models.Conversation) error {
-	return r.db.WithContext(ctx).Save(conversation).Error
+	// Only the name is editable; the retention has its own update
+	return r.conn(ctx).Model(conversation).Select("name").Updates(conversation).Error
 }
 
 // DeleteConversation implements chat.Repository.
 func (r *repo) DeleteConversation(ctx context.Context, conversationID string) error {
-	return r.db.WithContext(ctx).Delete(&models.Conversation{}, "id = ?", conversationID).Error
+	return r.conn(ctx).Delete(&models.Conversation{}, "id = ?", conversationID).Error
+}
+
+// CreateConversationWithParticipants implements chat.Repository.
+func (r *repo) CreateConversationWithParticipants(ctx context.Context, conversation *models.Conversation, participants []*models.ConversationParticipant) error {
+	if conversation.ID == "" {
+		conversation.ID = uuid.New().String()
+	}
+	if conversation.CreatedAt.IsZero() {
+		conversation.CreatedAt = time.Now()
+	}
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(conversation).Error; err != nil {
+			return err
+		}
+		if len(participants) == 0 {
+			return nil
+		}
+		userIDs := make([]string, len(participants))
+		for i, participant := range participants {
+			participant.ConversationID = conversation.ID
+			if participant.JoinedAt.IsZero() {
+				participant.JoinedAt = conversation.CreatedAt
+			}
+			userIDs[i] = participant.UserID
+		}
+		if err := checkUsersExist(tx, userIDs); err != nil {
+			return err
+		}
+		return tx.Create(&participants).Error
+	})
+}
+
+// checkUsersExist returns chat.ErrUserNotFound unless every user ID belongs to a registered user.
+func checkUsersExist(tx *gorm.DB, userIDs []string) error {
+	unique := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		unique[userID] = true
+	}
+	var count int64
+	if err := tx.Model(&models.User{}).Where("id IN ?", userIDs).Count(&count).Error; err != nil {
+		return err
+	}
+	if count != int64(len(unique)) {
+		return chat.ErrUserNotFound
+	}
+	return nil
+}
+
+// FindDirectConversation implements chat.Repository.
+func (r *repo) FindDirectConversation(ctx context.Context, userA, userB string) (*models.Conversation, error) {
+	return findDirectConversation(r.conn(ctx), models.DirectConversationKey(userA, userB))
+}
+
+func findDirectConversation(db *gorm.DB, directKey string) (*models.Conversation, error) {
+	var conversation models.Conversation
+	err := db.First(&conversation, "direct_key = ?", directKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, chat.ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// CreateDirectConversation implements chat.Repository.
+// The insert skips on a conflicting direct key; under read committed it waits for a concurrent
+// insert of the same pair to commit, after which the next statement sees the winning row.
+func (r *repo) CreateDirectConversation(ctx context.Context, conversation *models.Conversation, userA, userB string) (*models.Conversation, bool, error) {
+	if conversation.ID == "" {
+		conversation.ID = uuid.New().String()
+	}
+	if conversation.CreatedAt.IsZero() {
+		conversation.CreatedAt = time.Now()
+	}
+	directKey := models.DirectConversationKey(userA, userB)
+	conversation.IsGroup = false
+	conversation.DirectKey = &directKey
+
+	var existing *models.Conversation
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkUsersExist(tx, []string{userA, userB}); err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "direct_key"}},
+			DoNothing: true,
+		}).Create(conversation)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var err error
+			existing, err = findDirectConversation(tx, directKey)
+			return err
+		}
+
+		participants := []*models.ConversationParticipant{
+			{ConversationID: conversation.ID, UserID: userA, Role: models.ParticipantRoleMember, JoinedAt: conversation.CreatedAt},
+			{ConversationID: conversation.ID, UserID: userB, Role: models.ParticipantRoleMember, JoinedAt: conversation.CreatedAt},
+		}
+		return tx.Create(&participants).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+	return conversation, true, nil
 }
 
 // IsUserInConversation implements chat.Repository.
 func (r *repo) IsUserInConversation(ctx context.Context, userID, conversationID string) (bool, error) {
 	var count int64
-	err := r.db.WithContext(ctx).
+	err := r.conn(ctx).
 		Model(&models.ConversationParticipant{}).
 		Where("user_id = ? AND conversation_id = ?", userID, conversationID).
 		Count(&count).Error
@@ -76,30 +412,822 @@ func (r *repo) IsUserInConversation(ctx context.Context, userID, conversationID
 	return count > 0, nil
 }
 
-func (r *repo) CreateMessage(ctx context.Context, message models.Message) error {
+// GetContactIDs implements chat.Repository.
+func (r *repo) GetContactIDs(ctx context.Context, userID string, userIDs []string) ([]string, error) {
+	var contacts []string
+	if len(userIDs) == 0 {
+		return contacts, nil
+	}
+	err := r.conn(ctx).Raw(`
+		SELECT DISTINCT other.user_id
+		FROM conversation_participants me
+		JOIN conversation_participants other ON other.conversation_id = me.conversation_id
+		WHERE me.user_id = ? AND other.user_id IN ?`,
+		userID, userIDs,
+	).Scan(&contacts).Error
+	if err != nil {
+		return nil, err
+	}
+	return contacts, nil
+}
+
+// GetParticipant implements chat.Repository.
+func (r *repo) GetParticipant(ctx context.Context, conversationID, userID string) (*models.ConversationParticipant, error) {
+	var participant models.ConversationParticipant
+	err := r.conn(ctx).
+		First(&participant, "conversation_id = ? AND user_id = ?", conversationID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, chat.ErrParticipantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &participant, nil
+}
+
+// GetParticipants implements chat.Repository.
+func (r *repo) GetParticipants(ctx context.Context, conversationID string) ([]*models.ConversationParticipant, error) {
+	var participants []*models.ConversationParticipant
+	if err := r.conn(ctx).
+		Where("conversation_id = ?", conversationID).
+		Order("joined_at ASC, user_id ASC").
+		Find(&participants).Error; err != nil {
+		return nil, err
+	}
+	return participants, nil
+}
+
+// AddParticipants implements chat.Repository.
+func (r *repo) AddParticipants(ctx context.Context, conversationID string, userIDs []string, role models.ParticipantRole) ([]*models.ConversationParticipant, error) {
+	var added []*models.ConversationParticipant
+	if len(userIDs) == 0 {
+		return added, nil
+	}
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkUsersExist(tx, userIDs); err != nil {
+			return err
+		}
+		return tx.Raw(`
+			INSERT INTO conversation_participants (conversation_id, user_id, role, joined_at)
+			SELECT ?, u.id, ?, ?
+			FROM users u
+			WHERE u.id IN ?
+			ON CONFLICT (conversation_id, user_id) DO NOTHING
+			RETURNING conversation_id, user_id, role, joined_at`,
+			conversationID, role, time.Now(), userIDs,
+		).Scan(&added).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// RemoveParticipant implements chat.Repository.
+func (r *repo) RemoveParticipant(ctx context.Context, conversationID, userID string) (bool, error) {
+	result := r.conn(ctx).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Delete(&models.ConversationParticipant{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateParticipantRole implements chat.Repository.
+func (r *repo) UpdateParticipantRole(ctx context.Context, conversationID, userID string, role models.ParticipantRole) error {
+	result := r.conn(ctx).
+		Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ? AND role <> ?", conversationID, userID, models.ParticipantRoleOwner).
+		Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return chat.ErrParticipantNotFound
+	}
+	return nil
+}
+
+// TransferOwnership implements chat.Repository.
+// The current owner is demoted first so that the single owner index holds at every step.
+func (r *repo) TransferOwnership(ctx context.Context, conversationID, fromUserID, toUserID string) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ? AND role = ?", conversationID, fromUserID, models.ParticipantRoleOwner).
+			Update("role", models.ParticipantRoleAdmin)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return chat.ErrParticipantNotFound
+		}
+
+		result = tx.Model(&models.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ?", conversationID, toUserID).
+			Update("role", models.ParticipantRoleOwner)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return chat.ErrParticipantNotFound
+		}
+		return nil
+	})
+}
+
+// CreateMessage implements chat.Repository.
+func (r *repo) CreateMessage(ctx context.Context, message *models.Message) error {
 	if message.ID == "" {
 		message.ID = uuid.New().String()
 	}
 	if message.CreatedAt.IsZero() {
 		message.CreatedAt = time.Now()
 	}
-	return r.db.WithContext(ctx).Create(&message).Error
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		// The conversation row stays locked until commit, so sequence numbers are handed out in commit order
+		var seq int64
+		err := tx.Raw(`UPDATE conversations SET last_seq = last_seq + 1 WHERE id = ? RETURNING last_seq`, message.ConversationID).
+			Scan(&seq).Error
+		if err != nil {
+			return err
+		}
+		if seq == 0 {
+			return chat.ErrConversationNotFound
+		}
+		message.Seq = seq
+
+		result := tx.Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "sender_id"}, {Name: "client_message_id"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "client_message_id IS NOT NULL"}}},
+			DoNothing:   true,
+		}).Create(message)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return chat.ErrDuplicateMessage
+		}
+		if err := linkAttachments(tx, message); err != nil {
+			return err
+		}
+		return tx.Exec(`
+			INSERT INTO message_status (message_id, user_id, status, updated_at)
+			SELECT ?, cp.user_id, ?, ?
+			FROM conversation_participants cp
+			WHERE cp.conversation_id = ? AND cp.user_id IS DISTINCT FROM ?
+			ON CONFLICT (message_id, user_id) DO NOTHING`,
+			message.ID, models.MessageStatusSent, message.CreatedAt, message.ConversationID, message.SenderID,
+		).Error
+	})
 }
 
-// GetMessages implements chat.Repository.
-func (r *repo) GetMessages(ctx context.Context, conversationID string, limit, offset int) ([]*models.Message, error) {
-	var messages []*models.Message
+// CreateMessages implements chat.Repository.
+func (r *repo) CreateMessages(ctx context.Context, messages []*models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
 
-	err := r.db.WithContext(ctx).
-		Where("conversation_id = ?", conversationID).
-		Order("created_at DESC").
+	byConversation := make(map[string][]*models.Message)
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		byConversation[message.ConversationID] = append(byConversation[message.ConversationID], message)
+		ids[i] = message.ID
+	}
+	// Conversations are locked in the same order by every writer so that batches cannot deadlock
+	conversationIDs := make([]string, 0, len(byConversation))
+	for conversationID := range byConversation {
+		conversationIDs = append(conversationIDs, conversationID)
+	}
+	sort.Strings(conversationIDs)
+
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, conversationID := range conversationIDs {
+			batch := byConversation[conversationID]
+			var lastSeq int64
+			err := tx.Raw(`UPDATE conversations SET last_seq = last_seq + ? WHERE id = ? RETURNING last_seq`, len(batch), conversationID).
+				Scan(&lastSeq).Error
+			if err != nil {
+				return err
+			}
+			if lastSeq == 0 {
+				return chat.ErrConversationNotFound
+			}
+			firstSeq := lastSeq - int64(len(batch)) + 1
+			for i, message := range batch {
+				message.Seq = firstSeq + int64(i)
+			}
+		}
+
+		if err := tx.CreateInBatches(messages, messageInsertBatchSize).Error; err != nil {
+			return err
+		}
+		for _, message := range messages {
+			if err := linkAttachments(tx, message); err != nil {
+				return err
+			}
+		}
+		return tx.Exec(`
+			INSERT INTO message_status (message_id, user_id, status, updated_at)
+			SELECT m.id, cp.user_id, ?, m.created_at
+			FROM messages m
+			JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id IS DISTINCT FROM m.sender_id
+			WHERE m.id IN ?
+			ON CONFLICT (message_id, user_id) DO NOTHING`,
+			models.MessageStatusSent, ids,
+		).Error
+	})
+}
+
+// GetMessagesAfterSeq implements chat.Repository.
+func (r *repo) GetMessagesAfterSeq(ctx context.Context, conversationID string, afterSeq int64, limit int) ([]*models.Message, error) {
+	var messages []*models.Message
+	err := r.conn(ctx).
+		Where("conversation_id = ? AND seq > ?", conversationID, afterSeq).
+		Where(r.unexpired("messages")).
+		Order("seq ASC").
 		Limit(limit).
-		Offset(offset).
 		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// linkAttachments links the attachments of a message that are still unshared.
+func linkAttachments(tx *gorm.DB, message *models.Message) error {
+	if len(message.Attachments) == 0 {
+		return nil
+	}
+	ids := make([]string, len(message.Attachments))
+	for i, attachment := range message.Attachments {
+		ids[i] = attachment.ID
+	}
+	result := tx.Model(&models.Attachment{}).
+		Where("id IN ? AND conversation_id = ? AND uploader_id = ? AND message_id IS NULL", ids, message.ConversationID, message.SenderID).
+		Update("message_id", message.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(ids)) {
+		return chat.ErrInvalidAttachment
+	}
+	return nil
+}
+
+// GetMessageByClientID implements chat.Repository.
+func (r *repo) GetMessageByClientID(ctx context.Context, senderID, clientMessageID string) (*models.Message, error) {
+	var message models.Message
+	err := r.conn(ctx).
+		First(&message, "sender_id = ? AND client_message_id = ?", senderID, clientMessageID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, chat.ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// GetMessageByID implements chat.Repository.
+func (r *repo) GetMessageByID(ctx context.Context, messageID string) (*models.Message, error) {
+	var message models.Message
+	err := r.conn(ctx).Where(r.unexpired("messages")).First(&message, "id = ?", messageID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, chat.ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
 
+// UpsertMessageStatus implements chat.Repository.
+// Only participants other than the sender get a status row, and the
+// status is only ever promoted (sent -> delivered -> read).
+func (r *repo) UpsertMessageStatus(ctx context.Context, messageID, userID string, status models.MessageStatusType) (bool, error) {
+	tx := r.conn(ctx).Exec(`
+		INSERT INTO message_status (message_id, user_id, status, updated_at)
+		SELECT m.id, cp.user_id, ?, ?
+		FROM messages m
+		JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = ?
+		WHERE m.id = ? AND m.sender_id IS DISTINCT FROM cp.user_id
+		ON CONFLICT (message_id, user_id) DO UPDATE
+		SET status = EXCLUDED.status, updated_at = EXCLUDED.updated_at
+		WHERE `+statusRank("message_status.status")+` < `+statusRank("EXCLUDED.status"),
+		status, time.Now(), userID, messageID,
+	)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// UpsertMessageStatuses implements chat.Repository.
+func (r *repo) UpsertMessageStatuses(ctx context.Context, messageIDs []string, userID string, status models.MessageStatusType) ([]string, error) {
+	var changed []string
+	if len(messageIDs) == 0 {
+		return changed, nil
+	}
+	err := r.conn(ctx).Raw(`
+		INSERT INTO message_status (message_id, user_id, status, updated_at)
+		SELECT m.id, cp.user_id, ?, ?
+		FROM messages m
+		JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = ?
+		WHERE m.id IN ? AND m.sender_id IS DISTINCT FROM cp.user_id
+		ON CONFLICT (message_id, user_id) DO UPDATE
+		SET status = EXCLUDED.status, updated_at = EXCLUDED.updated_at
+		WHERE `+statusRank("message_status.status")+` < `+statusRank("EXCLUDED.status")+`
+		RETURNING message_id`,
+		status, time.Now(), userID, messageIDs,
+	).Scan(&changed).Error
 	if err != nil {
 		return nil, err
 	}
+	return changed, nil
+}
+
+// GetMessageStatuses implements chat.Repository.
+func (r *repo) GetMessageStatuses(ctx context.Context, messageID string) ([]*models.MessageStatus, error) {
+	var statuses []*models.MessageStatus
+	if err := r.conn(ctx).
+		Where("message_id = ?", messageID).
+		Order("updated_at ASC").
+		Find(&statuses).Error; err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// GetMessagesByIDs implements chat.Repository.
+func (r *repo) GetMessagesByIDs(ctx context.Context, messageIDs []string) ([]*models.Message, error) {
+	var messages []*models.Message
+	if len(messageIDs) == 0 {
+		return messages, nil
+	}
+	if err := r.conn(ctx).Where("id IN ?", messageIDs).Where(r.unexpired("messages")).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// GetThreadSummaries implements chat.Repository.
+func (r *repo) GetThreadSummaries(ctx context.Context, messageIDs []string) ([]*models.ThreadSummary, error) {
+	var summaries []*models.ThreadSummary
+	if len(messageIDs) == 0 {
+		return summaries, nil
+	}
+	if err := r.conn(ctx).
+		Model(&models.Message{}).
+		Select("reply_to_id AS message_id, COUNT(*) AS reply_count, MAX(created_at) AS last_reply_at").
+		Where("reply_to_id IN ? AND deleted_at IS NULL", messageIDs).
+		Group("reply_to_id").
+		Scan(&summaries).Error; err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// GetReplies implements chat.Repository.
+func (r *repo) GetReplies(ctx context.Context, messageID string, cursor *chat.MessageCursor, limit int) ([]*models.Message, error) {
+	var messages []*models.Message
+
+	query := r.conn(ctx).Where("reply_to_id = ?", messageID).Where(r.unexpired("messages"))
+	if cursor != nil {
+		query = query.Where("(created_at, id) > (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	if err := query.
+		Order("created_at ASC, id ASC").
+		Limit(limit).
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// UpdateMessageContent implements chat.Repository.
+func (r *repo) UpdateMessageContent(ctx context.Context, messageID, content, editedBy string, editedAt time.Time) (*models.Message, error) {
+	var message models.Message
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&message, "id = ?", messageID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return chat.ErrMessageNotFound
+			}
+			return err
+		}
+		if message.IsDeleted() {
+			return chat.ErrMessageDeleted
+		}
+
+		revision := models.MessageRevision{
+			ID:        uuid.New().String(),
+			MessageID: messageID,
+			Content:   message.Content,
+			EditedBy:  editedBy,
+			CreatedAt: editedAt,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+
+		message.Content = content
+		message.EditedAt = &editedAt
+		return tx.Model(&message).Updates(map[string]interface{}{
+			"content":   content,
+			"edited_at": editedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// SoftDeleteMessage implements chat.Repository.
+func (r *repo) SoftDeleteMessage(ctx context.Context, messageID string, deletedAt time.Time) (*models.Message, error) {
+	var message models.Message
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&message, "id = ?", messageID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return chat.ErrMessageNotFound
+			}
+			return err
+		}
+		if message.IsDeleted() {
+			return chat.ErrMessageDeleted
+		}
+
+		message.Content = ""
+		message.Metadata = nil
+		message.DeletedAt = &deletedAt
+		if err := tx.Model(&message).Updates(map[string]interface{}{
+			"content":    "",
+			"metadata":   gorm.Expr("NULL"),
+			"deleted_at": deletedAt,
+		}).Error; err != nil {
+			return err
+		}
+		// Earlier revisions would still expose the deleted content.
+		return tx.Where("message_id = ?", messageID).Delete(&models.MessageRevision{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// GetMessageRevisions implements chat.Repository.
+func (r *repo) GetMessageRevisions(ctx context.Context, messageID string) ([]*models.MessageRevision, error) {
+	var revisions []*models.MessageRevision
+	if err := r.conn(ctx).
+		Where("message_id = ?", messageID).
+		Order("created_at ASC").
+		Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// CreateAttachment implements chat.Repository.
+func (r *repo) CreateAttachment(ctx context.Context, attachment *models.Attachment) error {
+	return r.conn(ctx).Create(attachment).Error
+}
+
+// GetAttachmentByID implements chat.Repository.
+func (r *repo) GetAttachmentByID(ctx context.Context, attachmentID string) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := r.conn(ctx).First(&attachment, "id = ?", attachmentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, chat.ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// UpdateAttachmentMedia implements chat.Repository.
+func (r *repo) UpdateAttachmentMedia(ctx context.Context, attachment *models.Attachment) error {
+	return r.conn(ctx).Model(attachment).Updates(map[string]interface{}{
+		"size_bytes":   attachment.SizeBytes,
+		"width":        attachment.Width,
+		"height":       attachment.Height,
+		"duration_ms":  attachment.DurationMs,
+		"blurhash":     attachment.BlurHash,
+		"variants":     attachment.Variants,
+		"processed_at": attachment.ProcessedAt,
+	}).Error
+}
+
+// ClaimUnprocessedAttachments implements chat.Repository.
+func (r *repo) ClaimUnprocessedAttachments(ctx context.Context, typePrefixes []string, retryBefore time.Time, limit int) ([]*models.Attachment, error) {
+	var attachments []*models.Attachment
+	if len(typePrefixes) == 0 {
+		return attachments, nil
+	}
+	patterns := make([]string, len(typePrefixes))
+	for i, prefix := range typePrefixes {
+		patterns[i] = prefix + "%"
+	}
+	err := r.conn(ctx).Raw(`
+		UPDATE attachments
+		SET media_attempts = media_attempts + 1, media_attempted_at = ?
+		WHERE id IN (
+			SELECT id
+			FROM attachments
+			WHERE processed_at IS NULL AND media_failed_at IS NULL AND message_id IS NOT NULL
+				AND content_type LIKE ANY (ARRAY[?])
+				AND (media_attempted_at IS NULL OR media_attempted_at < ?)
+			ORDER BY created_at ASC
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		time.Now(), patterns, retryBefore, limit,
+	).Scan(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// FailAttachmentMedia implements chat.Repository.
+func (r *repo) FailAttachmentMedia(ctx context.Context, attachmentID string, failedAt time.Time) error {
+	return r.conn(ctx).Model(&models.Attachment{}).Where("id = ?", attachmentID).Update("media_failed_at", failedAt).Error
+}
+
+// MergeMessageMetadata implements chat.Repository.
+func (r *repo) MergeMessageMetadata(ctx context.Context, messageID string, metadata datatypes.JSON) (*models.Message, error) {
+	tx := r.conn(ctx).Exec(
+		"UPDATE messages SET metadata = COALESCE(metadata, '{}'::jsonb) || ?::jsonb WHERE id = ? AND deleted_at IS NULL",
+		string(metadata), messageID,
+	)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	message, err := r.GetMessageByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if tx.RowsAffected == 0 {
+		return nil, chat.ErrMessageDeleted
+	}
+	return message, nil
+}
+
+// GetAttachmentsByIDs implements chat.Repository.
+func (r *repo) GetAttachmentsByIDs(ctx context.Context, attachmentIDs []string) ([]*models.Attachment, error) {
+	var attachments []*models.Attachment
+	if len(attachmentIDs) == 0 {
+		return attachments, nil
+	}
+	if err := r.conn(ctx).Where("id IN ?", attachmentIDs).Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// GetAttachmentsByMessageIDs implements chat.Repository.
+func (r *repo) GetAttachmentsByMessageIDs(ctx context.Context, messageIDs []string) ([]*models.Attachment, error) {
+	var attachments []*models.Attachment
+	if len(messageIDs) == 0 {
+		return attachments, nil
+	}
+	if err := r.conn(ctx).
+		Where("message_id IN ?", messageIDs).
+		Order("created_at ASC, id ASC").
+		Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// AddReaction implements chat.Repository.
+func (r *repo) AddReaction(ctx context.Context, reaction *models.MessageReaction) (bool, error) {
+	if reaction.CreatedAt.IsZero() {
+		reaction.CreatedAt = time.Now()
+	}
+	tx := r.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(reaction)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// RemoveReaction implements chat.Repository.
+func (r *repo) RemoveReaction(ctx context.Context, messageID, userID, emoji string) (bool, error) {
+	tx := r.conn(ctx).
+		Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
+		Delete(&models.MessageReaction{})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// GetReactionCounts implements chat.Repository.
+func (r *repo) GetReactionCounts(ctx context.Context, messageIDs []string, userID string) ([]*models.ReactionCount, error) {
+	var counts []*models.ReactionCount
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+	if err := r.conn(ctx).
+		Model(&models.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, BOOL_OR(user_id = ?) AS reacted", userID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("MIN(created_at) ASC").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// searchConfig is the text search configuration of messages.content_tsv.
+const searchConfig = "simple"
+
+// searchRow is a messages row extended with the search relevance and snippet.
+type searchRow struct {
+	models.Message
+	Rank    float64
+	Snippet string
+}
+
+// PinMessage implements chat.Repository.
+// The conversation row is locked so that concurrent pins cannot exceed the maximum together.
+func (r *repo) PinMessage(ctx context.Context, pin *models.PinnedMessage, max int) (*models.PinnedMessage, bool, error) {
+	current := pin
+	pinned := false
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		var conversation models.Conversation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&conversation, "id = ?", pin.ConversationID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return chat.ErrConversationNotFound
+			}
+			return err
+		}
+
+		var existing models.PinnedMessage
+		err := tx.First(&existing, "message_id = ?", pin.MessageID).Error
+		if err == nil {
+			current = &existing
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.PinnedMessage{}).
+			Where("conversation_id = ?", pin.ConversationID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(max) {
+			return chat.ErrTooManyPins
+		}
+		if err := tx.Create(pin).Error; err != nil {
+			return err
+		}
+		pinned = true
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return current, pinned, nil
+}
+
+// UnpinMessage implements chat.Repository.
+func (r *repo) UnpinMessage(ctx context.Context, messageID string) (bool, error) {
+	result := r.conn(ctx).Where("message_id = ?", messageID).Delete(&models.PinnedMessage{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetPinnedMessages implements chat.Repository.
+func (r *repo) GetPinnedMessages(ctx context.Context, conversationID string) ([]*models.PinnedMessage, error) {
+	var pins []*models.PinnedMessage
+	if err := r.conn(ctx).
+		Where("conversation_id = ?", conversationID).
+		Order("pinned_at DESC, message_id DESC").
+		Find(&pins).Error; err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+// CreateMentions implements chat.Repository.
+func (r *repo) CreateMentions(ctx context.Context, mentions []*models.MessageMention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	return r.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&mentions).Error
+}
+
+// GetMentions implements chat.Repository.
+func (r *repo) GetMentions(ctx context.Context, userID string, cursor *chat.MentionCursor, limit int) ([]*models.MessageMention, error) {
+	query := r.conn(ctx).
+		Table("message_mentions AS mm").
+		Select("mm.*").
+		Joins("JOIN messages m ON m.id = mm.message_id AND m.deleted_at IS NULL").
+		Joins("JOIN conversation_participants cp ON cp.conversation_id = mm.conversation_id AND cp.user_id = mm.user_id").
+		Where("mm.user_id = ?", userID).
+		Where(r.unexpired("m"))
+	if cursor != nil {
+		query = query.Where("(mm.created_at, mm.message_id) < (?, ?)", cursor.CreatedAt, cursor.MessageID)
+	}
+
+	var mentions []*models.MessageMention
+	if err := query.
+		Order("mm.created_at DESC, mm.message_id DESC").
+		Limit(limit).
+		Find(&mentions).Error; err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
+
+// SearchMessages implements chat.Repository.
+func (r *repo) SearchMessages(ctx context.Context, query *chat.SearchQuery, cursor *chat.SearchCursor, limit int) ([]*chat.SearchHit, error) {
+	// The rank is widened to float8 so that it survives a round trip through the cursor.
+	rank := "ts_rank_cd(messages.content_tsv, q.query)::float8"
+
+	db := r.conn(ctx).
+		Table("messages, websearch_to_tsquery(?, ?) AS q(query)", searchConfig, query.Text).
+		Select("messages.*, "+rank+" AS rank, "+
+			"ts_headline(?, replace(replace(replace(messages.content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), q.query, "+
+			"'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5') AS snippet", searchConfig).
+		Where("messages.content_tsv @@ q.query").
+		Where("messages.deleted_at IS NULL").
+		Where(r.unexpired("messages")).
+		Where("EXISTS (SELECT 1 FROM conversation_participants cp WHERE cp.conversation_id = messages.conversation_id AND cp.user_id = ?)", query.UserID)
+
+	if query.ConversationID != "" {
+		db = db.Where("messages.conversation_id = ?", query.ConversationID)
+	}
+	if query.SenderID != "" {
+		db = db.Where("messages.sender_id = ?", query.SenderID)
+	}
+	if query.MessageType != "" {
+		db = db.Where("messages.message_type = ?", query.MessageType)
+	}
+	if query.From != nil {
+		db = db.Where("messages.created_at >= ?", *query.From)
+	}
+	if query.To != nil {
+		db = db.Where("messages.created_at < ?", *query.To)
+	}
+	if cursor != nil {
+		db = db.Where("("+rank+", messages.created_at, messages.id) < (?, ?, ?)", cursor.Rank, cursor.CreatedAt, cursor.ID)
+	}
+
+	var rows []*searchRow
+	if err := db.
+		Order("rank DESC, messages.created_at DESC, messages.id DESC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	hits := make([]*chat.SearchHit, len(rows))
+	for i, row := range rows {
+		message := row.Message
+		hits[i] = &chat.SearchHit{Message: &message, Rank: row.Rank, Snippet: row.Snippet}
+	}
+	return hits, nil
+}
+
+// statusRank orders message statuses so that they can only be promoted.
+func statusRank(column string) string {
+	return "CASE " + column + " WHEN 'read' THEN 2 WHEN 'delivered' THEN 1 ELSE 0 END"
+}
+
+// GetMessages implements chat.Repository.
+func (r *repo) GetMessages(ctx context.Context, conversationID string, cursor *chat.MessageCursor, limit int) ([]*models.Message, error) {
+	var messages []*models.Message
+
+	query := r.conn(ctx).Where("conversation_id = ?", conversationID).Where(r.unexpired("messages"))
+	switch {
+	case cursor == nil:
+		query = query.Order("created_at DESC, id DESC")
+	case cursor.Direction == chat.CursorAfter:
+		query = query.
+			Where("(created_at, id) > (?, ?)", cursor.CreatedAt, cursor.ID).
+			Order("created_at ASC, id ASC")
+	default:
+		query = query.
+			Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID).
+			Order("created_at DESC, id DESC")
+	}
+
+	if err := query.Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
 
 	return messages, nil
 }
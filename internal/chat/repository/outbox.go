@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+	"video-call/pkg/database/postgres"
+
+	"gorm.io/gorm"
+)
+
+// outboxDispatchLock is the key of the advisory lock held by the relay dispatching the outbox.
+// A single dispatcher keeps the events of a conversation in the order they were stored.
+const outboxDispatchLock = 0x63686174 // "chat"
+
+// outbox implements chat.Outbox on the chat_outbox table.
+type outbox struct {
+	db *gorm.DB
+}
+
+// NewOutbox is the constructor for outbox.
+func NewOutbox(db *gorm.DB) chat.Outbox {
+	return &outbox{db: db}
+}
+
+// PublishMessage implements chat.Publisher.
+func (o *outbox) PublishMessage(ctx context.Context, message *models.Message) error {
+	return o.store(ctx, chat.ConversationTopic(message.ConversationID), "", chat.NewMessageEvent(message))
+}
+
+// PublishToConversation implements chat.Publisher.
+func (o *outbox) PublishToConversation(ctx context.Context, conversationID string, event *chat.Event) error {
+	return o.store(ctx, chat.ConversationTopic(conversationID), event.SenderID, event)
+}
+
+// PublishToUser implements chat.Publisher.
+func (o *outbox) PublishToUser(ctx context.Context, userID string, event *chat.Event) error {
+	return o.store(ctx, chat.UserTopic(userID), event.SenderID, event)
+}
+
+func (o *outbox) store(ctx context.Context, topic, excludeID string, frame any) error {
+	payload, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	return postgres.Conn(ctx, o.db).Create(&models.OutboxEvent{
+		Topic:     topic,
+		ExcludeID: excludeID,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// Dispatch implements chat.Outbox. The error of a failed publish is returned after the events
+// published before it are marked. Events are marked in the transaction that locked them, so an event published right before
+// a crash stays undispatched and is published again.
+func (o *outbox) Dispatch(ctx context.Context, limit int, publish func(event *models.OutboxEvent) error) (int, error) {
+	dispatched := 0
+	var publishErr error
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", outboxDispatchLock).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var events []*models.OutboxEvent
+		if err := tx.
+			Where("dispatched_at IS NULL").
+			Order("id ASC").
+			Limit(limit).
+			Find(&events).Error; err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(events))
+		for _, event := range events {
+			if publishErr = publish(event); publishErr != nil {
+				break
+			}
+			ids = append(ids, event.ID)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.OutboxEvent{}).
+			Where("id IN ?", ids).
+			Update("dispatched_at", time.Now()).Error; err != nil {
+			return err
+		}
+		dispatched = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return dispatched, publishErr
+}
+
+// PurgeDispatched implements chat.Outbox.
+func (o *outbox) PurgeDispatched(ctx context.Context, before time.Time) (int64, error) {
+	result := o.db.WithContext(ctx).
+		Where("dispatched_at IS NOT NULL AND dispatched_at < ?", before).
+		Delete(&models.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
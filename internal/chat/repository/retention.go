@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// retained is a condition holding while a message is within the retention of its conversation,
+// given the table aliases of both. Without a policy the conversation follows the tenant default.
+func (r *repo) retained(message, conversation string, at time.Time) clause.Expr {
+	seconds := fmt.Sprintf("COALESCE(%s.retention_seconds, ?)", conversation)
+	return gorm.Expr(
+		fmt.Sprintf("(%[1]s = 0 OR %[2]s.created_at > CAST(? AS timestamp) - make_interval(secs => %[1]s))", seconds, message),
+		r.defaultRetention, at, r.defaultRetention,
+	)
+}
+
+// unexpired is a condition holding while a message is within the retention of its conversation.
+// Queries returning messages use it, so that expired messages are hidden before the purge deletes them.
+func (r *repo) unexpired(message string) clause.Expr {
+	return gorm.Expr(
+		fmt.Sprintf("EXISTS (SELECT 1 FROM conversations rc WHERE rc.id = %s.conversation_id AND ?)", message),
+		r.retained(message, "rc", time.Now()),
+	)
+}
+
+// UpdateConversationRetention implements chat.Repository.
+func (r *repo) UpdateConversationRetention(ctx context.Context, conversationID string, seconds *int64) error {
+	result := r.conn(ctx).
+		Model(&models.Conversation{}).
+		Where("id = ?", conversationID).
+		Update("retention_seconds", seconds)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return chat.ErrConversationNotFound
+	}
+	return nil
+}
+
+// PurgeExpiredMessages implements chat.Repository.
+// Each call is a short transaction; messages locked by a concurrent purge or edit are skipped
+// and picked up by a later call. Statuses, reactions, mentions, attachments and voicemails go along by cascade.
+// The expired messages are looked up per conversation on the keyset index, so that conversations
+// keeping their messages forever cost nothing; without a tenant default only those with a policy are visited.
+func (r *repo) PurgeExpiredMessages(ctx context.Context, at time.Time, limit int) (*chat.PurgedMessages, error) {
+	expiring := gorm.Expr("COALESCE(c.retention_seconds, ?) > 0", r.defaultRetention)
+	if r.defaultRetention == 0 {
+		expiring = gorm.Expr("c.retention_seconds > 0")
+	}
+
+	purged := &chat.PurgedMessages{}
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		if err := tx.Raw(`
+			SELECT m.id
+			FROM conversations c
+			JOIN messages m ON m.conversation_id = c.id
+			WHERE ?
+				AND m.created_at <= CAST(? AS timestamp) - make_interval(secs => COALESCE(c.retention_seconds, ?))
+			LIMIT ?
+			FOR UPDATE OF m SKIP LOCKED`,
+			expiring, at, r.defaultRetention, limit,
+		).Scan(&ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		var attachments []*models.Attachment
+		if err := tx.Where("message_id IN ?", ids).Find(&attachments).Error; err != nil {
+			return err
+		}
+		// The voicemail rows go along by cascade, their recordings are left to the caller
+		if err := tx.Model(&models.Voicemail{}).Where("message_id IN ?", ids).Pluck("storage_key", &purged.VoicemailKeys).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id IN ?", ids).Delete(&models.Message{})
+		if result.Error != nil {
+			return result.Error
+		}
+		purged.Count = int(result.RowsAffected)
+		if len(attachments) == 0 {
+			return nil
+		}
+
+		// Forwarded copies share the stored objects of their originals; those stay while a copy is left
+		keys := make([]string, len(attachments))
+		for i, attachment := range attachments {
+			keys[i] = attachment.StorageKey
+		}
+		var shared []string
+		if err := tx.Model(&models.Attachment{}).Where("storage_key IN ?", keys).Distinct().Pluck("storage_key", &shared).Error; err != nil {
+			return err
+		}
+		purged.Attachments = unsharedAttachments(attachments, shared)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
+
+// unsharedAttachments leaves out the attachments whose stored object is in sharedKeys.
+func unsharedAttachments(attachments []*models.Attachment, sharedKeys []string) []*models.Attachment {
+	if len(sharedKeys) == 0 {
+		return attachments
+	}
+	shared := make(map[string]bool, len(sharedKeys))
+	for _, key := range sharedKeys {
+		shared[key] = true
+	}
+	unshared := attachments[:0]
+	for _, attachment := range attachments {
+		if !shared[attachment.StorageKey] {
+			unshared = append(unshared, attachment)
+		}
+	}
+	return unshared
+}
+
+// LockMessage implements chat.Repository.
+func (r *repo) LockMessage(ctx context.Context, messageID string) error {
+	var ids []string
+	err := r.conn(ctx).Raw(`SELECT m.id FROM messages m WHERE m.id = ? AND ? FOR SHARE OF m`, messageID, r.unexpired("m")).
+		Scan(&ids).Error
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return chat.ErrMessageNotFound
+	}
+	return nil
+}
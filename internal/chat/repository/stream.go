@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"video-call/internal/chat"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	messageStream           = "chat-messages"
+	messageDeadLetterStream = "chat-messages-dead"
+	messageWritersGroup     = "chat-writers"
+	writeResultPrefix       = "chat-write-results:"
+)
+
+// messageQueue implements chat.MessageQueue on a Redis stream with a consumer group.
+// The stream has no length limit: only entries the writers acked are trimmed, so that
+// a backlog built up while the database is down is never dropped.
+type messageQueue struct {
+	rdb *redis.Client
+}
+
+// NewMessageQueue is the constructor for messageQueue. It creates the stream and the writers'
+// group if needed.
+func NewMessageQueue(ctx context.Context, rdb *redis.Client) (chat.MessageQueue, error) {
+	err := rdb.XGroupCreateMkStream(ctx, messageStream, messageWritersGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+	return &messageQueue{rdb: rdb}, nil
+}
+
+// Add implements chat.MessageQueue.
+func (q *messageQueue) Add(ctx context.Context, message *chat.QueuedMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return q.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: messageStream,
+		Values: map[string]any{"message": payload},
+	}).Err()
+}
+
+// Read implements chat.MessageQueue.
+func (q *messageQueue) Read(ctx context.Context, consumer string, count int, block time.Duration) ([]*chat.QueuedMessage, error) {
+	streams, err := q.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    messageWritersGroup,
+		Consumer: consumer,
+		Streams:  []string{messageStream, ">"},
+		Count:    int64(count),
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []redis.XMessage
+	for _, stream := range streams {
+		entries = append(entries, stream.Messages...)
+	}
+	return q.decode(ctx, entries), nil
+}
+
+// Claim implements chat.MessageQueue. The delivery counts of the pending entries tell their attempts.
+func (q *messageQueue) Claim(ctx context.Context, consumer string, minIdle time.Duration, count int) ([]*chat.QueuedMessage, error) {
+	pending, err := q.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: messageStream,
+		Group:  messageWritersGroup,
+		Idle:   minIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  int64(count),
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+	ids := make([]string, len(pending))
+	attempts := make(map[string]int, len(pending))
+	for i, entry := range pending {
+		ids[i] = entry.ID
+		attempts[entry.ID] = int(entry.RetryCount)
+	}
+
+	// Entries another writer claimed in the meantime are no longer idle and are left out
+	entries, err := q.rdb.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   messageStream,
+		Group:    messageWritersGroup,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	messages := q.decode(ctx, entries)
+	for _, message := range messages {
+		message.Attempts = attempts[message.EntryID]
+	}
+	return messages, nil
+}
+
+// Ack implements chat.MessageQueue.
+func (q *messageQueue) Ack(ctx context.Context, entryIDs ...string) error {
+	if len(entryIDs) == 0 {
+		return nil
+	}
+	return q.rdb.XAck(ctx, messageStream, messageWritersGroup, entryIDs...).Err()
+}
+
+// DeadLetter implements chat.MessageQueue.
+func (q *messageQueue) DeadLetter(ctx context.Context, message *chat.QueuedMessage, reason string) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return q.deadLetter(ctx, message.EntryID, string(payload), reason)
+}
+
+// Stats implements chat.MessageQueue.
+func (q *messageQueue) Stats(ctx context.Context) (lag, pending int64, err error) {
+	groups, err := q.rdb.XInfoGroups(ctx, messageStream).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, group := range groups {
+		if group.Name == messageWritersGroup {
+			return group.Lag, group.Pending, nil
+		}
+	}
+	return 0, 0, nil
+}
+
+// Trim implements chat.MessageQueue. Entries below both the oldest pending one and the last one
+// delivered to the group have been acked.
+func (q *messageQueue) Trim(ctx context.Context) (int64, error) {
+	groups, err := q.rdb.XInfoGroups(ctx, messageStream).Result()
+	if err != nil {
+		return 0, err
+	}
+	minID := ""
+	for _, group := range groups {
+		if group.Name == messageWritersGroup {
+			minID = group.LastDeliveredID
+		}
+	}
+	if minID == "" || minID == "0-0" {
+		return 0, nil
+	}
+	pending, err := q.rdb.XPending(ctx, messageStream, messageWritersGroup).Result()
+	if err != nil {
+		return 0, err
+	}
+	if pending.Count > 0 {
+		minID = pending.Lower
+	}
+	return q.rdb.XTrimMinIDApprox(ctx, messageStream, minID, 0).Result()
+}
+
+// PublishResult implements chat.MessageQueue.
+func (q *messageQueue) PublishResult(ctx context.Context, origin string, result *chat.WriteResult) error {
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return q.rdb.Publish(ctx, writeResultPrefix+origin, payload).Err()
+}
+
+// SubscribeResults implements chat.MessageQueue.
+func (q *messageQueue) SubscribeResults(ctx context.Context, origin string) (<-chan *chat.WriteResult, error) {
+	pubsub := q.rdb.Subscribe(ctx, writeResultPrefix+origin)
+	// Wait for the confirmation so that no result published from now on is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	results := make(chan *chat.WriteResult)
+	go func() {
+		defer close(results)
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var result chat.WriteResult
+				if err := json.Unmarshal([]byte(msg.Payload), &result); err != nil {
+					log.Printf("[MessageQueue] Dropping malformed write result: %v", err)
+					continue
+				}
+				select {
+				case results <- &result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return results, nil
+}
+
+// decode parses stream entries. Entries that cannot be parsed would fail forever,
+// so they go to the dead-letter stream right away.
+func (q *messageQueue) decode(ctx context.Context, entries []redis.XMessage) []*chat.QueuedMessage {
+	messages := make([]*chat.QueuedMessage, 0, len(entries))
+	for _, entry := range entries {
+		payload, _ := entry.Values["message"].(string)
+		var message chat.QueuedMessage
+		if err := json.Unmarshal([]byte(payload), &message); err != nil {
+			if err := q.deadLetter(ctx, entry.ID, payload, "malformed entry: "+err.Error()); err != nil {
+				log.Printf("[MessageQueue] Failed to dead-letter entry %s: %v", entry.ID, err)
+			}
+			continue
+		}
+		message.EntryID = entry.ID
+		messages = append(messages, &message)
+	}
+	return messages
+}
+
+func (q *messageQueue) deadLetter(ctx context.Context, entryID, payload, reason string) error {
+	_, err := q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		err := pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: messageDeadLetterStream,
+			Values: map[string]any{
+				"message":   payload,
+				"entry_id":  entryID,
+				"reason":    reason,
+				"failed_at": time.Now().UTC().Format(time.RFC3339),
+			},
+		}).Err()
+		if err != nil {
+			return err
+		}
+		return pipe.XAck(ctx, messageStream, messageWritersGroup, entryID).Err()
+	})
+	return err
+}
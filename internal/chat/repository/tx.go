@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+
+	"video-call/pkg/database/postgres"
+
+	"gorm.io/gorm"
+)
+
+// Transaction implements chat.Repository.
+// Transactions started inside fn, also by repository methods, become savepoints of this one.
+func (r *repo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return postgres.Transaction(ctx, r.db, fn)
+}
+
+// AfterCommit implements chat.Repository.
+func (r *repo) AfterCommit(ctx context.Context, hook func(ctx context.Context)) {
+	postgres.AfterCommit(ctx, hook)
+}
+
+// conn returns the connection to run a query on: the transaction of ctx if there is one.
+func (r *repo) conn(ctx context.Context) *gorm.DB {
+	return postgres.Conn(ctx, r.db)
+}
@@ -0,0 +1,85 @@
+package chat
+
+import (
+	"strconv"
+	"time"
+
+	"video-call/internal/models"
+)
+
+const (
+	// RetentionDefault makes a conversation follow the tenant default retention.
+	RetentionDefault = "default"
+	// RetentionOff keeps the messages of a conversation forever.
+	RetentionOff = "off"
+	// DefaultPurgeBatchSize is the number of expired messages deleted per transaction.
+	DefaultPurgeBatchSize = 500
+)
+
+// PurgedMessages is the outcome of purging a batch of expired messages.
+// Deleting the stored objects it lists is left to the caller.
+type PurgedMessages struct {
+	Count int
+	// Attachments are the attachments of the messages whose stored objects no other attachment shares
+	Attachments []*models.Attachment
+	// VoicemailKeys are the stored recordings of the voicemails the messages announced
+	VoicemailKeys []string
+}
+
+// retentionPeriods are the retention policies a conversation can be set to.
+var retentionPeriods = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"90d": 90 * 24 * time.Hour,
+}
+
+// Retention is the retention policy of a conversation and the period it results in.
+type Retention struct {
+	// Policy is a period such as "7d", RetentionOff or RetentionDefault
+	Policy string
+	// Period is how long messages are kept; 0 keeps them forever
+	Period time.Duration
+}
+
+// ParseRetentionPolicy returns the value stored for a retention policy:
+// nil for RetentionDefault, 0 for RetentionOff and the period in seconds otherwise.
+func ParseRetentionPolicy(policy string) (*int64, error) {
+	var seconds int64
+	switch policy {
+	case RetentionDefault:
+		return nil, nil
+	case RetentionOff:
+	default:
+		period, ok := retentionPeriods[policy]
+		if !ok {
+			return nil, ErrInvalidRetention
+		}
+		seconds = int64(period / time.Second)
+	}
+	return &seconds, nil
+}
+
+// RetentionPolicyOf returns the retention policy of the value stored for a conversation.
+func RetentionPolicyOf(seconds *int64) string {
+	switch {
+	case seconds == nil:
+		return RetentionDefault
+	case *seconds == 0:
+		return RetentionOff
+	}
+	for policy, period := range retentionPeriods {
+		if int64(period/time.Second) == *seconds {
+			return policy
+		}
+	}
+	return strconv.FormatInt(*seconds, 10) + "s"
+}
+
+// NewRetention returns the retention of a conversation given the tenant default period.
+func NewRetention(seconds *int64, defaultPeriod time.Duration) *Retention {
+	retention := &Retention{Policy: RetentionPolicyOf(seconds), Period: defaultPeriod}
+	if seconds != nil {
+		retention.Period = time.Duration(*seconds) * time.Second
+	}
+	return retention
+}
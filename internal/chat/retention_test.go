@@ -0,0 +1,32 @@
+package chat
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetentionPolicyRoundTrip(t *testing.T) {
+	for _, policy := range []string{"24h", "7d", "90d", RetentionOff, RetentionDefault} {
+		seconds, err := ParseRetentionPolicy(policy)
+		if err != nil {
+			t.Fatalf("ParseRetentionPolicy(%q): %v", policy, err)
+		}
+		if got := RetentionPolicyOf(seconds); got != policy {
+			t.Errorf("RetentionPolicyOf(ParseRetentionPolicy(%q)) = %q", policy, got)
+		}
+	}
+	if _, err := ParseRetentionPolicy("1y"); !errors.Is(err, ErrInvalidRetention) {
+		t.Errorf("ParseRetentionPolicy(1y) = %v, want ErrInvalidRetention", err)
+	}
+}
+
+func TestNewRetentionFollowsDefault(t *testing.T) {
+	if r := NewRetention(nil, 48*time.Hour); r.Policy != RetentionDefault || r.Period != 48*time.Hour {
+		t.Errorf("NewRetention(nil) = %+v", r)
+	}
+	off := int64(0)
+	if r := NewRetention(&off, 48*time.Hour); r.Policy != RetentionOff || r.Period != 0 {
+		t.Errorf("NewRetention(0) = %+v", r)
+	}
+}
@@ -0,0 +1,88 @@
+package chat
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"video-call/internal/models"
+)
+
+const (
+	// DefaultSearchPageSize is used when no or an invalid limit is requested.
+	DefaultSearchPageSize = 20
+	// MaxSearchPageSize is the largest page SearchMessages returns.
+	MaxSearchPageSize = 50
+	// MaxSearchQueryLength bounds the search text in bytes.
+	MaxSearchQueryLength = 256
+)
+
+var (
+	// ErrInvalidSearchQuery is returned when the search text is empty or too long
+	ErrInvalidSearchQuery = errors.New("invalid search query")
+	// ErrInvalidDateRange is returned when a search date range ends before it starts
+	ErrInvalidDateRange = errors.New("invalid date range")
+)
+
+// SearchQuery selects messages matching a full-text query in the conversations of UserID.
+// Empty filters match everything.
+type SearchQuery struct {
+	UserID         string
+	Text           string
+	ConversationID string
+	SenderID       string
+	MessageType    models.MessageType
+	From           *time.Time // inclusive
+	To             *time.Time // exclusive
+	Cursor         string     // opaque cursor from a previous page
+	Limit          int
+}
+
+// SearchHit is a message matching a search together with its relevance.
+// Snippet is the HTML escaped content with matches wrapped in <mark> tags.
+type SearchHit struct {
+	Message *models.Message
+	Rank    float64
+	Snippet string
+}
+
+// SearchResult is a page of search hits ordered by decreasing relevance.
+type SearchResult struct {
+	Hits       []*SearchHit
+	NextCursor string
+}
+
+// SearchCursor anchors keyset pagination on (rank, created_at, id), all descending.
+type SearchCursor struct {
+	Rank      float64   `json:"r"`
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// NewSearchCursor returns a cursor continuing after hit.
+func NewSearchCursor(hit *SearchHit) *SearchCursor {
+	return &SearchCursor{Rank: hit.Rank, CreatedAt: hit.Message.CreatedAt, ID: hit.Message.ID}
+}
+
+// Encode returns the opaque string form of the cursor.
+func (c *SearchCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeSearchCursor parses a cursor produced by SearchCursor.Encode.
+func DecodeSearchCursor(s string) (*SearchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor SearchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.ID == "" || cursor.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
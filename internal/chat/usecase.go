@@ -9,9 +9,9 @@ import (
 
 // UseCase defines the interface for chat-related business logic.
 type UseCase interface {
-	// CreateConversation creates a new conversation with the given details
-	// The conversation ID will be generated if not provided
-	CreateConversation(ctx context.Context, conversation *models.Conversation) error
+	// CreateConversation creates a group conversation owned by conversation.CreatedBy with the given members
+	// The conversation ID will be generated if not provided; direct conversations are created by GetOrCreateDirectConversation
+	CreateConversation(ctx context.Context, conversation *models.Conversation, memberIDs []string) error
 
 	// GetConversationByID retrieves a conversation by its ID
 	GetConversationByID(ctx context.Context, conversationID string) (*models.Conversation, error)
@@ -20,19 +20,175 @@ type UseCase interface {
 	// Results are ordered by last activity (newest first)
 	GetConversationsByUserID(ctx context.Context, userID string) ([]*models.Conversation, error)
 
-	// UpdateConversation updates an existing conversation
-	UpdateConversation(ctx context.Context, conversation *models.Conversation) error
+	// GetConversationSummaries retrieves the conversations of a user matching the filter with their unread count
+	// and last message; pinned conversations come first, then the others by last activity (newest first)
+	GetConversationSummaries(ctx context.Context, userID string, filter ConversationFilter) ([]*ConversationSummary, error)
 
-	// DeleteConversation deletes a conversation by its ID
-	DeleteConversation(ctx context.Context, conversationID string) error
+	// UpdateConversationSettings mutes, pins or archives a conversation for the user only
+	// The other devices of the user receive a conversation.settings event
+	UpdateConversationSettings(ctx context.Context, conversationID, userID string, update *SettingsUpdate) (*models.ConversationParticipant, error)
+
+	// MarkConversationRead moves the read position of the user forward to a message, or to the latest
+	// message if messageID is empty; earlier messages are marked read and subscribers receive a conversation.read event
+	MarkConversationRead(ctx context.Context, conversationID, userID, messageID string) error
+
+	// UpdateConversation saves a renamed group on behalf of userID, who must be an owner or admin
+	// Subscribers receive a conversation.updated event
+	UpdateConversation(ctx context.Context, conversation *models.Conversation, userID string) error
+
+	// DeleteConversation deletes a conversation on behalf of userID
+	// Only the owner may delete a group; subscribers receive a conversation.deleted event
+	DeleteConversation(ctx context.Context, conversationID, userID string) error
+
+	// GetMembers retrieves the members of a conversation with their roles in joining order
+	GetMembers(ctx context.Context, conversationID string) ([]*models.ConversationParticipant, error)
+
+	// AddMembers adds users to a group on behalf of userID, who must be an owner or admin
+	// Users who are already members are skipped; subscribers and the new members receive a member.added event
+	AddMembers(ctx context.Context, conversationID, userID string, memberIDs []string) ([]*models.ConversationParticipant, error)
+
+	// RemoveMember removes memberID from a group on behalf of userID, who must outrank the member
+	// Subscribers receive a member.removed event and the live subscriptions of the member are cut off
+	RemoveMember(ctx context.Context, conversationID, userID, memberID string) error
+
+	// LeaveConversation removes userID from a group
+	// The owner must transfer ownership first unless they are the last member, in which case the group is deleted
+	LeaveConversation(ctx context.Context, conversationID, userID string) error
+
+	// UpdateMemberRole promotes a member to admin or demotes an admin on behalf of the owner
+	UpdateMemberRole(ctx context.Context, conversationID, userID, memberID string, role models.ParticipantRole) error
+
+	// TransferOwnership hands the ownership of a group from userID to another member
+	// The previous owner stays on as an admin
+	TransferOwnership(ctx context.Context, conversationID, userID, newOwnerID string) error
+
+	// GetOrCreateDirectConversation returns the one-to-one conversation between two users,
+	// creating it with both users as participants if it does not exist yet
+	GetOrCreateDirectConversation(ctx context.Context, userA, userB string) (*models.Conversation, error)
 
 	// IsUserInConversation checks if a user is a participant in a conversation
 	IsUserInConversation(ctx context.Context, userID, conversationID string) (bool, error)
 
-	// CreateMessage creates a new message in a conversation
-	CreateMessage(ctx context.Context, message models.Message) error
+	// CreateMessage creates a new message in a conversation and returns it as stored
+	// The message is published as message.new; members who have not muted the conversation
+	// also receive a notification.message event
+	// A retried send with the client message ID of a stored message returns that original message,
+	// whose ID differs from message.ID, and stores nothing
+	CreateMessage(ctx context.Context, message models.Message) (*models.Message, error)
+
+	// CreateMessages stores a batch of queued messages and returns each as stored, or its error
+	// A message already stored under its ID is returned as it is
+	CreateMessages(ctx context.Context, messages []models.Message) ([]*models.Message, []error)
+
+	// ValidateReplyTo checks that a replied message exists in the conversation
+	ValidateReplyTo(ctx context.Context, conversationID, replyToID string) error
+
+	// GetThread retrieves a root message with a page of its replies, oldest first
+	GetThread(ctx context.Context, conversationID, messageID, userID, cursor string, limit int) (*Thread, error)
+
+	// UploadAttachment stores a file uploaded to a conversation until a message shares it
+	UploadAttachment(ctx context.Context, conversationID, userID string, upload *AttachmentUpload) (*models.Attachment, error)
+
+	// SendVoiceMessage stores an Ogg Opus recording and posts it as a voice message
+	// with its duration and waveform in the message metadata
+	SendVoiceMessage(ctx context.Context, conversationID, userID string, upload *AttachmentUpload, replyToID *string) (*models.Message, error)
+
+	// ValidateAttachments checks that the sender may share the attachments in a message of the conversation
+	ValidateAttachments(ctx context.Context, conversationID, senderID string, attachmentIDs []string) ([]*models.Attachment, error)
+
+	// GetAttachment retrieves an attachment of the conversation with a fresh download URL for userID
+	GetAttachment(ctx context.Context, conversationID, attachmentID, userID string) (*models.Attachment, error)
+
+	// OpenAttachment checks a signed download link and opens the attachment or one of its variants for a participant
+	OpenAttachment(ctx context.Context, attachmentID, variant, userID string, expires int64, signature string) (*AttachmentDownload, error)
+
+	// AddReaction reacts to a message with an emoji; reacting twice with the same emoji is a no-op
+	AddReaction(ctx context.Context, conversationID, messageID, userID, emoji string) error
+
+	// RemoveReaction withdraws a reaction; removing a missing reaction is a no-op
+	RemoveReaction(ctx context.Context, conversationID, messageID, userID, emoji string) error
+
+	// EditMessage replaces the content of a message, keeping the previous content as a revision
+	// Only the sender or a group admin may edit; subscribers receive a message.updated event
+	EditMessage(ctx context.Context, conversationID, messageID, userID, content string) (*models.Message, error)
+
+	// DeleteMessage replaces a message by a tombstone without content
+	// Only the sender or a group admin may delete; subscribers receive a message.deleted event
+	DeleteMessage(ctx context.Context, conversationID, messageID, userID string) error
+
+	// GetMessage retrieves a message of a conversation the user participates in
+	// Returns ErrMessageDeleted if the message has been deleted
+	GetMessage(ctx context.Context, messageID, userID string) (*models.Message, error)
+
+	// GetMessageRevisions retrieves the edit history of a message, oldest first
+	GetMessageRevisions(ctx context.Context, conversationID, messageID string) ([]*models.MessageRevision, error)
+
+	// MarkMessagesDelivered records that messages reached one of the recipient's connections
+	// The sender of each message is notified when its status changes
+	MarkMessagesDelivered(ctx context.Context, messageIDs []string, userID string) error
+
+	// MarkMessageRead records that the recipient acknowledged reading a message
+	// The sender is notified when the status changes
+	MarkMessageRead(ctx context.Context, messageID, userID string) error
+
+	// GetMessageStatuses retrieves the per-recipient statuses of a message in a conversation
+	GetMessageStatuses(ctx context.Context, conversationID, messageID string) ([]*models.MessageStatus, error)
+
+	// StartTyping tells the other participants that the user is typing
+	// The indicator expires after chat.TypingTimeout unless renewed
+	StartTyping(ctx context.Context, conversationID, userID string) error
+
+	// StopTyping tells the other participants that the user stopped typing
+	StopTyping(ctx context.Context, conversationID, userID string) error
+
+	// UpdatePresence refreshes the state of one connection of a user
+	// Conversation peers are notified when the user's aggregated presence changes
+	UpdatePresence(ctx context.Context, userID, connectionID string, status models.PresenceStatus) error
+
+	// RemovePresence forgets a closed connection of a user
+	RemovePresence(ctx context.Context, userID, connectionID string) error
+
+	// GetPresence retrieves the aggregated presence of the given users as seen by viewerID;
+	// users sharing no conversation with the viewer are left out
+	GetPresence(ctx context.Context, viewerID string, userIDs []string) ([]*models.UserPresence, error)
+
+	// SweepPresences announces up to limit users whose connections expired without being closed
+	// as offline and returns how many users were checked
+	SweepPresences(ctx context.Context, limit int) (int, error)
+
+	// SearchMessages finds messages matching a full-text query in the user's conversations
+	SearchMessages(ctx context.Context, query *SearchQuery) (*SearchResult, error)
+
+	// UpdateRetention sets the retention policy of a conversation: a period such as "7d",
+	// chat.RetentionOff or chat.RetentionDefault
+	UpdateRetention(ctx context.Context, conversationID, userID, policy string) (*Retention, error)
+
+	// PurgeExpiredMessages deletes up to limit messages past the retention of their conversation
+	// and returns how many were deleted
+	PurgeExpiredMessages(ctx context.Context, limit int) (int, error)
+
+	// PinMessage pins a message to its conversation, up to the configured maximum of pins
+	PinMessage(ctx context.Context, conversationID, messageID, userID string) (*models.PinnedMessage, error)
+
+	// UnpinMessage removes the pin of a message
+	UnpinMessage(ctx context.Context, conversationID, messageID, userID string) error
+
+	// GetPinnedMessages retrieves the pinned messages of a conversation, most recently pinned first
+	GetPinnedMessages(ctx context.Context, conversationID, viewerID string) ([]*PinnedMessage, error)
+
+	// ForwardMessage copies a message and its attachments to other conversations of the user
+	// and returns the new messages in the order of targetIDs; with includeSender the copies
+	// credit the sender of the original
+	ForwardMessage(ctx context.Context, conversationID, messageID, userID string, targetIDs []string, includeSender bool) ([]*models.Message, error)
+
+	// GetMentions retrieves a page of the recent mentions of a user, newest first;
+	// cursor is the NextCursor of the previous page or empty for the first one
+	GetMentions(ctx context.Context, userID, cursor string, limit int) (*MentionPage, error)
+
+	// SyncMessages retrieves a page of the messages of a conversation stored after a sequence number
+	SyncMessages(ctx context.Context, conversationID, viewerID string, afterSeq int64, limit int) (*SyncPage, error)
 
-	// GetMessages retrieves messages for a conversation with pagination
-	// Returns messages in descending order by creation time (newest first)
-	GetMessages(ctx context.Context, conversationID string, limit, offset int) ([]*models.Message, error)
+	// GetMessages retrieves a page of messages for a conversation using keyset pagination
+	// Messages are returned newest first together with cursors for the adjacent pages
+	GetMessages(ctx context.Context, conversationID string, query *MessagePageQuery) (*MessagePage, error)
 }
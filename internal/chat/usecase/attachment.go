@@ -0,0 +1,335 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+	"video-call/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAttachmentMaxSizeMB = 25
+	defaultDownloadURLExpiry   = 15 * time.Minute
+	maxFileNameLength          = 255
+)
+
+// defaultAttachmentTypes is used when ATTACHMENT_ALLOWED_TYPES is not configured.
+var defaultAttachmentTypes = []string{
+	"image/jpeg", "image/png", "image/gif", "image/webp",
+	"video/mp4", "video/webm",
+	"audio/mpeg",
+	"application/pdf", "application/zip", "text/plain",
+}
+
+func (u *usecase) attachmentMaxSize() int64 {
+	mb := u.cfg.Media.AttachmentMaxSizeMB
+	if mb <= 0 {
+		mb = defaultAttachmentMaxSizeMB
+	}
+	return int64(mb) << 20
+}
+
+func (u *usecase) isAllowedAttachmentType(contentType string) bool {
+	allowed := u.cfg.Media.AttachmentAllowedTypes
+	if len(allowed) == 0 {
+		allowed = defaultAttachmentTypes
+	}
+	for _, t := range allowed {
+		if strings.EqualFold(strings.TrimSpace(t), contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *usecase) downloadURLExpiry() time.Duration {
+	if u.cfg.Storage.URLExpiry <= 0 {
+		return defaultDownloadURLExpiry
+	}
+	return time.Duration(u.cfg.Storage.URLExpiry) * time.Second
+}
+
+// UploadAttachment stores a file uploaded to a conversation until a message shares it.
+func (u *usecase) UploadAttachment(ctx context.Context, conversationID, userID string, upload *chat.AttachmentUpload) (*models.Attachment, error) {
+	u.logger.Infof(ctx, "Usecase UploadAttachment: conversationID=%s, userID=%s, size=%d", conversationID, userID, upload.Size)
+
+	maxSize := u.attachmentMaxSize()
+	if upload.Size > maxSize {
+		return nil, chat.ErrAttachmentTooLarge
+	}
+
+	body, err := storage.NewUpload(upload.Reader, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	contentType := body.ContentType()
+	if !u.isAllowedAttachmentType(contentType) {
+		return nil, chat.ErrUnsupportedMediaType
+	}
+
+	attachment := &models.Attachment{
+		ID:             uuid.New().String(),
+		ConversationID: conversationID,
+		UploaderID:     userID,
+		FileName:       sanitizeFileName(upload.FileName),
+		ContentType:    contentType,
+		CreatedAt:      time.Now(),
+	}
+	attachment.StorageKey = fmt.Sprintf("attachments/%s/%s", conversationID, attachment.ID)
+
+	if err := u.store.Put(ctx, attachment.StorageKey, body, upload.Size, contentType); err != nil {
+		u.logger.Errorf(ctx, "Failed to store attachment %s: %v", attachment.ID, err)
+		return nil, err
+	}
+	if body.TooLarge() {
+		u.deleteObject(ctx, attachment.StorageKey)
+		return nil, chat.ErrAttachmentTooLarge
+	}
+	attachment.SizeBytes = body.Size()
+
+	if err := u.repo.CreateAttachment(ctx, attachment); err != nil {
+		u.logger.Errorf(ctx, "Failed to save attachment %s: %v", attachment.ID, err)
+		u.deleteObject(ctx, attachment.StorageKey)
+		return nil, err
+	}
+
+	u.signAttachment(attachment, userID)
+	return attachment, nil
+}
+
+// ValidateAttachments checks that the sender uploaded the attachments to the conversation
+// and that no message shares them yet. They are returned in the order of attachmentIDs.
+func (u *usecase) ValidateAttachments(ctx context.Context, conversationID, senderID string, attachmentIDs []string) ([]*models.Attachment, error) {
+	seen := make(map[string]bool, len(attachmentIDs))
+	for _, id := range attachmentIDs {
+		if _, err := uuid.Parse(id); err != nil || seen[id] {
+			return nil, chat.ErrInvalidAttachment
+		}
+		seen[id] = true
+	}
+
+	found, err := u.repo.GetAttachmentsByIDs(ctx, attachmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Attachment, len(found))
+	for _, attachment := range found {
+		byID[attachment.ID] = attachment
+	}
+
+	attachments := make([]*models.Attachment, 0, len(attachmentIDs))
+	for _, id := range attachmentIDs {
+		attachment, ok := byID[id]
+		if !ok || attachment.ConversationID != conversationID || attachment.UploaderID != senderID || attachment.MessageID != nil {
+			return nil, chat.ErrInvalidAttachment
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// GetAttachment retrieves an attachment of the conversation with a fresh download URL for userID.
+// Attachments not shared by a message yet are only visible to their uploader, and images and
+// videos are only downloadable by others once processed.
+func (u *usecase) GetAttachment(ctx context.Context, conversationID, attachmentID, userID string) (*models.Attachment, error) {
+	if _, err := uuid.Parse(attachmentID); err != nil {
+		return nil, chat.ErrAttachmentNotFound
+	}
+	attachment, err := u.repo.GetAttachmentByID(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if attachment.ConversationID != conversationID {
+		return nil, chat.ErrAttachmentNotFound
+	}
+	if attachment.MessageID == nil {
+		if attachment.UploaderID != userID {
+			return nil, chat.ErrAttachmentNotFound
+		}
+	} else {
+		message, err := u.repo.GetMessageByID(ctx, *attachment.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		if message.IsDeleted() {
+			return nil, chat.ErrAttachmentNotFound
+		}
+	}
+
+	u.signAttachment(attachment, userID)
+	return attachment, nil
+}
+
+// OpenAttachment checks a signed download link and opens the attachment, or one of its
+// variants if variant is set. The link only works for the user it was issued to, and only
+// while they are a participant.
+func (u *usecase) OpenAttachment(ctx context.Context, attachmentID, variant, userID string, expires int64, signature string) (*chat.AttachmentDownload, error) {
+	if err := u.signer.Verify(attachmentResource(attachmentID, variant, userID), expires, signature); err != nil {
+		return nil, chat.ErrInvalidDownloadLink
+	}
+
+	attachment, err := u.repo.GetAttachmentByID(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := u.repo.IsUserInConversation(ctx, userID, attachment.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, chat.ErrNotAllowed
+	}
+	if attachment.MessageID != nil {
+		message, err := u.repo.GetMessageByID(ctx, *attachment.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		if message.IsDeleted() {
+			return nil, chat.ErrAttachmentNotFound
+		}
+	}
+	if withheld(attachment, userID) {
+		return nil, chat.ErrAttachmentNotFound
+	}
+
+	download := &chat.AttachmentDownload{
+		Attachment:  attachment,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Size:        attachment.SizeBytes,
+	}
+	key := attachment.StorageKey
+	if variant != "" {
+		if !hasVariant(attachment, variant) {
+			return nil, chat.ErrAttachmentNotFound
+		}
+		key = variantKey(attachment, variant)
+		download.FileName = variant + ".jpg"
+		download.ContentType = "image/jpeg"
+		download.Size = -1
+	}
+
+	if presigner, ok := u.store.(storage.Presigner); ok {
+		expiry := time.Until(time.Unix(expires, 0))
+		if expiry < time.Second {
+			expiry = time.Second
+		}
+		download.RedirectURL, err = presigner.PresignGet(ctx, key, expiry, download.FileName)
+		if err != nil {
+			return nil, err
+		}
+		return download, nil
+	}
+
+	download.Body, err = u.store.Get(ctx, key)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, chat.ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return download, nil
+}
+
+// attachAttachments fills in the attachments of the messages with download URLs for viewerID.
+func (u *usecase) attachAttachments(ctx context.Context, messages []*models.Message, viewerID string) error {
+	ids := make([]string, 0, len(messages))
+	for _, message := range messages {
+		if !message.IsDeleted() {
+			ids = append(ids, message.ID)
+		}
+	}
+
+	attachments, err := u.repo.GetAttachmentsByMessageIDs(ctx, ids)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to get attachments: %v", err)
+		return err
+	}
+	byID := make(map[string][]*models.Attachment, len(ids))
+	for _, attachment := range attachments {
+		u.signAttachment(attachment, viewerID)
+		byID[*attachment.MessageID] = append(byID[*attachment.MessageID], attachment)
+	}
+
+	for _, message := range messages {
+		message.Attachments = byID[message.ID]
+	}
+	return nil
+}
+
+// signAttachment fills in download links of an attachment and its variants that only userID may use.
+// Withheld attachments get none.
+func (u *usecase) signAttachment(attachment *models.Attachment, userID string) {
+	if withheld(attachment, userID) {
+		return
+	}
+	expires := time.Now().Add(u.downloadURLExpiry())
+	attachment.URL = u.downloadURL(attachment.ID, "", userID, expires)
+	for i := range attachment.Variants {
+		attachment.Variants[i].URL = u.downloadURL(attachment.ID, attachment.Variants[i].Name, userID, expires)
+	}
+}
+
+func (u *usecase) downloadURL(attachmentID, variant, userID string, expires time.Time) string {
+	query := url.Values{}
+	if variant != "" {
+		query.Set("variant", variant)
+	}
+	query.Set("user_id", userID)
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", u.signer.Sign(attachmentResource(attachmentID, variant, userID), expires))
+	return fmt.Sprintf("/api/v1/chat/attachments/%s/content?%s", attachmentID, query.Encode())
+}
+
+func (u *usecase) deleteObject(ctx context.Context, key string) {
+	if err := u.store.Delete(ctx, key); err != nil {
+		u.logger.Errorf(ctx, "Failed to delete stored object %s: %v", key, err)
+	}
+}
+
+// withheld reports whether an attachment is kept from userID until the media pipeline has processed it:
+// the original of an image or video may carry metadata such as the position of the camera.
+func withheld(attachment *models.Attachment, userID string) bool {
+	return attachment.HasMedia() && attachment.ProcessedAt == nil && attachment.UploaderID != userID
+}
+
+// attachmentResource is the signed part of an attachment download link.
+func attachmentResource(attachmentID, variant, userID string) string {
+	return "attachment:" + attachmentID + ":" + variant + ":" + userID
+}
+
+// variantKey is the storage key of a rendition of an attachment.
+func variantKey(attachment *models.Attachment, variant string) string {
+	return attachment.StorageKey + "." + variant + ".jpg"
+}
+
+func hasVariant(attachment *models.Attachment, variant string) bool {
+	for _, v := range attachment.Variants {
+		if v.Name == variant {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeFileName keeps the base name of an uploaded file for display and downloads.
+func sanitizeFileName(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	if len(name) > maxFileNameLength {
+		name = strings.ToValidUTF8(name[len(name)-maxFileNameLength:], "")
+	}
+	return name
+}
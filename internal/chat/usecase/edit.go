@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+)
+
+// messageDeletedEvent is the payload of a chat.EventMessageDeleted event.
+type messageDeletedEvent struct {
+	MessageID string    `json:"message_id"`
+	DeletedBy string    `json:"deleted_by"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// EditMessage replaces the content of a message, keeping the previous content as a revision.
+func (u *usecase) EditMessage(ctx context.Context, conversationID, messageID, userID, content string) (*models.Message, error) {
+	u.logger.Infof(ctx, "Usecase EditMessage: conversationID=%s, messageID=%s, userID=%s", conversationID, messageID, userID)
+
+	if strings.TrimSpace(content) == "" {
+		return nil, chat.ErrEmptyContent
+	}
+	message, err := u.getModifiableMessage(ctx, conversationID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		edited, err := u.repo.UpdateMessageContent(ctx, message.ID, content, userID, time.Now())
+		if err != nil {
+			return err
+		}
+		message = edited
+		return u.outbox.PublishToConversation(ctx, conversationID, &chat.Event{
+			Type:           chat.EventMessageUpdated,
+			ConversationID: conversationID,
+			Data:           message,
+		})
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to edit message %s: %v", messageID, err)
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// DeleteMessage replaces a message by a tombstone without content.
+func (u *usecase) DeleteMessage(ctx context.Context, conversationID, messageID, userID string) error {
+	u.logger.Infof(ctx, "Usecase DeleteMessage: conversationID=%s, messageID=%s, userID=%s", conversationID, messageID, userID)
+
+	message, err := u.getModifiableMessage(ctx, conversationID, messageID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		deleted, err := u.repo.SoftDeleteMessage(ctx, message.ID, time.Now())
+		if err != nil {
+			return err
+		}
+		// A deleted message has nothing left to show, so it loses its pin
+		if err := u.unpinMessage(ctx, deleted, userID, false); err != nil {
+			return err
+		}
+		return u.outbox.PublishToConversation(ctx, conversationID, &chat.Event{
+			Type:           chat.EventMessageDeleted,
+			ConversationID: conversationID,
+			Data: messageDeletedEvent{
+				MessageID: deleted.ID,
+				DeletedBy: userID,
+				DeletedAt: *deleted.DeletedAt,
+			},
+		})
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to delete message %s: %v", messageID, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetMessageRevisions retrieves the edit history of a message, oldest first.
+func (u *usecase) GetMessageRevisions(ctx context.Context, conversationID, messageID string) ([]*models.MessageRevision, error) {
+	u.logger.Infof(ctx, "Usecase GetMessageRevisions: conversationID=%s, messageID=%s", conversationID, messageID)
+
+	if _, err := u.getConversationMessage(ctx, conversationID, messageID); err != nil {
+		return nil, err
+	}
+	return u.repo.GetMessageRevisions(ctx, messageID)
+}
+
+// getModifiableMessage loads a message of the conversation that userID may edit or delete.
+func (u *usecase) getModifiableMessage(ctx context.Context, conversationID, messageID, userID string) (*models.Message, error) {
+	message, err := u.getConversationMessage(ctx, conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.IsDeleted() {
+		return nil, chat.ErrMessageDeleted
+	}
+	if message.SenderID == userID {
+		return message, nil
+	}
+
+	// Owners and admins moderate the messages of a group
+	actor, err := u.getGroupParticipant(ctx, conversationID, userID)
+	if errors.Is(err, chat.ErrNotGroupConversation) || errors.Is(err, chat.ErrParticipantNotFound) {
+		return nil, chat.ErrNotAllowed
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Role.CanModerate() {
+		return nil, chat.ErrNotAllowed
+	}
+	return message, nil
+}
@@ -0,0 +1,202 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// maxForwardTargets is the number of conversations a message may be forwarded to at once.
+const maxForwardTargets = 10
+
+// forwardedFrom is stored under the "forwarded_from" key of the Metadata of a forwarded message.
+type forwardedFrom struct {
+	MessageID      string `json:"message_id"`
+	ConversationID string `json:"conversation_id"`
+	// SenderID is only set when the forwarding user chose to credit the original sender
+	SenderID string `json:"sender_id,omitempty"`
+}
+
+// ForwardMessage copies a message to other conversations of the user, all or none of them.
+// The copies are sent by the user and share the stored objects of the original's attachments,
+// so images and videos can only be forwarded once the media pipeline has processed them.
+// Mentions in the content are not resolved again, so forwarding notifies no one by name.
+func (u *usecase) ForwardMessage(ctx context.Context, conversationID, messageID, userID string, targetIDs []string, includeSender bool) ([]*models.Message, error) {
+	u.logger.Infof(ctx, "Usecase ForwardMessage: conversationID=%s, messageID=%s, userID=%s, targets=%v", conversationID, messageID, userID, targetIDs)
+
+	if len(targetIDs) == 0 || len(targetIDs) > maxForwardTargets {
+		return nil, chat.ErrInvalidForwardTarget
+	}
+	for _, targetID := range targetIDs {
+		if _, err := uuid.Parse(targetID); err != nil {
+			return nil, chat.ErrInvalidForwardTarget
+		}
+	}
+
+	if err := u.checkMember(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+	source, err := u.getConversationMessage(ctx, conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if source.IsDeleted() {
+		return nil, chat.ErrMessageDeleted
+	}
+	if isVoicemail(source) {
+		return nil, chat.ErrNotForwardable
+	}
+
+	// Conversations are locked in sorted order when storing, like the message writer does
+	unique := make([]string, 0, len(targetIDs))
+	seen := make(map[string]bool, len(targetIDs))
+	for _, targetID := range targetIDs {
+		if !seen[targetID] {
+			seen[targetID] = true
+			unique = append(unique, targetID)
+		}
+	}
+	sort.Strings(unique)
+	for _, targetID := range unique {
+		if err := u.checkMember(ctx, targetID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	attachments, err := u.repo.GetAttachmentsByMessageIDs(ctx, []string{source.ID})
+	if err != nil {
+		return nil, err
+	}
+	// The copies would expose the originals, which may still carry the position of the camera
+	for _, attachment := range attachments {
+		if attachment.HasMedia() && attachment.ProcessedAt == nil {
+			return nil, chat.ErrMediaNotReady
+		}
+	}
+	from := forwardedFrom{MessageID: source.ID, ConversationID: source.ConversationID}
+	if includeSender {
+		from.SenderID = source.SenderID
+	}
+	now := time.Now()
+	byTarget := make(map[string]*models.Message, len(unique))
+	messages := make([]*models.Message, len(unique))
+	for i, targetID := range unique {
+		message, err := forwardedMessage(source, attachments, targetID, userID, from, now)
+		if err != nil {
+			return nil, err
+		}
+		messages[i] = message
+		byTarget[targetID] = message
+	}
+
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		// The original may not be purged while its attachments are being shared
+		if err := u.repo.LockMessage(ctx, source.ID); err != nil {
+			return err
+		}
+		for _, message := range messages {
+			for _, attachment := range message.Attachments {
+				if err := u.repo.CreateAttachment(ctx, attachment); err != nil {
+					return err
+				}
+			}
+			if err := u.repo.CreateMessage(ctx, message); err != nil {
+				return err
+			}
+			if err := u.outbox.PublishMessage(ctx, message); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to forward message %s: %v", messageID, err)
+		return nil, err
+	}
+
+	forwarded := make([]*models.Message, len(targetIDs))
+	for i, targetID := range targetIDs {
+		forwarded[i] = byTarget[targetID]
+	}
+	for _, message := range messages {
+		u.messageCreated(ctx, message, nil)
+	}
+	return forwarded, nil
+}
+
+// checkMember checks that the user is a participant of a conversation.
+func (u *usecase) checkMember(ctx context.Context, conversationID, userID string) error {
+	ok, err := u.repo.IsUserInConversation(ctx, userID, conversationID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return chat.ErrNotAllowed
+	}
+	return nil
+}
+
+// isVoicemail reports whether a message announces a voicemail. Only the caller and the callee
+// may play its recording, so a copy would not play for anyone else.
+func isVoicemail(message *models.Message) bool {
+	var metadata struct {
+		Kind string `json:"kind"`
+	}
+	return len(message.Metadata) > 0 && json.Unmarshal(message.Metadata, &metadata) == nil && metadata.Kind == "voicemail"
+}
+
+// forwardedMessage builds the copy of source sent by userID to a conversation. Its attachments
+// are new records pointing to the stored objects of the original ones, including processed media.
+func forwardedMessage(source *models.Message, attachments []*models.Attachment, conversationID, userID string, from forwardedFrom, now time.Time) (*models.Message, error) {
+	message := &models.Message{
+		ID:             uuid.New().String(),
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Content:        source.Content,
+		MessageType:    source.MessageType,
+		CreatedAt:      now,
+	}
+
+	// Attachment IDs in the metadata, such as those of processed media, refer to the copies
+	metadata := []byte(source.Metadata)
+	for _, attachment := range attachments {
+		attachmentCopy := *attachment
+		attachmentCopy.ID = uuid.New().String()
+		attachmentCopy.ConversationID = conversationID
+		attachmentCopy.UploaderID = userID
+		attachmentCopy.MessageID = nil
+		attachmentCopy.CreatedAt = now
+		attachmentCopy.Variants = append(attachment.Variants[:0:0], attachment.Variants...)
+		message.Attachments = append(message.Attachments, &attachmentCopy)
+		metadata = bytes.ReplaceAll(metadata, []byte(attachment.ID), []byte(attachmentCopy.ID))
+	}
+
+	var fields map[string]json.RawMessage
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &fields); err != nil {
+			return nil, err
+		}
+	}
+	if fields == nil {
+		fields = make(map[string]json.RawMessage, 1)
+	}
+	value, err := json.Marshal(from)
+	if err != nil {
+		return nil, err
+	}
+	fields["forwarded_from"] = value
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	message.Metadata = datatypes.JSON(encoded)
+	return message, nil
+}
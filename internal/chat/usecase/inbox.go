@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// conversationReadEvent is the payload of a chat.EventConversationRead event.
+type conversationReadEvent struct {
+	UserID    string    `json:"user_id"`
+	MessageID string    `json:"message_id"`
+	ReadAt    time.Time `json:"read_at"`
+}
+
+// messageNotificationEvent is the payload of a chat.EventMessageNotification event.
+type messageNotificationEvent struct {
+	MessageID   string             `json:"message_id"`
+	Snippet     string             `json:"snippet"`
+	MessageType models.MessageType `json:"message_type"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// GetConversationSummaries retrieves the inbox of a user, pinned and then most recently active conversations first.
+func (u *usecase) GetConversationSummaries(ctx context.Context, userID string, filter chat.ConversationFilter) ([]*chat.ConversationSummary, error) {
+	u.logger.Infof(ctx, "Usecase GetConversationSummaries: %s, filter %+v", userID, filter)
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, chat.ErrInvalidUserID
+	}
+	return u.repo.GetConversationSummaries(ctx, userID, filter)
+}
+
+// UpdateConversationSettings changes how the user sees a conversation.
+func (u *usecase) UpdateConversationSettings(ctx context.Context, conversationID, userID string, update *chat.SettingsUpdate) (*models.ConversationParticipant, error) {
+	u.logger.Infof(ctx, "Usecase UpdateConversationSettings: conversationID=%s, userID=%s", conversationID, userID)
+
+	if _, err := uuid.Parse(conversationID); err != nil {
+		return nil, chat.ErrInvalidConversationID
+	}
+	now := time.Now()
+	if update.Muted != nil && *update.Muted && (update.MutedUntil == nil || !update.MutedUntil.After(now)) {
+		return nil, chat.ErrInvalidMuteUntil
+	}
+
+	participant, err := u.repo.UpdateParticipantSettings(ctx, conversationID, userID, update, now)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to update settings of conversation %s for user %s: %v", conversationID, userID, err)
+		return nil, err
+	}
+
+	// Settings are private, so only the other devices of the user hear about them
+	event := &chat.Event{
+		Type:           chat.EventConversationSettings,
+		ConversationID: conversationID,
+		Data:           participant,
+	}
+	if err := u.publisher.PublishToUser(ctx, userID, event); err != nil {
+		u.logger.Errorf(ctx, "Failed to publish settings of conversation %s for user %s: %v", conversationID, userID, err)
+	}
+	return participant, nil
+}
+
+// notifyRecipients sends a notification of a new message to the members who have not muted the conversation.
+// Unlike the message itself it reaches their user topic, so clients that are not watching the conversation see it too.
+// Mentioned members are skipped, as they got a mention event instead.
+func (u *usecase) notifyRecipients(ctx context.Context, message *models.Message, mentions []*models.MessageMention) {
+	recipients, err := u.repo.GetNotificationRecipients(ctx, message.ConversationID, message.SenderID, time.Now())
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to get recipients of message %s: %v", message.ID, err)
+		return
+	}
+	mentioned := make(map[string]bool, len(mentions))
+	for _, mention := range mentions {
+		mentioned[mention.UserID] = true
+	}
+
+	event := &chat.Event{
+		Type:           chat.EventMessageNotification,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Data: messageNotificationEvent{
+			MessageID:   message.ID,
+			Snippet:     snippetOf(message.Content),
+			MessageType: message.MessageType,
+			CreatedAt:   message.CreatedAt,
+		},
+	}
+	for _, userID := range recipients {
+		if mentioned[userID] {
+			continue
+		}
+		if err := u.publisher.PublishToUser(ctx, userID, event); err != nil {
+			u.logger.Errorf(ctx, "Failed to notify %s of message %s: %v", userID, message.ID, err)
+		}
+	}
+}
+
+// snippetOf returns the beginning of a message content shown in notifications.
+func snippetOf(content string) string {
+	snippet := []rune(content)
+	if len(snippet) > chat.PreviewLength {
+		snippet = snippet[:chat.PreviewLength]
+	}
+	return string(snippet)
+}
+
+// MarkConversationRead moves the read position of the user to a message, or to the latest message if messageID is empty.
+func (u *usecase) MarkConversationRead(ctx context.Context, conversationID, userID, messageID string) error {
+	u.logger.Infof(ctx, "Usecase MarkConversationRead: conversationID=%s, userID=%s, messageID=%s", conversationID, userID, messageID)
+
+	if _, err := uuid.Parse(conversationID); err != nil {
+		return chat.ErrInvalidConversationID
+	}
+	if messageID == "" {
+		latest, err := u.repo.GetMessages(ctx, conversationID, nil, 1)
+		if err != nil {
+			return err
+		}
+		if len(latest) == 0 {
+			return nil
+		}
+		messageID = latest[0].ID
+	} else if _, err := uuid.Parse(messageID); err != nil {
+		return chat.ErrInvalidMessageID
+	}
+
+	return u.advanceReadPosition(ctx, conversationID, userID, messageID)
+}
+
+// advanceReadPosition moves the read position forward and tells the conversation, so that
+// senders see their messages read and the other devices of the user clear the unread badge.
+// The senders of the messages read along get their status events too.
+func (u *usecase) advanceReadPosition(ctx context.Context, conversationID, userID, messageID string) error {
+	readAt := time.Now()
+	advanced, read, err := u.repo.MarkConversationRead(ctx, conversationID, userID, messageID, readAt)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to mark conversation %s read up to %s for user %s: %v", conversationID, messageID, userID, err)
+		return err
+	}
+	if !advanced {
+		return nil
+	}
+
+	event := &chat.Event{
+		Type:           chat.EventConversationRead,
+		ConversationID: conversationID,
+		Data: conversationReadEvent{
+			UserID:    userID,
+			MessageID: messageID,
+			ReadAt:    readAt,
+		},
+	}
+	if err := u.publisher.PublishToConversation(ctx, conversationID, event); err != nil {
+		u.logger.Errorf(ctx, "Failed to publish read position of user %s in conversation %s: %v", userID, conversationID, err)
+	}
+
+	for _, message := range read {
+		status := &chat.Event{
+			Type:           chat.EventMessageStatus,
+			ConversationID: conversationID,
+			Data: messageStatusEvent{
+				MessageID: message.ID,
+				UserID:    userID,
+				Status:    models.MessageStatusRead,
+				UpdatedAt: readAt,
+			},
+		}
+		if err := u.publisher.PublishToUser(ctx, message.SenderID, status); err != nil {
+			u.logger.Errorf(ctx, "Failed to publish status of message %s: %v", message.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+	"video-call/pkg/media"
+)
+
+const (
+	defaultMediaWorkers   = 2
+	mediaProcessTimeout   = 2 * time.Minute
+	mediaPollInterval     = 30 * time.Second
+	mediaMaxAttempts      = 5
+	posterVariant         = "poster"
+	thumbnailVariantLabel = "thumb_%d"
+)
+
+// mediaRetryDelay is how long a claimed attachment is left to its worker before another
+// claims it again: the time to wait for a free worker and to process it.
+const mediaRetryDelay = 3 * mediaProcessTimeout
+
+var defaultThumbnailSizes = []int{160, 320, 640}
+
+// errMediaAttemptsExhausted is logged for an attachment claimed after its last attempt.
+var errMediaAttemptsExhausted = errors.New("no attempts left")
+
+// videoExtensions tells ffmpeg which muxer to rewrite a video with.
+var videoExtensions = map[string]string{
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+	"video/quicktime": ".mov",
+}
+
+// mediaJob processes the image and video attachments shared by one message.
+type mediaJob struct {
+	messageID      string
+	conversationID string
+	attachments    []*models.Attachment
+}
+
+// mediaMetadata is stored under the "media" key of a message's Metadata once its attachments are processed.
+type mediaMetadata struct {
+	AttachmentID string                `json:"attachment_id"`
+	ContentType  string                `json:"content_type"`
+	Width        int                   `json:"width,omitempty"`
+	Height       int                   `json:"height,omitempty"`
+	DurationMs   int64                 `json:"duration_ms,omitempty"`
+	BlurHash     string                `json:"blurhash,omitempty"`
+	Variants     []models.MediaVariant `json:"variants,omitempty"`
+	// Failed is set when the pipeline gave up on the attachment, which then stays withheld
+	Failed bool `json:"failed,omitempty"`
+}
+
+// mediaPipeline processes uploaded media in the background so that sending stays fast.
+// The attachments waiting for it are found in the database, so that none is lost to a restart
+// or a burst of uploads; until processed, they are withheld from everyone but their uploader.
+type mediaPipeline struct {
+	u       *usecase
+	wake    chan struct{}
+	jobs    chan mediaJob
+	workers int
+	ffmpeg  *media.FFmpeg
+	sizes   []int
+}
+
+func newMediaPipeline(u *usecase) *mediaPipeline {
+	workers := u.cfg.Media.Workers
+	if workers <= 0 {
+		workers = defaultMediaWorkers
+	}
+	sizes := u.cfg.Media.ThumbnailSizes
+	if len(sizes) == 0 {
+		sizes = defaultThumbnailSizes
+	}
+
+	p := &mediaPipeline{
+		u:       u,
+		wake:    make(chan struct{}, 1),
+		jobs:    make(chan mediaJob),
+		workers: workers,
+		ffmpeg:  media.NewFFmpeg(u.cfg.Media.FFmpegPath, u.cfg.Media.FFprobePath),
+		sizes:   sizes,
+	}
+	go p.dispatch()
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// enqueue wakes the pipeline up if a message shares media that is not processed yet.
+// Other nodes pick it up on their next poll.
+func (p *mediaPipeline) enqueue(message *models.Message) {
+	for _, attachment := range message.Attachments {
+		// Forwarded copies of processed attachments share the renditions of the original
+		if attachment.HasMedia() && attachment.ProcessedAt == nil {
+			select {
+			case p.wake <- struct{}{}:
+			default:
+			}
+			return
+		}
+	}
+}
+
+// dispatch hands the unprocessed attachments over to the workers, message by message.
+func (p *mediaPipeline) dispatch() {
+	ticker := time.NewTicker(mediaPollInterval)
+	defer ticker.Stop()
+	for {
+		// Claim no more than the workers take on, so that claims do not expire while waiting
+		for p.claim() == p.workers {
+		}
+		select {
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim claims a batch of unprocessed attachments and returns how many it handed over.
+func (p *mediaPipeline) claim() int {
+	ctx := context.Background()
+	typePrefixes := []string{"image/"}
+	// Without ffmpeg videos wait for a node that has it
+	if p.ffmpeg.Available() {
+		typePrefixes = append(typePrefixes, "video/")
+	}
+	attachments, err := p.u.repo.ClaimUnprocessedAttachments(ctx, typePrefixes, time.Now().Add(-mediaRetryDelay), p.workers)
+	if err != nil {
+		p.u.logger.Errorf(ctx, "Failed to claim unprocessed attachments: %v", err)
+		return 0
+	}
+
+	var jobs []mediaJob
+	byMessage := make(map[string]int, len(attachments))
+	for _, attachment := range attachments {
+		i, ok := byMessage[*attachment.MessageID]
+		if !ok {
+			i = len(jobs)
+			byMessage[*attachment.MessageID] = i
+			jobs = append(jobs, mediaJob{messageID: *attachment.MessageID, conversationID: attachment.ConversationID})
+		}
+		jobs[i].attachments = append(jobs[i].attachments, attachment)
+	}
+	for _, job := range jobs {
+		p.jobs <- job
+	}
+	return len(attachments)
+}
+
+func (p *mediaPipeline) worker() {
+	for job := range p.jobs {
+		ctx, cancel := context.WithTimeout(context.Background(), mediaProcessTimeout)
+		p.process(ctx, job)
+		cancel()
+	}
+}
+
+// process processes the claimed attachments of a message. Failed ones are retried by a later
+// claim; after mediaMaxAttempts the attachment is marked failed, which the message metadata shows.
+// An attachment claimed once more was lost to a crash on its last attempt and fails right away.
+func (p *mediaPipeline) process(ctx context.Context, job mediaJob) {
+	settled := 0
+	for _, attachment := range job.attachments {
+		err := errMediaAttemptsExhausted
+		if attachment.MediaAttempts <= mediaMaxAttempts {
+			if attachment.MessageType() == models.MessageTypeVideo {
+				err = p.processVideo(ctx, attachment)
+			} else {
+				err = p.processImage(ctx, attachment)
+			}
+		}
+		now := time.Now()
+		if err != nil {
+			p.u.logger.Errorf(ctx, "Failed to process attachment %s (attempt %d): %v", attachment.ID, attachment.MediaAttempts, err)
+			if attachment.MediaAttempts < mediaMaxAttempts {
+				continue
+			}
+			if err := p.u.repo.FailAttachmentMedia(ctx, attachment.ID, now); err != nil {
+				p.u.logger.Errorf(ctx, "Failed to mark attachment %s as failed: %v", attachment.ID, err)
+				continue
+			}
+			settled++
+			continue
+		}
+
+		attachment.ProcessedAt = &now
+		if err := p.u.repo.UpdateAttachmentMedia(ctx, attachment); err != nil {
+			p.u.logger.Errorf(ctx, "Failed to save processed attachment %s: %v", attachment.ID, err)
+			continue
+		}
+		settled++
+	}
+	if settled == 0 {
+		return
+	}
+
+	// The attachments of a message may be processed in several jobs; each one describes them all
+	attachments, err := p.u.repo.GetAttachmentsByMessageIDs(ctx, []string{job.messageID})
+	if err != nil {
+		p.u.logger.Errorf(ctx, "Failed to get attachments of message %s: %v", job.messageID, err)
+		return
+	}
+	results := make([]mediaMetadata, 0, len(attachments))
+	for _, attachment := range attachments {
+		switch {
+		case !attachment.HasMedia():
+		case attachment.ProcessedAt != nil:
+			results = append(results, mediaMetadata{
+				AttachmentID: attachment.ID,
+				ContentType:  attachment.ContentType,
+				Width:        attachment.Width,
+				Height:       attachment.Height,
+				DurationMs:   attachment.DurationMs,
+				BlurHash:     attachment.BlurHash,
+				Variants:     attachment.Variants,
+			})
+		case attachment.MediaFailedAt != nil:
+			results = append(results, mediaMetadata{
+				AttachmentID: attachment.ID,
+				ContentType:  attachment.ContentType,
+				Failed:       true,
+			})
+		}
+	}
+
+	metadata, err := json.Marshal(map[string]any{"media": results})
+	if err != nil {
+		p.u.logger.Errorf(ctx, "Failed to encode media metadata of message %s: %v", job.messageID, err)
+		return
+	}
+	message, err := p.u.repo.MergeMessageMetadata(ctx, job.messageID, metadata)
+	if err != nil {
+		p.u.logger.Errorf(ctx, "Failed to update metadata of message %s: %v", job.messageID, err)
+		return
+	}
+
+	event := &chat.Event{
+		Type:           chat.EventMessageUpdated,
+		ConversationID: job.conversationID,
+		Data:           message,
+	}
+	if err := p.u.publisher.PublishToConversation(ctx, job.conversationID, event); err != nil {
+		p.u.logger.Errorf(ctx, "Failed to publish processed media of message %s: %v", job.messageID, err)
+	}
+}
+
+// processImage replaces the original by a copy without EXIF and stores its thumbnails.
+func (p *mediaPipeline) processImage(ctx context.Context, attachment *models.Attachment) error {
+	rc, err := p.u.store.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return err
+	}
+	result, err := media.ProcessImage(rc, p.sizes)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+
+	if result.Stripped != nil {
+		if err := p.put(ctx, attachment.StorageKey, result.Stripped, attachment.ContentType); err != nil {
+			return err
+		}
+		attachment.SizeBytes = int64(len(result.Stripped))
+	}
+	return p.storeRenditions(ctx, attachment, result)
+}
+
+// processVideo strips container metadata, measures the duration and renders the first frame.
+func (p *mediaPipeline) processVideo(ctx context.Context, attachment *models.Attachment) error {
+	if !p.ffmpeg.Available() {
+		return media.ErrToolUnavailable
+	}
+
+	dir, err := os.MkdirTemp("", "media-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	src := dir + "/original"
+	if err := p.download(ctx, attachment.StorageKey, src); err != nil {
+		return err
+	}
+
+	// A video whose duration cannot be told is still stripped and shown, without one
+	duration, err := p.ffmpeg.Duration(ctx, src)
+	if err != nil && !errors.Is(err, media.ErrNoDuration) {
+		return err
+	}
+	attachment.DurationMs = duration.Milliseconds()
+
+	// Keep the container format: ffmpeg picks the muxer from the extension.
+	if ext, ok := videoExtensions[attachment.ContentType]; ok {
+		stripped := dir + "/stripped" + ext
+		if err := p.ffmpeg.StripMetadata(ctx, src, stripped); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(stripped)
+		if err != nil {
+			return err
+		}
+		if err := p.put(ctx, attachment.StorageKey, data, attachment.ContentType); err != nil {
+			return err
+		}
+		attachment.SizeBytes = int64(len(data))
+	}
+
+	frame, err := p.ffmpeg.FirstFrame(ctx, src)
+	if err != nil {
+		return err
+	}
+	poster, err := media.EncodeJPEG(frame)
+	if err != nil {
+		return err
+	}
+	if err := p.put(ctx, variantKey(attachment, posterVariant), poster, "image/jpeg"); err != nil {
+		return err
+	}
+
+	result, err := media.ProcessFrame(frame, p.sizes)
+	if err != nil {
+		return err
+	}
+	attachment.Variants = append(attachment.Variants[:0], models.MediaVariant{
+		Name:   posterVariant,
+		Width:  result.Width,
+		Height: result.Height,
+	})
+	return p.storeRenditions(ctx, attachment, result)
+}
+
+// storeRenditions stores the thumbnails of a processed image and records them on the attachment.
+func (p *mediaPipeline) storeRenditions(ctx context.Context, attachment *models.Attachment, result *media.ImageResult) error {
+	attachment.Width = result.Width
+	attachment.Height = result.Height
+	attachment.BlurHash = result.BlurHash
+	for _, thumbnail := range result.Thumbnails {
+		name := fmt.Sprintf(thumbnailVariantLabel, thumbnail.Size)
+		if err := p.put(ctx, variantKey(attachment, name), thumbnail.Data, "image/jpeg"); err != nil {
+			return err
+		}
+		attachment.Variants = append(attachment.Variants, models.MediaVariant{
+			Name:   name,
+			Width:  thumbnail.Width,
+			Height: thumbnail.Height,
+		})
+	}
+	return nil
+}
+
+func (p *mediaPipeline) put(ctx context.Context, key string, data []byte, contentType string) error {
+	return p.u.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+func (p *mediaPipeline) download(ctx context.Context, key, path string) error {
+	rc, err := p.u.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,288 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// membersAddedEvent is the payload of a chat.EventMemberAdded event.
+type membersAddedEvent struct {
+	Members []*models.ConversationParticipant `json:"members"`
+	AddedBy string                            `json:"added_by"`
+}
+
+// memberRemovedEvent is the payload of a chat.EventMemberRemoved event.
+// RemovedBy equals UserID when the member left on their own.
+type memberRemovedEvent struct {
+	UserID    string `json:"user_id"`
+	RemovedBy string `json:"removed_by"`
+}
+
+// memberRoleUpdatedEvent is the payload of a chat.EventMemberRoleUpdated event.
+type memberRoleUpdatedEvent struct {
+	UserID    string                 `json:"user_id"`
+	Role      models.ParticipantRole `json:"role"`
+	UpdatedBy string                 `json:"updated_by"`
+}
+
+// conversationDeletedEvent is the payload of a chat.EventConversationDeleted event.
+type conversationDeletedEvent struct {
+	DeletedBy string `json:"deleted_by"`
+}
+
+// GetMembers retrieves the members of a conversation with their roles in joining order.
+func (u *usecase) GetMembers(ctx context.Context, conversationID string) ([]*models.ConversationParticipant, error) {
+	u.logger.Infof(ctx, "Usecase GetMembers: conversationID=%s", conversationID)
+
+	if _, err := uuid.Parse(conversationID); err != nil {
+		return nil, chat.ErrInvalidConversationID
+	}
+	return u.repo.GetParticipants(ctx, conversationID)
+}
+
+// AddMembers adds users to a group on behalf of an owner or admin.
+func (u *usecase) AddMembers(ctx context.Context, conversationID, userID string, memberIDs []string) ([]*models.ConversationParticipant, error) {
+	u.logger.Infof(ctx, "Usecase AddMembers: conversationID=%s, userID=%s, members=%v", conversationID, userID, memberIDs)
+
+	for _, memberID := range memberIDs {
+		if _, err := uuid.Parse(memberID); err != nil {
+			return nil, chat.ErrInvalidUserID
+		}
+	}
+	actor, err := u.getGroupParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Role.CanModerate() {
+		return nil, chat.ErrNotAllowed
+	}
+
+	var added []*models.ConversationParticipant
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		added, err = u.repo.AddParticipants(ctx, conversationID, memberIDs, models.ParticipantRoleMember)
+		if err != nil || len(added) == 0 {
+			return err
+		}
+		return u.publishMembersAdded(ctx, conversationID, userID, added)
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to add members to conversation %s: %v", conversationID, err)
+		return nil, err
+	}
+	return added, nil
+}
+
+// RemoveMember removes a member from a group on behalf of someone who outranks them.
+func (u *usecase) RemoveMember(ctx context.Context, conversationID, userID, memberID string) error {
+	u.logger.Infof(ctx, "Usecase RemoveMember: conversationID=%s, userID=%s, memberID=%s", conversationID, userID, memberID)
+
+	if memberID == userID {
+		return u.LeaveConversation(ctx, conversationID, userID)
+	}
+	if _, err := uuid.Parse(memberID); err != nil {
+		return chat.ErrInvalidUserID
+	}
+	actor, err := u.getGroupParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	member, err := u.repo.GetParticipant(ctx, conversationID, memberID)
+	if err != nil {
+		return err
+	}
+	if !actor.Role.CanModerate() || !actor.Role.Outranks(member.Role) {
+		return chat.ErrNotAllowed
+	}
+
+	return u.removeMember(ctx, conversationID, memberID, userID)
+}
+
+// LeaveConversation removes the user from a group.
+func (u *usecase) LeaveConversation(ctx context.Context, conversationID, userID string) error {
+	u.logger.Infof(ctx, "Usecase LeaveConversation: conversationID=%s, userID=%s", conversationID, userID)
+
+	actor, err := u.getGroupParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if actor.Role == models.ParticipantRoleOwner {
+		members, err := u.repo.GetParticipants(ctx, conversationID)
+		if err != nil {
+			return err
+		}
+		if len(members) > 1 {
+			return chat.ErrOwnerCannotLeave
+		}
+		// Nobody is left to take over, so the group goes away with its last member
+		return u.deleteConversation(ctx, conversationID, userID)
+	}
+
+	return u.removeMember(ctx, conversationID, userID, userID)
+}
+
+// UpdateMemberRole promotes a member to admin or demotes an admin on behalf of the owner.
+func (u *usecase) UpdateMemberRole(ctx context.Context, conversationID, userID, memberID string, role models.ParticipantRole) error {
+	u.logger.Infof(ctx, "Usecase UpdateMemberRole: conversationID=%s, userID=%s, memberID=%s, role=%s", conversationID, userID, memberID, role)
+
+	if role != models.ParticipantRoleAdmin && role != models.ParticipantRoleMember {
+		return chat.ErrNotAllowed
+	}
+	if _, err := uuid.Parse(memberID); err != nil {
+		return chat.ErrInvalidUserID
+	}
+	actor, err := u.getGroupParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if actor.Role != models.ParticipantRoleOwner || memberID == userID {
+		return chat.ErrNotAllowed
+	}
+
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := u.repo.UpdateParticipantRole(ctx, conversationID, memberID, role); err != nil {
+			return err
+		}
+		return u.publishRoleUpdated(ctx, conversationID, memberID, role, userID)
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to update role of %s in conversation %s: %v", memberID, conversationID, err)
+		return err
+	}
+	return nil
+}
+
+// TransferOwnership hands the ownership of a group to another member.
+func (u *usecase) TransferOwnership(ctx context.Context, conversationID, userID, newOwnerID string) error {
+	u.logger.Infof(ctx, "Usecase TransferOwnership: conversationID=%s, userID=%s, newOwnerID=%s", conversationID, userID, newOwnerID)
+
+	if _, err := uuid.Parse(newOwnerID); err != nil {
+		return chat.ErrInvalidUserID
+	}
+	actor, err := u.getGroupParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if actor.Role != models.ParticipantRoleOwner || newOwnerID == userID {
+		return chat.ErrNotAllowed
+	}
+
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := u.repo.TransferOwnership(ctx, conversationID, userID, newOwnerID); err != nil {
+			return err
+		}
+		if err := u.publishRoleUpdated(ctx, conversationID, newOwnerID, models.ParticipantRoleOwner, userID); err != nil {
+			return err
+		}
+		return u.publishRoleUpdated(ctx, conversationID, userID, models.ParticipantRoleAdmin, userID)
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to transfer ownership of conversation %s: %v", conversationID, err)
+		return err
+	}
+	return nil
+}
+
+// getGroupParticipant retrieves the membership of the user in a group conversation.
+func (u *usecase) getGroupParticipant(ctx context.Context, conversationID, userID string) (*models.ConversationParticipant, error) {
+	if _, err := uuid.Parse(conversationID); err != nil {
+		return nil, chat.ErrInvalidConversationID
+	}
+	conversation, err := u.repo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !conversation.IsGroup {
+		return nil, chat.ErrNotGroupConversation
+	}
+	return u.repo.GetParticipant(ctx, conversationID, userID)
+}
+
+// removeMember removes a member and tells the subscribers, including the member's own connections.
+func (u *usecase) removeMember(ctx context.Context, conversationID, memberID, removedBy string) error {
+	err := u.repo.Transaction(ctx, func(ctx context.Context) error {
+		removed, err := u.repo.RemoveParticipant(ctx, conversationID, memberID)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return chat.ErrParticipantNotFound
+		}
+
+		// The event has no sender so that the connections of a leaving member receive it too
+		// and drop their subscription to the conversation.
+		return u.outbox.PublishToConversation(ctx, conversationID, &chat.Event{
+			Type:           chat.EventMemberRemoved,
+			ConversationID: conversationID,
+			Data: memberRemovedEvent{
+				UserID:    memberID,
+				RemovedBy: removedBy,
+			},
+		})
+	})
+	if err != nil && !errors.Is(err, chat.ErrParticipantNotFound) {
+		u.logger.Errorf(ctx, "Failed to remove %s from conversation %s: %v", memberID, conversationID, err)
+	}
+	return err
+}
+
+// deleteConversation deletes a conversation and tells its subscribers, who drop their subscriptions.
+func (u *usecase) deleteConversation(ctx context.Context, conversationID, deletedBy string) error {
+	err := u.repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := u.repo.DeleteConversation(ctx, conversationID); err != nil {
+			return err
+		}
+		return u.outbox.PublishToConversation(ctx, conversationID, &chat.Event{
+			Type:           chat.EventConversationDeleted,
+			ConversationID: conversationID,
+			Data:           conversationDeletedEvent{DeletedBy: deletedBy},
+		})
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to delete conversation %s: %v", conversationID, err)
+		return err
+	}
+	return nil
+}
+
+// publishMembersAdded tells the subscribers of a conversation and the new members about them.
+// New members are not subscribed to the conversation yet, so they are reached on their own topics.
+// The events go through the outbox; ctx should carry the transaction adding the members.
+func (u *usecase) publishMembersAdded(ctx context.Context, conversationID, addedBy string, members []*models.ConversationParticipant) error {
+	event := &chat.Event{
+		Type:           chat.EventMemberAdded,
+		ConversationID: conversationID,
+		Data: membersAddedEvent{
+			Members: members,
+			AddedBy: addedBy,
+		},
+	}
+	if err := u.outbox.PublishToConversation(ctx, conversationID, event); err != nil {
+		return err
+	}
+	for _, member := range members {
+		if err := u.outbox.PublishToUser(ctx, member.UserID, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishRoleUpdated tells the subscribers of a conversation about a new role of a member.
+// The event goes through the outbox; ctx should carry the transaction changing the role.
+func (u *usecase) publishRoleUpdated(ctx context.Context, conversationID, memberID string, role models.ParticipantRole, updatedBy string) error {
+	return u.outbox.PublishToConversation(ctx, conversationID, &chat.Event{
+		Type:           chat.EventMemberRoleUpdated,
+		ConversationID: conversationID,
+		Data: memberRoleUpdatedEvent{
+			UserID:    memberID,
+			Role:      role,
+			UpdatedBy: updatedBy,
+		},
+	})
+}
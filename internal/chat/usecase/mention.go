@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// mentionEvent is the payload of a chat.EventMention event.
+type mentionEvent struct {
+	MessageID   string             `json:"message_id"`
+	Snippet     string             `json:"snippet"`
+	MessageType models.MessageType `json:"message_type"`
+	Everyone    bool               `json:"everyone"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// GetMentions retrieves a page of the recent mentions of a user, newest first.
+func (u *usecase) GetMentions(ctx context.Context, userID, cursor string, limit int) (*chat.MentionPage, error) {
+	u.logger.Infof(ctx, "Usecase GetMentions: userID=%s", userID)
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, chat.ErrInvalidUserID
+	}
+	if limit <= 0 || limit > chat.MaxMentionPageSize {
+		limit = chat.DefaultMentionPageSize
+	}
+	var after *chat.MentionCursor
+	if cursor != "" {
+		decoded, err := chat.DecodeMentionCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = decoded
+	}
+
+	// Fetch one extra row to learn whether another page exists.
+	mentions, err := u.repo.GetMentions(ctx, userID, after, limit+1)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to get mentions of user %s: %v", userID, err)
+		return nil, err
+	}
+	page := &chat.MentionPage{}
+	if len(mentions) > limit {
+		mentions = mentions[:limit]
+		page.NextCursor = chat.NewMentionCursor(mentions[limit-1]).Encode()
+	}
+
+	ids := make([]string, len(mentions))
+	for i, mention := range mentions {
+		ids[i] = mention.MessageID
+	}
+	messages, err := u.repo.GetMessagesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.decorateMessages(ctx, messages, userID); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Message, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+	}
+	for _, mention := range mentions {
+		if message, ok := byID[mention.MessageID]; ok {
+			page.Mentions = append(page.Mentions, &chat.Mention{Mention: mention, Message: message})
+		}
+	}
+	return page, nil
+}
+
+// resolveMentions finds the members of the conversation a message mentions by username or by @all.
+// Names that are not members, and the sender, are not mentioned.
+func (u *usecase) resolveMentions(ctx context.Context, message *models.Message) ([]*models.MessageMention, error) {
+	usernames, all := chat.ParseMentions(message.Content)
+	if len(usernames) == 0 && !all {
+		return nil, nil
+	}
+
+	participants, err := u.repo.GetParticipants(ctx, message.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[string]bool, len(participants))
+	for _, participant := range participants {
+		members[participant.UserID] = true
+	}
+	delete(members, message.SenderID)
+
+	var mentions []*models.MessageMention
+	byName := make(map[string]bool)
+	if len(usernames) > 0 {
+		users, err := u.users.GetUsersByUsernames(ctx, usernames)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			if !members[user.ID] || byName[user.ID] {
+				continue
+			}
+			byName[user.ID] = true
+			mentions = append(mentions, newMention(message, user.ID, false))
+		}
+	}
+	if all {
+		for _, participant := range participants {
+			if members[participant.UserID] && !byName[participant.UserID] {
+				mentions = append(mentions, newMention(message, participant.UserID, true))
+			}
+		}
+	}
+	return mentions, nil
+}
+
+func newMention(message *models.Message, userID string, everyone bool) *models.MessageMention {
+	return &models.MessageMention{
+		MessageID:      message.ID,
+		UserID:         userID,
+		ConversationID: message.ConversationID,
+		Everyone:       everyone,
+		CreatedAt:      message.CreatedAt,
+	}
+}
+
+// storeMentions records the mentions of a stored message and tells each mentioned user,
+// whether or not they muted the conversation. ctx should carry the transaction storing the message.
+func (u *usecase) storeMentions(ctx context.Context, message *models.Message, mentions []*models.MessageMention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	if err := u.repo.CreateMentions(ctx, mentions); err != nil {
+		return err
+	}
+	for _, mention := range mentions {
+		err := u.outbox.PublishToUser(ctx, mention.UserID, &chat.Event{
+			Type:           chat.EventMention,
+			ConversationID: message.ConversationID,
+			SenderID:       message.SenderID,
+			Data: mentionEvent{
+				MessageID:   message.ID,
+				Snippet:     snippetOf(message.Content),
+				MessageType: message.MessageType,
+				Everyone:    mention.Everyone,
+				CreatedAt:   message.CreatedAt,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
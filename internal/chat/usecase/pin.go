@@ -0,0 +1,182 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// messagePinnedEvent is the payload of a chat.EventMessagePinned event.
+type messagePinnedEvent struct {
+	MessageID string    `json:"message_id"`
+	PinnedBy  string    `json:"pinned_by"`
+	PinnedAt  time.Time `json:"pinned_at"`
+}
+
+// messageUnpinnedEvent is the payload of a chat.EventMessageUnpinned event.
+type messageUnpinnedEvent struct {
+	MessageID  string `json:"message_id"`
+	UnpinnedBy string `json:"unpinned_by"`
+}
+
+// PinMessage pins a message to its conversation on behalf of an owner or admin of a group,
+// or of either participant of a direct conversation. Pinning a pinned message returns its pin.
+func (u *usecase) PinMessage(ctx context.Context, conversationID, messageID, userID string) (*models.PinnedMessage, error) {
+	u.logger.Infof(ctx, "Usecase PinMessage: conversationID=%s, messageID=%s, userID=%s", conversationID, messageID, userID)
+
+	if err := u.checkCanPin(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+	message, err := u.getConversationMessage(ctx, conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.IsDeleted() {
+		return nil, chat.ErrMessageDeleted
+	}
+
+	var pin *models.PinnedMessage
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		current, pinned, err := u.repo.PinMessage(ctx, &models.PinnedMessage{
+			MessageID:      message.ID,
+			ConversationID: conversationID,
+			PinnedBy:       &userID,
+			PinnedAt:       time.Now(),
+		}, u.maxPinnedMessages())
+		if err != nil {
+			return err
+		}
+		pin = current
+		if !pinned {
+			return nil
+		}
+		return u.outbox.PublishToConversation(ctx, conversationID, &chat.Event{
+			Type:           chat.EventMessagePinned,
+			ConversationID: conversationID,
+			Data: messagePinnedEvent{
+				MessageID: pin.MessageID,
+				PinnedBy:  userID,
+				PinnedAt:  pin.PinnedAt,
+			},
+		})
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to pin message %s: %v", messageID, err)
+		return nil, err
+	}
+	return pin, nil
+}
+
+// UnpinMessage removes the pin of a message on behalf of someone who may pin messages.
+func (u *usecase) UnpinMessage(ctx context.Context, conversationID, messageID, userID string) error {
+	u.logger.Infof(ctx, "Usecase UnpinMessage: conversationID=%s, messageID=%s, userID=%s", conversationID, messageID, userID)
+
+	if err := u.checkCanPin(ctx, conversationID, userID); err != nil {
+		return err
+	}
+	message, err := u.getConversationMessage(ctx, conversationID, messageID)
+	if err != nil {
+		return err
+	}
+
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		return u.unpinMessage(ctx, message, userID, true)
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to unpin message %s: %v", messageID, err)
+		return err
+	}
+	return nil
+}
+
+// GetPinnedMessages retrieves the pinned messages of a conversation, most recently pinned first.
+func (u *usecase) GetPinnedMessages(ctx context.Context, conversationID, viewerID string) ([]*chat.PinnedMessage, error) {
+	u.logger.Infof(ctx, "Usecase GetPinnedMessages: conversationID=%s", conversationID)
+
+	if _, err := uuid.Parse(conversationID); err != nil {
+		return nil, chat.ErrInvalidConversationID
+	}
+	pins, err := u.repo.GetPinnedMessages(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(pins))
+	for i, pin := range pins {
+		ids[i] = pin.MessageID
+	}
+	messages, err := u.repo.GetMessagesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.decorateMessages(ctx, messages, viewerID); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Message, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+	}
+
+	// Pins of expired messages stay hidden until the purge deletes them
+	pinned := make([]*chat.PinnedMessage, 0, len(pins))
+	for _, pin := range pins {
+		if message, ok := byID[pin.MessageID]; ok {
+			pinned = append(pinned, &chat.PinnedMessage{Pin: pin, Message: message})
+		}
+	}
+	return pinned, nil
+}
+
+// unpinMessage removes the pin of a message and tells the subscribers; ctx should carry the transaction.
+// Unless required, a message that is not pinned is left alone.
+func (u *usecase) unpinMessage(ctx context.Context, message *models.Message, userID string, required bool) error {
+	unpinned, err := u.repo.UnpinMessage(ctx, message.ID)
+	if err != nil {
+		return err
+	}
+	if !unpinned {
+		if required {
+			return chat.ErrPinNotFound
+		}
+		return nil
+	}
+	return u.outbox.PublishToConversation(ctx, message.ConversationID, &chat.Event{
+		Type:           chat.EventMessageUnpinned,
+		ConversationID: message.ConversationID,
+		Data: messageUnpinnedEvent{
+			MessageID:  message.ID,
+			UnpinnedBy: userID,
+		},
+	})
+}
+
+// checkCanPin checks that the user may pin messages to a conversation.
+func (u *usecase) checkCanPin(ctx context.Context, conversationID, userID string) error {
+	if _, err := uuid.Parse(conversationID); err != nil {
+		return chat.ErrInvalidConversationID
+	}
+	conversation, err := u.repo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	actor, err := u.repo.GetParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if conversation.IsGroup && !actor.Role.CanModerate() {
+		return chat.ErrNotAllowed
+	}
+	return nil
+}
+
+// maxPinnedMessages is the number of pins a conversation may have.
+func (u *usecase) maxPinnedMessages() int {
+	if u.cfg.Chat.MaxPinnedMessages > 0 {
+		return u.cfg.Chat.MaxPinnedMessages
+	}
+	return chat.DefaultMaxPinnedMessages
+}
@@ -0,0 +1,221 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// presenceTTL is how long a connection counts as live after its last heartbeat.
+	presenceTTL = 2 * chat.PresenceHeartbeat
+	// presenceSweepBatch is the most expired users announced per sweep call
+	presenceSweepBatch = 100
+)
+
+// typingEvent is the payload of typing.start and typing.stop events.
+type typingEvent struct {
+	UserID    string     `json:"user_id"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// StartTyping tells the other participants that the user is typing.
+func (u *usecase) StartTyping(ctx context.Context, conversationID, userID string) error {
+	expiresAt := time.Now().Add(chat.TypingTimeout)
+	return u.publisher.PublishToConversation(ctx, conversationID, &chat.Event{
+		Type:           chat.EventTypingStart,
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Data:           typingEvent{UserID: userID, ExpiresAt: &expiresAt},
+	})
+}
+
+// StopTyping tells the other participants that the user stopped typing.
+func (u *usecase) StopTyping(ctx context.Context, conversationID, userID string) error {
+	return u.publisher.PublishToConversation(ctx, conversationID, &chat.Event{
+		Type:           chat.EventTypingStop,
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Data:           typingEvent{UserID: userID},
+	})
+}
+
+// UpdatePresence refreshes the state of one connection of a user.
+func (u *usecase) UpdatePresence(ctx context.Context, userID, connectionID string, status models.PresenceStatus) error {
+	return u.changePresence(ctx, userID, func() error {
+		return u.redisRepo.SetConnectionPresence(ctx, userID, connectionID, status, presenceTTL)
+	})
+}
+
+// RemovePresence forgets a closed connection of a user.
+func (u *usecase) RemovePresence(ctx context.Context, userID, connectionID string) error {
+	return u.changePresence(ctx, userID, func() error {
+		return u.redisRepo.RemoveConnectionPresence(ctx, userID, connectionID, time.Now())
+	})
+}
+
+// GetPresence retrieves the aggregated presence of the given users. Only users sharing
+// a conversation with the viewer, and the viewer themselves, are visible to them.
+func (u *usecase) GetPresence(ctx context.Context, viewerID string, userIDs []string) ([]*models.UserPresence, error) {
+	u.logger.Infof(ctx, "Usecase GetPresence: viewerID=%s, userIDs=%v", viewerID, userIDs)
+
+	for _, userID := range userIDs {
+		if _, err := uuid.Parse(userID); err != nil {
+			return nil, chat.ErrInvalidUserID
+		}
+	}
+	contacts, err := u.repo.GetContactIDs(ctx, viewerID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[string]bool, len(contacts)+1)
+	visible[viewerID] = true
+	for _, contact := range contacts {
+		visible[contact] = true
+	}
+
+	presences := make([]*models.UserPresence, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if !visible[userID] {
+			continue
+		}
+		presence, err := u.redisRepo.GetPresence(ctx, userID)
+		if err != nil {
+			u.logger.Errorf(ctx, "Failed to get presence of user %s: %v", userID, err)
+			return nil, err
+		}
+		presences = append(presences, presence)
+	}
+	return presences, nil
+}
+
+// changePresence applies update and notifies the user's conversation peers
+// when the aggregated presence of the user changed.
+func (u *usecase) changePresence(ctx context.Context, userID string, update func() error) error {
+	before, err := u.redisRepo.GetPresence(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := update(); err != nil {
+		return err
+	}
+	after, err := u.redisRepo.GetPresence(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if before.Status == after.Status {
+		return nil
+	}
+	return u.publishPresence(ctx, after)
+}
+
+// SweepPresences announces users going offline because their connections expired without
+// being closed, e.g. when their node went away. It returns how many users it checked.
+func (u *usecase) SweepPresences(ctx context.Context, limit int) (int, error) {
+	userIDs, err := u.redisRepo.ClaimExpiredPresences(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+	for _, userID := range userIDs {
+		presence, err := u.redisRepo.GetPresence(ctx, userID)
+		if err != nil {
+			return 0, err
+		}
+		// A connection that came back in the meantime keeps the user online
+		if presence.Status != models.PresenceOffline {
+			continue
+		}
+		if err := u.publishPresence(ctx, presence); err != nil {
+			return 0, err
+		}
+	}
+	return len(userIDs), nil
+}
+
+// publishPresence notifies the conversation peers of a user about the user's presence.
+func (u *usecase) publishPresence(ctx context.Context, presence *models.UserPresence) error {
+	conversations, err := u.repo.GetConversationsByUserID(ctx, presence.UserID)
+	if err != nil {
+		return err
+	}
+	for _, conversation := range conversations {
+		event := &chat.Event{
+			Type:           chat.EventPresenceUpdate,
+			ConversationID: conversation.ID,
+			SenderID:       presence.UserID,
+			Data:           presence,
+		}
+		if err := u.publisher.PublishToConversation(ctx, conversation.ID, event); err != nil {
+			u.logger.Errorf(ctx, "Failed to publish presence of user %s: %v", presence.UserID, err)
+		}
+	}
+	return nil
+}
+
+type PresenceSweeper interface {
+	SweepPresences(ctx context.Context, limit int) (int, error)
+}
+
+// PresenceTracker periodically announces users whose connections expired without being closed.
+// Every node runs one; each expired user is announced by one of them.
+type PresenceTracker struct {
+	uc PresenceSweeper
+
+	stop    context.CancelFunc
+	stopped context.Context
+	wg      sync.WaitGroup
+}
+
+// NewPresenceTracker is the constructor for PresenceTracker. It starts sweeping right away.
+func NewPresenceTracker(uc PresenceSweeper) *PresenceTracker {
+	t := &PresenceTracker{uc: uc}
+	t.stopped, t.stop = context.WithCancel(context.Background())
+	t.wg.Add(1)
+	go t.run()
+	return t
+}
+
+// Close stops the tracker after the sweep at hand, or when ctx is done.
+func (t *PresenceTracker) Close(ctx context.Context) error {
+	t.stop()
+	drained := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *PresenceTracker) run() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(chat.PresenceHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stopped.Done():
+			return
+		case <-ticker.C:
+		}
+		for t.stopped.Err() == nil {
+			n, err := t.uc.SweepPresences(context.Background(), presenceSweepBatch)
+			if err != nil {
+				log.Printf("[Presence Tracker] Failed to sweep expired presences: %v", err)
+				break
+			}
+			if n < presenceSweepBatch {
+				break
+			}
+		}
+	}
+}
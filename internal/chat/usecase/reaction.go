@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+)
+
+// maxEmojiLength bounds a reaction in bytes; long ZWJ sequences such as family emoji fit.
+const maxEmojiLength = 64
+
+// reactionEvent is the payload of chat.EventReactionAdd and chat.EventReactionRemove events.
+type reactionEvent struct {
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user_id"`
+	Emoji     string `json:"emoji"`
+}
+
+// AddReaction reacts to a message with an emoji.
+func (u *usecase) AddReaction(ctx context.Context, conversationID, messageID, userID, emoji string) error {
+	u.logger.Infof(ctx, "Usecase AddReaction: conversationID=%s, messageID=%s, userID=%s, emoji=%s", conversationID, messageID, userID, emoji)
+
+	if !isValidEmoji(emoji) {
+		return chat.ErrInvalidEmoji
+	}
+	message, err := u.getConversationMessage(ctx, conversationID, messageID)
+	if err != nil {
+		return err
+	}
+	if message.IsDeleted() {
+		return chat.ErrMessageDeleted
+	}
+
+	added, err := u.repo.AddReaction(ctx, &models.MessageReaction{
+		MessageID: messageID,
+		UserID:    userID,
+		Emoji:     emoji,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to add reaction to message %s: %v", messageID, err)
+		return err
+	}
+	if added {
+		u.publishReaction(ctx, chat.EventReactionAdd, conversationID, messageID, userID, emoji)
+	}
+	return nil
+}
+
+// RemoveReaction withdraws a reaction of a user from a message.
+func (u *usecase) RemoveReaction(ctx context.Context, conversationID, messageID, userID, emoji string) error {
+	u.logger.Infof(ctx, "Usecase RemoveReaction: conversationID=%s, messageID=%s, userID=%s, emoji=%s", conversationID, messageID, userID, emoji)
+
+	if !isValidEmoji(emoji) {
+		return chat.ErrInvalidEmoji
+	}
+	if _, err := u.getConversationMessage(ctx, conversationID, messageID); err != nil {
+		return err
+	}
+
+	removed, err := u.repo.RemoveReaction(ctx, messageID, userID, emoji)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to remove reaction from message %s: %v", messageID, err)
+		return err
+	}
+	if removed {
+		u.publishReaction(ctx, chat.EventReactionRemove, conversationID, messageID, userID, emoji)
+	}
+	return nil
+}
+
+func (u *usecase) publishReaction(ctx context.Context, eventType, conversationID, messageID, userID, emoji string) {
+	event := &chat.Event{
+		Type:           eventType,
+		ConversationID: conversationID,
+		Data: reactionEvent{
+			MessageID: messageID,
+			UserID:    userID,
+			Emoji:     emoji,
+		},
+	}
+	if err := u.publisher.PublishToConversation(ctx, conversationID, event); err != nil {
+		u.logger.Errorf(ctx, "Failed to publish reaction on message %s: %v", messageID, err)
+	}
+}
+
+// attachReactions fills in the aggregated reactions of the messages as seen by viewerID.
+func (u *usecase) attachReactions(ctx context.Context, messages []*models.Message, viewerID string) error {
+	ids := make([]string, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+
+	counts, err := u.repo.GetReactionCounts(ctx, ids, viewerID)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to get reaction counts: %v", err)
+		return err
+	}
+	byID := make(map[string][]models.ReactionCount, len(messages))
+	for _, count := range counts {
+		byID[count.MessageID] = append(byID[count.MessageID], *count)
+	}
+
+	for _, message := range messages {
+		message.Reactions = byID[message.ID]
+	}
+	return nil
+}
+
+// isValidEmoji accepts a short run of non-ASCII symbols without letters or spaces.
+// It is deliberately loose since new emoji keep being added to Unicode; keycaps
+// such as "1️⃣" are the only emoji that contain ASCII characters.
+func isValidEmoji(emoji string) bool {
+	if emoji == "" || len(emoji) > maxEmojiLength || !utf8.ValidString(emoji) {
+		return false
+	}
+	if strings.IndexFunc(emoji, func(r rune) bool { return r >= utf8.RuneSelf }) < 0 {
+		return false
+	}
+	return strings.IndexFunc(emoji, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsSpace(r) || unicode.IsControl(r)
+	}) < 0
+}
@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// relayPollInterval is how often the relay looks for new events when the outbox is drained
+	relayPollInterval = 200 * time.Millisecond
+	// relayBatchSize is the number of events published per outbox transaction
+	relayBatchSize = 500
+	// relayRetryDelay slows the relay down after the hub or the database failed
+	relayRetryDelay = time.Second
+	// relayRetention is how long dispatched events are kept before they are purged
+	relayRetention = 24 * time.Hour
+	// relayPurgeInterval is how often dispatched events are purged
+	relayPurgeInterval = time.Hour
+)
+
+var relayDispatched = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "chat_outbox_dispatched_total",
+	Help: "Outbox events published to the hub.",
+})
+
+// Broadcaster publishes frames to the subscribers of a hub topic.
+type Broadcaster interface {
+	// PublishExcept publishes a frame to every subscriber of topic except the clients with ID exclude
+	PublishExcept(topic string, message []byte, exclude string) error
+}
+
+// OutboxRelay publishes the events stored in the outbox to the hub and marks them dispatched.
+// An event is published at least once: after a crash between publishing and marking it
+// is published again, which clients tolerate as they dedupe messages by ID and seq.
+type OutboxRelay struct {
+	outbox chat.Outbox
+	hub    Broadcaster
+
+	stop    context.CancelFunc
+	stopped context.Context
+	wg      sync.WaitGroup
+}
+
+// NewOutboxRelay is the constructor for OutboxRelay. It starts relaying right away.
+func NewOutboxRelay(outbox chat.Outbox, hub Broadcaster) *OutboxRelay {
+	r := &OutboxRelay{outbox: outbox, hub: hub}
+	r.stopped, r.stop = context.WithCancel(context.Background())
+	r.wg.Add(2)
+	go r.relay()
+	go r.purge()
+	return r
+}
+
+// Close stops the relay after the batch at hand, or when ctx is done.
+// Events not dispatched yet are published by another node or after the next start.
+func (r *OutboxRelay) Close(ctx context.Context) error {
+	r.stop()
+	drained := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *OutboxRelay) relay() {
+	defer r.wg.Done()
+	for r.stopped.Err() == nil {
+		// The batch is finished even while shutting down
+		n, err := r.outbox.Dispatch(context.Background(), relayBatchSize, r.publish)
+		relayDispatched.Add(float64(n))
+		delay := time.Duration(0)
+		switch {
+		case err != nil:
+			log.Printf("[Outbox Relay] Failed to dispatch events: %v", err)
+			delay = relayRetryDelay
+		case n < relayBatchSize:
+			delay = relayPollInterval
+		}
+		if delay > 0 {
+			select {
+			case <-r.stopped.Done():
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+func (r *OutboxRelay) publish(event *models.OutboxEvent) error {
+	return r.hub.PublishExcept(event.Topic, event.Payload, event.ExcludeID)
+}
+
+// purge deletes the dispatched events once they are no longer needed to investigate deliveries.
+func (r *OutboxRelay) purge() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(relayPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stopped.Done():
+			return
+		case <-ticker.C:
+		}
+		n, err := r.outbox.PurgeDispatched(r.stopped, time.Now().Add(-relayRetention))
+		if err != nil {
+			if r.stopped.Err() == nil {
+				log.Printf("[Outbox Relay] Failed to purge dispatched events: %v", err)
+			}
+			continue
+		}
+		if n > 0 {
+			log.Printf("[Outbox Relay] Purged %d dispatched events", n)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"video-call/config"
+	"video-call/internal/chat"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// purgeInterval is how often the purger looks for expired messages
+	purgeInterval = time.Minute
+	// purgePause spaces out the batches of a purge so that other writers get the rows in between
+	purgePause = 100 * time.Millisecond
+)
+
+var purgedMessages = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "chat_messages_purged_total",
+	Help: "Messages deleted after the retention of their conversation expired.",
+})
+
+// retentionUpdatedEvent is the payload of a chat.EventConversationRetention event.
+type retentionUpdatedEvent struct {
+	Policy string `json:"policy"`
+	// RetentionSeconds is how long messages are kept from now on; 0 keeps them forever
+	RetentionSeconds int64  `json:"retention_seconds"`
+	UpdatedBy        string `json:"updated_by"`
+}
+
+// UpdateRetention sets the retention policy of a conversation. Group policies are set by an owner
+// or admin, direct ones by either participant. Messages past the new retention disappear right away.
+func (u *usecase) UpdateRetention(ctx context.Context, conversationID, userID, policy string) (*chat.Retention, error) {
+	u.logger.Infof(ctx, "Usecase UpdateRetention: conversationID=%s, userID=%s, policy=%s", conversationID, userID, policy)
+
+	if _, err := uuid.Parse(conversationID); err != nil {
+		return nil, chat.ErrInvalidConversationID
+	}
+	seconds, err := chat.ParseRetentionPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	conversation, err := u.repo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	actor, err := u.repo.GetParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.IsGroup && !actor.Role.CanModerate() {
+		return nil, chat.ErrNotAllowed
+	}
+
+	retention := chat.NewRetention(seconds, u.defaultRetention())
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := u.repo.UpdateConversationRetention(ctx, conversationID, seconds); err != nil {
+			return err
+		}
+		return u.outbox.PublishToConversation(ctx, conversationID, &chat.Event{
+			Type:           chat.EventConversationRetention,
+			ConversationID: conversationID,
+			Data: retentionUpdatedEvent{
+				Policy:           retention.Policy,
+				RetentionSeconds: int64(retention.Period / time.Second),
+				UpdatedBy:        userID,
+			},
+		})
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to update retention of conversation %s: %v", conversationID, err)
+		return nil, err
+	}
+	return retention, nil
+}
+
+// PurgeExpiredMessages deletes a batch of expired messages and the stored objects of their attachments
+// and voicemails.
+func (u *usecase) PurgeExpiredMessages(ctx context.Context, limit int) (int, error) {
+	purged, err := u.repo.PurgeExpiredMessages(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+	for _, attachment := range purged.Attachments {
+		u.deleteObject(ctx, attachment.StorageKey)
+		for _, variant := range attachment.Variants {
+			u.deleteObject(ctx, variantKey(attachment, variant.Name))
+		}
+	}
+	for _, key := range purged.VoicemailKeys {
+		u.deleteObject(ctx, key)
+	}
+	return purged.Count, nil
+}
+
+// defaultRetention is the tenant default retention; 0 keeps messages forever.
+func (u *usecase) defaultRetention() time.Duration {
+	return time.Duration(u.cfg.Chat.DefaultRetentionHours) * time.Hour
+}
+
+type Purger interface {
+	PurgeExpiredMessages(ctx context.Context, limit int) (int, error)
+}
+
+// RetentionPurger deletes expired messages in the background, in small batches so that
+// no transaction holds its locks for long. Until then queries hide the expired messages.
+type RetentionPurger struct {
+	uc        Purger
+	batchSize int
+
+	stop    context.CancelFunc
+	stopped context.Context
+	wg      sync.WaitGroup
+}
+
+// NewRetentionPurger is the constructor for RetentionPurger. It starts purging right away.
+func NewRetentionPurger(cfg *config.Config, uc Purger) *RetentionPurger {
+	p := &RetentionPurger{uc: uc, batchSize: cfg.Chat.PurgeBatchSize}
+	if p.batchSize <= 0 {
+		p.batchSize = chat.DefaultPurgeBatchSize
+	}
+	p.stopped, p.stop = context.WithCancel(context.Background())
+	p.wg.Add(1)
+	go p.run()
+	return p
+}
+
+// Close stops the purger after the batch at hand, or when ctx is done.
+func (p *RetentionPurger) Close(ctx context.Context) error {
+	p.stop()
+	drained := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *RetentionPurger) run() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		p.purge()
+		select {
+		case <-p.stopped.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge deletes batches of expired messages until none are left.
+func (p *RetentionPurger) purge() {
+	for p.stopped.Err() == nil {
+		// The batch is finished even while shutting down
+		n, err := p.uc.PurgeExpiredMessages(context.Background(), p.batchSize)
+		purgedMessages.Add(float64(n))
+		if err != nil {
+			log.Printf("[Retention Purger] Failed to purge expired messages: %v", err)
+			return
+		}
+		if n < p.batchSize {
+			return
+		}
+		select {
+		case <-p.stopped.Done():
+		case <-time.After(purgePause):
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SearchMessages finds messages matching a full-text query in the user's conversations.
+// Hits are ordered by decreasing relevance, newest first among equally relevant ones.
+func (u *usecase) SearchMessages(ctx context.Context, query *chat.SearchQuery) (*chat.SearchResult, error) {
+	u.logger.Infof(ctx, "Usecase SearchMessages: userID=%s, conversationID=%s, senderID=%s", query.UserID, query.ConversationID, query.SenderID)
+
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" || len(query.Text) > chat.MaxSearchQueryLength {
+		return nil, chat.ErrInvalidSearchQuery
+	}
+	if _, err := uuid.Parse(query.UserID); err != nil {
+		return nil, chat.ErrInvalidUserID
+	}
+	if query.ConversationID != "" {
+		if _, err := uuid.Parse(query.ConversationID); err != nil {
+			return nil, chat.ErrInvalidConversationID
+		}
+	}
+	if query.SenderID != "" {
+		if _, err := uuid.Parse(query.SenderID); err != nil {
+			return nil, chat.ErrInvalidUserID
+		}
+	}
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return nil, chat.ErrInvalidDateRange
+	}
+
+	limit := query.Limit
+	if limit <= 0 || limit > chat.MaxSearchPageSize {
+		limit = chat.DefaultSearchPageSize
+	}
+
+	var cursor *chat.SearchCursor
+	if query.Cursor != "" {
+		decoded, err := chat.DecodeSearchCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = decoded
+	}
+
+	// Fetch one extra row to learn whether another page exists.
+	hits, err := u.repo.SearchMessages(ctx, query, cursor, limit+1)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to search messages: %v", err)
+		return nil, err
+	}
+
+	result := &chat.SearchResult{Hits: hits}
+	if len(hits) > limit {
+		result.Hits = hits[:limit]
+		result.NextCursor = chat.NewSearchCursor(result.Hits[limit-1]).Encode()
+	}
+
+	messages := make([]*models.Message, len(result.Hits))
+	for i, hit := range result.Hits {
+		messages[i] = hit.Message
+	}
+	if err := u.decorateMessages(ctx, messages, query.UserID); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// messageStatusEvent is the payload of a chat.EventMessageStatus event.
+type messageStatusEvent struct {
+	MessageID string                   `json:"message_id"`
+	UserID    string                   `json:"user_id"`
+	Status    models.MessageStatusType `json:"status"`
+	UpdatedAt time.Time                `json:"updated_at"`
+}
+
+// MarkMessagesDelivered records that messages reached one of the recipient's connections.
+// Connections report them in batches, so that a replay of missed messages is one write.
+func (u *usecase) MarkMessagesDelivered(ctx context.Context, messageIDs []string, userID string) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return chat.ErrInvalidUserID
+	}
+	ids := make([]string, 0, len(messageIDs))
+	for _, messageID := range messageIDs {
+		if _, err := uuid.Parse(messageID); err == nil {
+			ids = append(ids, messageID)
+		}
+	}
+
+	changed, err := u.repo.UpsertMessageStatuses(ctx, ids, userID, models.MessageStatusDelivered)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to mark %d messages as delivered for user %s: %v", len(ids), userID, err)
+		return err
+	}
+	messages, err := u.repo.GetMessagesByIDs(ctx, changed)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, message := range messages {
+		event := &chat.Event{
+			Type:           chat.EventMessageStatus,
+			ConversationID: message.ConversationID,
+			Data: messageStatusEvent{
+				MessageID: message.ID,
+				UserID:    userID,
+				Status:    models.MessageStatusDelivered,
+				UpdatedAt: now,
+			},
+		}
+		if err := u.publisher.PublishToUser(ctx, message.SenderID, event); err != nil {
+			u.logger.Errorf(ctx, "Failed to publish status of message %s: %v", message.ID, err)
+		}
+	}
+	return nil
+}
+
+// MarkMessageRead records that the recipient acknowledged reading a message.
+func (u *usecase) MarkMessageRead(ctx context.Context, messageID, userID string) error {
+	return u.updateMessageStatus(ctx, messageID, userID, models.MessageStatusRead)
+}
+
+// GetMessageStatuses retrieves the per-recipient statuses of a message in a conversation.
+func (u *usecase) GetMessageStatuses(ctx context.Context, conversationID, messageID string) ([]*models.MessageStatus, error) {
+	u.logger.Infof(ctx, "Usecase GetMessageStatuses: conversationID=%s, messageID=%s", conversationID, messageID)
+
+	if _, err := u.getConversationMessage(ctx, conversationID, messageID); err != nil {
+		return nil, err
+	}
+
+	return u.repo.GetMessageStatuses(ctx, messageID)
+}
+
+func (u *usecase) updateMessageStatus(ctx context.Context, messageID, userID string, status models.MessageStatusType) error {
+	if _, err := uuid.Parse(messageID); err != nil {
+		return chat.ErrInvalidMessageID
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return chat.ErrInvalidUserID
+	}
+
+	changed, err := u.repo.UpsertMessageStatus(ctx, messageID, userID, status)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to mark message %s as %s for user %s: %v", messageID, status, userID, err)
+		return err
+	}
+	if !changed {
+		return nil
+	}
+
+	message, err := u.repo.GetMessageByID(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	if status == models.MessageStatusRead {
+		// Reading a message reads everything before it
+		if err := u.advanceReadPosition(ctx, message.ConversationID, userID, messageID); err != nil {
+			return err
+		}
+	}
+
+	event := &chat.Event{
+		Type:           chat.EventMessageStatus,
+		ConversationID: message.ConversationID,
+		Data: messageStatusEvent{
+			MessageID: messageID,
+			UserID:    userID,
+			Status:    status,
+			UpdatedAt: time.Now(),
+		},
+	}
+	if err := u.publisher.PublishToUser(ctx, message.SenderID, event); err != nil {
+		u.logger.Errorf(ctx, "Failed to publish status of message %s: %v", messageID, err)
+	}
+	return nil
+}
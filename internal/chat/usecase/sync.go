@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"context"
+
+	"video-call/internal/chat"
+
+	"github.com/google/uuid"
+)
+
+// SyncMessages retrieves the messages of a conversation stored after afterSeq, oldest first.
+func (u *usecase) SyncMessages(ctx context.Context, conversationID, viewerID string, afterSeq int64, limit int) (*chat.SyncPage, error) {
+	u.logger.Infof(ctx, "Usecase SyncMessages: conversationID=%s, afterSeq=%d, limit=%d", conversationID, afterSeq, limit)
+
+	if _, err := uuid.Parse(conversationID); err != nil {
+		return nil, chat.ErrInvalidConversationID
+	}
+	if afterSeq < 0 {
+		return nil, chat.ErrInvalidSeq
+	}
+	if limit <= 0 || limit > chat.MaxMessagePageSize {
+		limit = chat.DefaultMessagePageSize
+	}
+
+	// Fetch one extra row to learn whether another page exists.
+	messages, err := u.repo.GetMessagesAfterSeq(ctx, conversationID, afterSeq, limit+1)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to get messages after seq %d: %v", afterSeq, err)
+		return nil, err
+	}
+	page := &chat.SyncPage{HasMore: len(messages) > limit}
+	if page.HasMore {
+		messages = messages[:limit]
+	}
+	page.Messages = messages
+	if len(messages) == 0 {
+		return page, nil
+	}
+	if err := u.decorateMessages(ctx, messages, viewerID); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
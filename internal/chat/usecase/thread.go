@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+)
+
+// ValidateReplyTo checks that a replied message exists in the conversation.
+func (u *usecase) ValidateReplyTo(ctx context.Context, conversationID, replyToID string) error {
+	if _, err := u.getConversationMessage(ctx, conversationID, replyToID); err != nil {
+		if errors.Is(err, chat.ErrMessageNotFound) {
+			return chat.ErrInvalidReplyTo
+		}
+		return err
+	}
+	return nil
+}
+
+// GetThread retrieves a root message with a page of its replies, oldest first.
+func (u *usecase) GetThread(ctx context.Context, conversationID, messageID, userID, cursor string, limit int) (*chat.Thread, error) {
+	u.logger.Infof(ctx, "Usecase GetThread: conversationID=%s, messageID=%s, userID=%s, limit=%d", conversationID, messageID, userID, limit)
+
+	if limit <= 0 || limit > chat.MaxMessagePageSize {
+		limit = chat.DefaultMessagePageSize
+	}
+
+	root, err := u.getConversationMessage(ctx, conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *chat.MessageCursor
+	if cursor != "" {
+		decoded, err := chat.DecodeMessageCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = decoded
+	}
+
+	replies, err := u.repo.GetReplies(ctx, root.ID, after, limit+1)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to get replies of message %s: %v", messageID, err)
+		return nil, err
+	}
+
+	thread := &chat.Thread{Root: root}
+	if len(replies) > limit {
+		replies = replies[:limit]
+		thread.NextCursor = chat.NewMessageCursor(chat.CursorAfter, replies[len(replies)-1]).Encode()
+	}
+	thread.Replies = replies
+
+	if err := u.attachThreads(ctx, []*models.Message{root}); err != nil {
+		return nil, err
+	}
+	all := append([]*models.Message{root}, replies...)
+	if err := u.attachReactions(ctx, all, userID); err != nil {
+		return nil, err
+	}
+	if err := u.attachAttachments(ctx, all, userID); err != nil {
+		return nil, err
+	}
+	return thread, nil
+}
+
+// attachThreads fills in the reply counts of the messages and the messages they quote.
+func (u *usecase) attachThreads(ctx context.Context, messages []*models.Message) error {
+	ids := make([]string, 0, len(messages))
+	var parentIDs []string
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+		if message.ReplyToID != nil {
+			parentIDs = append(parentIDs, *message.ReplyToID)
+		}
+	}
+
+	summaries, err := u.repo.GetThreadSummaries(ctx, ids)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to get thread summaries: %v", err)
+		return err
+	}
+	byID := make(map[string]*models.ThreadSummary, len(summaries))
+	for _, summary := range summaries {
+		byID[summary.MessageID] = summary
+	}
+
+	parents, err := u.repo.GetMessagesByIDs(ctx, parentIDs)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to get replied messages: %v", err)
+		return err
+	}
+	parentsByID := make(map[string]*models.Message, len(parents))
+	for _, parent := range parents {
+		parentsByID[parent.ID] = parent
+	}
+
+	for _, message := range messages {
+		if summary, ok := byID[message.ID]; ok {
+			lastReplyAt := summary.LastReplyAt
+			message.ReplyCount = summary.ReplyCount
+			message.LastReplyAt = &lastReplyAt
+		}
+		if message.ReplyToID != nil {
+			message.ReplyTo = quoteOf(parentsByID[*message.ReplyToID])
+		}
+	}
+	return nil
+}
+
+// quoteOf returns the part of a replied message shown with its replies.
+func quoteOf(parent *models.Message) *models.Message {
+	if parent == nil {
+		return nil
+	}
+	return &models.Message{
+		ID:             parent.ID,
+		ConversationID: parent.ConversationID,
+		SenderID:       parent.SenderID,
+		Content:        parent.Content,
+		MessageType:    parent.MessageType,
+		CreatedAt:      parent.CreatedAt,
+		DeletedAt:      parent.DeletedAt,
+	}
+}
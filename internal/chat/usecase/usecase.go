@@ -2,12 +2,15 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"video-call/config"
+	"video-call/internal/auth"
 	"video-call/internal/chat"
 	"video-call/internal/models"
 	"video-call/pkg/logger"
+	"video-call/pkg/storage"
 	"github.com/google/uuid"
 )
 
@@ -16,23 +19,54 @@ type usecase struct {
 	cfg       *config.Config
 	repo      chat.Repository
 	redisRepo chat.RedisRepository
+	// users resolves the usernames mentioned in messages
+	users auth.UseCase
+	// publisher pushes events that need no consistency with the database, such as typing and presence
+	publisher chat.Publisher
+	// outbox carries the events of changes to conversations and messages, stored with the change
+	outbox    chat.Outbox
+	store     storage.Storage
+	signer    *storage.URLSigner
+	media     *mediaPipeline
 	logger    logger.Logger
 }
 
 // NewUseCase is the constructor for the chat use case.
-func NewUseCase(cfg *config.Config, repo chat.Repository, redisRepo chat.RedisRepository, logger logger.Logger) chat.UseCase {
-	return &usecase{
+func NewUseCase(cfg *config.Config, repo chat.Repository, redisRepo chat.RedisRepository, users auth.UseCase, publisher chat.Publisher, outbox chat.Outbox, store storage.Storage, logger logger.Logger) chat.UseCase {
+	signingKey := cfg.Storage.SigningKey
+	if signingKey == "" {
+		signingKey = cfg.Server.JwtSecretKey
+	}
+	u := &usecase{
 		cfg:       cfg,
 		repo:      repo,
 		redisRepo: redisRepo,
+		users:     users,
+		publisher: publisher,
+		outbox:    outbox,
+		store:     store,
+		signer:    storage.NewURLSigner(signingKey),
 		logger:    logger,
 	}
+	u.media = newMediaPipeline(u)
+	return u
 }
 
-// CreateConversation creates a new conversation.
-func (u *usecase) CreateConversation(ctx context.Context, conversation *models.Conversation) error {
-	u.logger.Infof(ctx, "Usecase CreateConversation: %+v", conversation)
-	
+// CreateConversation creates a group conversation owned by its creator.
+func (u *usecase) CreateConversation(ctx context.Context, conversation *models.Conversation, memberIDs []string) error {
+	u.logger.Infof(ctx, "Usecase CreateConversation: %+v, members %v", conversation, memberIDs)
+
+	if !conversation.IsGroup {
+		return chat.ErrNotGroupConversation
+	}
+	if conversation.CreatedBy == nil {
+		return chat.ErrInvalidUserID
+	}
+	ownerID := *conversation.CreatedBy
+	if _, err := uuid.Parse(ownerID); err != nil {
+		return chat.ErrInvalidUserID
+	}
+
 	// Set default values if not provided
 	if conversation.ID == "" {
 		conversation.ID = uuid.New().String()
@@ -40,14 +74,31 @@ func (u *usecase) CreateConversation(ctx context.Context, conversation *models.C
 	if conversation.CreatedAt.IsZero() {
 		conversation.CreatedAt = time.Now()
 	}
-	
+
+	participants := []*models.ConversationParticipant{{UserID: ownerID, Role: models.ParticipantRoleOwner}}
+	seen := map[string]bool{ownerID: true}
+	for _, memberID := range memberIDs {
+		if _, err := uuid.Parse(memberID); err != nil {
+			return chat.ErrInvalidUserID
+		}
+		if seen[memberID] {
+			continue
+		}
+		seen[memberID] = true
+		participants = append(participants, &models.ConversationParticipant{UserID: memberID, Role: models.ParticipantRoleMember})
+	}
+
 	// Create the conversation
-	err := u.repo.CreateConversation(ctx, conversation)
+	err := u.repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := u.repo.CreateConversationWithParticipants(ctx, conversation, participants); err != nil {
+			return err
+		}
+		return u.publishMembersAdded(ctx, conversation.ID, ownerID, participants)
+	})
 	if err != nil {
 		u.logger.Errorf(ctx, "Failed to create conversation: %v", err)
 		return err
 	}
-
 	return nil
 }
 
@@ -76,22 +127,110 @@ func (u *usecase) GetConversationsByUserID(ctx context.Context, userID string) (
 	return u.repo.GetConversationsByUserID(ctx, userID)
 }
 
-// UpdateConversation updates an existing conversation.
-func (u *usecase) UpdateConversation(ctx context.Context, conversation *models.Conversation) error {
-	u.logger.Infof(ctx, "Usecase UpdateConversation: %+v", conversation)
-	return u.repo.UpdateConversation(ctx, conversation)
+// UpdateConversation saves a renamed group on behalf of an owner or admin.
+func (u *usecase) UpdateConversation(ctx context.Context, conversation *models.Conversation, userID string) error {
+	u.logger.Infof(ctx, "Usecase UpdateConversation: %+v, userID %s", conversation, userID)
+
+	if !conversation.IsGroup {
+		return chat.ErrNotGroupConversation
+	}
+	actor, err := u.repo.GetParticipant(ctx, conversation.ID, userID)
+	if err != nil {
+		return err
+	}
+	if !actor.Role.CanModerate() {
+		return chat.ErrNotAllowed
+	}
+
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := u.repo.UpdateConversation(ctx, conversation); err != nil {
+			return err
+		}
+		return u.outbox.PublishToConversation(ctx, conversation.ID, &chat.Event{
+			Type:           chat.EventConversationUpdated,
+			ConversationID: conversation.ID,
+			Data:           conversation,
+		})
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to update conversation %s: %v", conversation.ID, err)
+		return err
+	}
+	return nil
 }
 
 // DeleteConversation deletes a conversation by its ID.
-func (u *usecase) DeleteConversation(ctx context.Context, conversationID string) error {
-	u.logger.Infof(ctx, "Usecase DeleteConversation: %s", conversationID)
-	
+// Groups may only be deleted by their owner, direct conversations by either participant.
+func (u *usecase) DeleteConversation(ctx context.Context, conversationID, userID string) error {
+	u.logger.Infof(ctx, "Usecase DeleteConversation: %s, userID %s", conversationID, userID)
+
 	// Validate conversation ID
 	if _, err := uuid.Parse(conversationID); err != nil {
 		return chat.ErrInvalidConversationID
 	}
-	
-	return u.repo.DeleteConversation(ctx, conversationID)
+
+	conversation, err := u.repo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	actor, err := u.repo.GetParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if conversation.IsGroup && actor.Role != models.ParticipantRoleOwner {
+		return chat.ErrNotAllowed
+	}
+
+	return u.deleteConversation(ctx, conversationID, userID)
+}
+
+// GetOrCreateDirectConversation returns the one-to-one conversation between two users,
+// creating it with both users as participants if it does not exist yet.
+func (u *usecase) GetOrCreateDirectConversation(ctx context.Context, userA, userB string) (*models.Conversation, error) {
+	u.logger.Infof(ctx, "Usecase GetOrCreateDirectConversation: userA %s, userB %s", userA, userB)
+
+	// The direct key compares IDs, so they are canonicalized first
+	idA, err := uuid.Parse(userA)
+	if err != nil {
+		return nil, chat.ErrInvalidUserID
+	}
+	idB, err := uuid.Parse(userB)
+	if err != nil {
+		return nil, chat.ErrInvalidUserID
+	}
+	if idA == idB {
+		return nil, chat.ErrInvalidMembers
+	}
+	userA, userB = idA.String(), idB.String()
+
+	// Most calls find an existing conversation, which needs no write
+	conversation, err := u.repo.FindDirectConversation(ctx, userA, userB)
+	if err == nil {
+		return conversation, nil
+	}
+	if !errors.Is(err, chat.ErrConversationNotFound) {
+		u.logger.Errorf(ctx, "Failed to find direct conversation: %v", err)
+		return nil, err
+	}
+
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		var created bool
+		var err error
+		conversation, created, err = u.repo.CreateDirectConversation(ctx, &models.Conversation{CreatedBy: &userA}, userA, userB)
+		if err != nil || !created {
+			return err
+		}
+		return u.publishMembersAdded(ctx, conversation.ID, userA, []*models.ConversationParticipant{
+			{ConversationID: conversation.ID, UserID: userA, Role: models.ParticipantRoleMember, JoinedAt: conversation.CreatedAt},
+			{ConversationID: conversation.ID, UserID: userB, Role: models.ParticipantRoleMember, JoinedAt: conversation.CreatedAt},
+		})
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to create direct conversation: %v", err)
+		return nil, err
+	}
+
+	return conversation, nil
 }
 
 // IsUserInConversation checks if a user is a participant in a conversation.
@@ -110,35 +249,353 @@ func (u *usecase) IsUserInConversation(ctx context.Context, userID, conversation
 }
 
 // CreateMessage creates a new message.
-func (u *usecase) CreateMessage(ctx context.Context, message models.Message) error {
+func (u *usecase) CreateMessage(ctx context.Context, message models.Message) (*models.Message, error) {
 	u.logger.Infof(ctx, "Usecase CreateMessage: %+v", message)
-	return u.repo.CreateMessage(ctx, message)
+
+	if message.ClientMessageID != nil {
+		// A retry of a send that already went through gets the original message back
+		original, err := u.repo.GetMessageByClientID(ctx, message.SenderID, *message.ClientMessageID)
+		if err == nil {
+			return original, nil
+		}
+		if !errors.Is(err, chat.ErrMessageNotFound) {
+			return nil, err
+		}
+	}
+	if message.ReplyToID != nil {
+		if err := u.ValidateReplyTo(ctx, message.ConversationID, *message.ReplyToID); err != nil {
+			return nil, err
+		}
+	}
+	if len(message.Attachments) > 0 {
+		ids := make([]string, len(message.Attachments))
+		for i, attachment := range message.Attachments {
+			ids[i] = attachment.ID
+		}
+		attachments, err := u.ValidateAttachments(ctx, message.ConversationID, message.SenderID, ids)
+		if err != nil {
+			return nil, err
+		}
+		message.Attachments = attachments
+		if message.MessageType == "" || message.MessageType == models.MessageTypeText {
+			message.MessageType = attachments[0].MessageType()
+		}
+	}
+	if message.ID == "" {
+		message.ID = uuid.New().String()
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	mentions, err := u.resolveMentions(ctx, &message)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to resolve mentions of message %s: %v", message.ID, err)
+		return nil, err
+	}
+
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := u.repo.CreateMessage(ctx, &message); err != nil {
+			return err
+		}
+		if err := u.outbox.PublishMessage(ctx, &message); err != nil {
+			return err
+		}
+		if err := u.storeMentions(ctx, &message, mentions); err != nil {
+			return err
+		}
+		// Callers may store the message as part of a transaction of their own
+		u.repo.AfterCommit(ctx, func(ctx context.Context) {
+			u.messageCreated(ctx, &message, mentions)
+		})
+		return nil
+	})
+	if errors.Is(err, chat.ErrDuplicateMessage) {
+		// The same send raced past the lookup above and was stored by the other attempt
+		return u.repo.GetMessageByClientID(ctx, message.SenderID, *message.ClientMessageID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
 }
 
-// GetMessages retrieves messages for a conversation with pagination.
-// Returns messages in descending order by creation time (newest first).
-func (u *usecase) GetMessages(ctx context.Context, conversationID string, limit, offset int) ([]*models.Message, error) {
-	u.logger.Infof(ctx, "Usecase GetMessages: conversationID=%s, limit=%d, offset=%d", conversationID, limit, offset)
-	
+// CreateMessages stores a batch of messages queued by the message writer and returns them as stored.
+// Messages already stored under their ID, e.g. delivered again after a crash, are returned as they are.
+// If the batch cannot be stored as a whole, each message goes through CreateMessage on its own.
+func (u *usecase) CreateMessages(ctx context.Context, messages []models.Message) ([]*models.Message, []error) {
+	u.logger.Infof(ctx, "Usecase CreateMessages: %d messages", len(messages))
+
+	stored := make([]*models.Message, len(messages))
+	errs := make([]error, len(messages))
+
+	ids := make([]string, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+	}
+	existing, err := u.repo.GetMessagesByIDs(ctx, ids)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return stored, errs
+	}
+	byID := make(map[string]*models.Message, len(existing))
+	for _, message := range existing {
+		byID[message.ID] = message
+	}
+
+	pending := make([]*models.Message, 0, len(messages))
+	positions := make([]int, 0, len(messages))
+	mentions := make([][]*models.MessageMention, 0, len(messages))
+	for i := range messages {
+		message := messages[i]
+		if found, ok := byID[message.ID]; ok && found.SenderID == message.SenderID {
+			stored[i] = found
+			continue
+		}
+		if len(message.Attachments) > 0 {
+			ids := make([]string, len(message.Attachments))
+			for j, attachment := range message.Attachments {
+				ids[j] = attachment.ID
+			}
+			// Attachments cross the queue by ID only
+			attachments, err := u.ValidateAttachments(ctx, message.ConversationID, message.SenderID, ids)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			message.Attachments = attachments
+			if message.MessageType == "" || message.MessageType == models.MessageTypeText {
+				message.MessageType = attachments[0].MessageType()
+			}
+		}
+		mentioned, err := u.resolveMentions(ctx, &message)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		pending = append(pending, &message)
+		positions = append(positions, i)
+		mentions = append(mentions, mentioned)
+	}
+	if len(pending) == 0 {
+		return stored, errs
+	}
+
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := u.repo.CreateMessages(ctx, pending); err != nil {
+			return err
+		}
+		for j, message := range pending {
+			if err := u.outbox.PublishMessage(ctx, message); err != nil {
+				return err
+			}
+			if err := u.storeMentions(ctx, message, mentions[j]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// One bad message, such as a duplicate client message ID, fails the whole batch
+		u.logger.Errorf(ctx, "Failed to store batch of %d messages, storing them one by one: %v", len(pending), err)
+		for _, i := range positions {
+			stored[i], errs[i] = u.CreateMessage(ctx, messages[i])
+		}
+		return stored, errs
+	}
+	for j, i := range positions {
+		u.messageCreated(ctx, pending[j], mentions[j])
+		stored[i] = pending[j]
+	}
+	return stored, errs
+}
+
+// messageCreated notifies the recipients of a stored message and processes its media.
+// The message itself and its mentions are published through the outbox.
+func (u *usecase) messageCreated(ctx context.Context, message *models.Message, mentions []*models.MessageMention) {
+	u.notifyRecipients(ctx, message, mentions)
+	u.media.enqueue(message)
+}
+
+// GetMessages retrieves a page of messages for a conversation using keyset pagination.
+// Messages are returned newest first together with cursors for the adjacent pages.
+func (u *usecase) GetMessages(ctx context.Context, conversationID string, query *chat.MessagePageQuery) (*chat.MessagePage, error) {
+	u.logger.Infof(ctx, "Usecase GetMessages: conversationID=%s, query=%+v", conversationID, query)
+
 	// Validate conversation ID
 	if _, err := uuid.Parse(conversationID); err != nil {
 		return nil, chat.ErrInvalidConversationID
 	}
-	
+
 	// Validate pagination parameters
-	if limit <= 0 || limit > 100 {
-		limit = 50 // Default limit
+	limit := query.Limit
+	if limit <= 0 || limit > chat.MaxMessagePageSize {
+		limit = chat.DefaultMessagePageSize
 	}
-	if offset < 0 {
-		offset = 0
+
+	anchors := 0
+	for _, anchor := range []string{query.Cursor, query.BeforeID, query.AfterID, query.AroundID} {
+		if anchor != "" {
+			anchors++
+		}
 	}
-	
-	// Get messages from repository
-	messages, err := u.repo.GetMessages(ctx, conversationID, limit, offset)
+	if anchors > 1 {
+		return nil, chat.ErrInvalidCursor
+	}
+
+	if query.AroundID != "" {
+		anchor, err := u.getConversationMessage(ctx, conversationID, query.AroundID)
+		if err != nil {
+			return nil, err
+		}
+		return u.getMessagesAround(ctx, conversationID, anchor, limit, query.ViewerID)
+	}
+
+	var cursor *chat.MessageCursor
+	switch {
+	case query.Cursor != "":
+		decoded, err := chat.DecodeMessageCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = decoded
+	case query.BeforeID != "", query.AfterID != "":
+		direction, anchorID := chat.CursorBefore, query.BeforeID
+		if query.AfterID != "" {
+			direction, anchorID = chat.CursorAfter, query.AfterID
+		}
+		anchor, err := u.getConversationMessage(ctx, conversationID, anchorID)
+		if err != nil {
+			return nil, err
+		}
+		cursor = chat.NewMessageCursor(direction, anchor)
+	}
+
+	// Fetch one extra row to learn whether another page exists.
+	messages, err := u.repo.GetMessages(ctx, conversationID, cursor, limit+1)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to get messages: %v", err)
+		return nil, err
+	}
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	page := &chat.MessagePage{Messages: messages}
+	if len(messages) == 0 {
+		return page, nil
+	}
+	if err := u.decorateMessages(ctx, messages, query.ViewerID); err != nil {
+		return nil, err
+	}
+
+	hasOlder, hasNewer := hasMore, cursor != nil
+	if cursor != nil && cursor.Direction == chat.CursorAfter {
+		reverseMessages(messages)
+		hasOlder, hasNewer = true, hasMore
+	}
+	if hasOlder {
+		page.NextCursor = chat.NewMessageCursor(chat.CursorBefore, messages[len(messages)-1]).Encode()
+	}
+	if hasNewer {
+		page.PrevCursor = chat.NewMessageCursor(chat.CursorAfter, messages[0]).Encode()
+	}
+
+	return page, nil
+}
+
+// getMessagesAround returns the anchor message with the messages surrounding it.
+func (u *usecase) getMessagesAround(ctx context.Context, conversationID string, anchor *models.Message, limit int, viewerID string) (*chat.MessagePage, error) {
+	olderLimit := (limit - 1) / 2
+	newerLimit := limit - 1 - olderLimit
+
+	older, err := u.repo.GetMessages(ctx, conversationID, chat.NewMessageCursor(chat.CursorBefore, anchor), olderLimit+1)
 	if err != nil {
 		u.logger.Errorf(ctx, "Failed to get messages: %v", err)
 		return nil, err
 	}
+	newer, err := u.repo.GetMessages(ctx, conversationID, chat.NewMessageCursor(chat.CursorAfter, anchor), newerLimit+1)
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to get messages: %v", err)
+		return nil, err
+	}
+
+	hasOlder, hasNewer := len(older) > olderLimit, len(newer) > newerLimit
+	if hasOlder {
+		older = older[:olderLimit]
+	}
+	if hasNewer {
+		newer = newer[:newerLimit]
+	}
+	reverseMessages(newer)
+
+	messages := make([]*models.Message, 0, len(newer)+1+len(older))
+	messages = append(messages, newer...)
+	messages = append(messages, anchor)
+	messages = append(messages, older...)
+
+	if err := u.decorateMessages(ctx, messages, viewerID); err != nil {
+		return nil, err
+	}
+
+	page := &chat.MessagePage{Messages: messages}
+	if hasOlder {
+		page.NextCursor = chat.NewMessageCursor(chat.CursorBefore, messages[len(messages)-1]).Encode()
+	}
+	if hasNewer {
+		page.PrevCursor = chat.NewMessageCursor(chat.CursorAfter, messages[0]).Encode()
+	}
+	return page, nil
+}
+
+// getConversationMessage retrieves a message and checks that it belongs to the conversation.
+func (u *usecase) getConversationMessage(ctx context.Context, conversationID, messageID string) (*models.Message, error) {
+	if _, err := uuid.Parse(messageID); err != nil {
+		return nil, chat.ErrInvalidMessageID
+	}
+	message, err := u.repo.GetMessageByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.ConversationID != conversationID {
+		return nil, chat.ErrMessageNotFound
+	}
+	return message, nil
+}
 
-	return messages, nil
+// GetMessage retrieves a message of a conversation the user participates in.
+func (u *usecase) GetMessage(ctx context.Context, messageID, userID string) (*models.Message, error) {
+	if _, err := uuid.Parse(messageID); err != nil {
+		return nil, chat.ErrInvalidMessageID
+	}
+	message, err := u.repo.GetMessageByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.checkMember(ctx, message.ConversationID, userID); err != nil {
+		return nil, err
+	}
+	if message.IsDeleted() {
+		return nil, chat.ErrMessageDeleted
+	}
+	return message, nil
+}
+
+// decorateMessages fills in everything a message listing shows besides the stored columns.
+func (u *usecase) decorateMessages(ctx context.Context, messages []*models.Message, viewerID string) error {
+	if err := u.attachThreads(ctx, messages); err != nil {
+		return err
+	}
+	if err := u.attachReactions(ctx, messages, viewerID); err != nil {
+		return err
+	}
+	return u.attachAttachments(ctx, messages, viewerID)
+}
+
+func reverseMessages(messages []*models.Message) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
 }
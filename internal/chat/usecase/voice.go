@@ -0,0 +1,182 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+	"video-call/pkg/media"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultVoiceMaxSizeMB   = 10
+	defaultVoiceMaxDuration = 5 * time.Minute
+	voiceContentType        = "audio/ogg"
+	// waveformBuckets is the number of bars clients draw for a voice message.
+	waveformBuckets = 64
+	// waveformSampleRate is plenty for an amplitude envelope and keeps decoding cheap.
+	waveformSampleRate = 8000
+)
+
+// voiceMetadata is stored in the Metadata of a voice message.
+type voiceMetadata struct {
+	AttachmentID string `json:"attachment_id"`
+	DurationMs   int64  `json:"duration_ms"`
+	Waveform     []int  `json:"waveform"`
+}
+
+func (u *usecase) voiceMaxSize() int64 {
+	mb := u.cfg.Media.VoiceMaxSizeMB
+	if mb <= 0 {
+		mb = defaultVoiceMaxSizeMB
+	}
+	return int64(mb) << 20
+}
+
+func (u *usecase) voiceMaxDuration() time.Duration {
+	if u.cfg.Media.VoiceMaxDuration <= 0 {
+		return defaultVoiceMaxDuration
+	}
+	return time.Duration(u.cfg.Media.VoiceMaxDuration) * time.Second
+}
+
+// SendVoiceMessage stores an Ogg Opus recording and posts it as a voice message.
+func (u *usecase) SendVoiceMessage(ctx context.Context, conversationID, userID string, upload *chat.AttachmentUpload, replyToID *string) (*models.Message, error) {
+	u.logger.Infof(ctx, "Usecase SendVoiceMessage: conversationID=%s, userID=%s, size=%d", conversationID, userID, upload.Size)
+
+	maxSize := u.voiceMaxSize()
+	if upload.Size > maxSize {
+		return nil, chat.ErrAttachmentTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(upload.Reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, chat.ErrAttachmentTooLarge
+	}
+
+	// The container is parsed rather than trusting a client reported duration.
+	info, err := media.ParseOggOpus(bytes.NewReader(data))
+	if err != nil {
+		return nil, chat.ErrInvalidVoiceMessage
+	}
+	if info.Duration <= 0 {
+		return nil, chat.ErrInvalidVoiceMessage
+	}
+	if info.Duration > u.voiceMaxDuration() {
+		return nil, chat.ErrVoiceMessageTooLong
+	}
+	// Checked again by CreateMessage, but a bad reply is caught before anything is stored
+	if replyToID != nil {
+		if err := u.ValidateReplyTo(ctx, conversationID, *replyToID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	attachment := &models.Attachment{
+		ID:             uuid.New().String(),
+		ConversationID: conversationID,
+		UploaderID:     userID,
+		FileName:       voiceFileName(upload.FileName),
+		ContentType:    voiceContentType,
+		SizeBytes:      int64(len(data)),
+		DurationMs:     info.Duration.Milliseconds(),
+		CreatedAt:      now,
+		ProcessedAt:    &now,
+	}
+	attachment.StorageKey = fmt.Sprintf("attachments/%s/%s", conversationID, attachment.ID)
+
+	metadata, err := json.Marshal(voiceMetadata{
+		AttachmentID: attachment.ID,
+		DurationMs:   attachment.DurationMs,
+		Waveform:     u.voiceWaveform(ctx, data, info),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.store.Put(ctx, attachment.StorageKey, bytes.NewReader(data), attachment.SizeBytes, voiceContentType); err != nil {
+		u.logger.Errorf(ctx, "Failed to store voice message %s: %v", attachment.ID, err)
+		return nil, err
+	}
+
+	message := models.Message{
+		ID:             uuid.New().String(),
+		ConversationID: conversationID,
+		SenderID:       userID,
+		MessageType:    models.MessageTypeVoice,
+		Metadata:       metadata,
+		ReplyToID:      replyToID,
+		CreatedAt:      now,
+		Attachments:    []*models.Attachment{attachment},
+	}
+	// The attachment is only kept along with the message sharing it
+	var stored *models.Message
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := u.repo.CreateAttachment(ctx, attachment); err != nil {
+			return err
+		}
+		stored, err = u.CreateMessage(ctx, message)
+		return err
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to post voice message: %v", err)
+		u.deleteObject(ctx, attachment.StorageKey)
+		return nil, err
+	}
+
+	attachment.MessageID = &stored.ID
+	u.signAttachment(attachment, userID)
+	stored.Attachments = []*models.Attachment{attachment}
+	return stored, nil
+}
+
+// voiceWaveform decodes the recording with ffmpeg when available. Otherwise, or if decoding
+// fails, the waveform is estimated from the Opus packet sizes.
+func (u *usecase) voiceWaveform(ctx context.Context, data []byte, info *media.OpusInfo) []int {
+	if u.media.ffmpeg.Available() {
+		samples, err := u.decodeVoice(ctx, data)
+		if err == nil && len(samples) > 0 {
+			return media.Waveform(media.PCMMagnitudes(samples), waveformBuckets)
+		}
+		u.logger.Errorf(ctx, "Failed to decode voice message, estimating its waveform: %v", err)
+	}
+	return media.Waveform(media.PacketMagnitudes(info.PacketSizes), waveformBuckets)
+}
+
+func (u *usecase) decodeVoice(ctx context.Context, data []byte) ([]int16, error) {
+	f, err := os.CreateTemp("", "voice-*.ogg")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u.media.ffmpeg.DecodePCM(ctx, f.Name(), waveformSampleRate)
+}
+
+func voiceFileName(name string) string {
+	name = sanitizeFileName(name)
+	if name == "file" || filepath.Ext(name) == "" {
+		return "voice.ogg"
+	}
+	return name
+}
+
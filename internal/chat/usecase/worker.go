@@ -2,45 +2,304 @@ package usecase
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net/http"
+	"sync"
+	"time"
 
+	"video-call/config"
+	"video-call/internal/chat"
 	"video-call/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	defaultWriterWorkers     = 4
+	defaultWriterBatchSize   = 100
+	defaultWriterMaxAttempts = 5
+
+	// writerReadBlock is how long a worker waits for new entries before checking for shutdown
+	writerReadBlock = 2 * time.Second
+	// writerClaimIdle is how long an entry stays with a writer before another one takes it over
+	writerClaimIdle = time.Minute
+	// writerRetryDelay slows a worker down after a batch that failed for a transient reason
+	writerRetryDelay = time.Second
+	// writerStatsInterval is how often the queue metrics are refreshed and acked entries trimmed
+	writerStatsInterval = 15 * time.Second
+)
+
+var (
+	// The queue is never trimmed past unacked entries, so a growing lag is what to alert on
+	writerQueueEntries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "chat_message_queue_entries",
+		Help: "Entries of the message queue not read yet (lag) or read but not acked (pending).",
+	}, []string{"state"})
+	writerResults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "chat_message_writes_total",
+		Help: "Queued messages by outcome: stored, rejected, retried or dead_lettered.",
+	}, []string{"result"})
+	writerBatchSizes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "chat_message_write_batch_size",
+		Help:    "Number of queued messages stored per batch.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 8),
+	})
 )
 
 type MessageWriterIface interface {
-	Enqueue(msg models.Message)
+	// Enqueue queues a message for writing; done, if not nil, is called with the stored message or the error.
+	// An error is returned if the message could not be queued, in which case done is not called.
+	Enqueue(msg models.Message, done func(stored *models.Message, err error)) error
 }
 
 type UseCase interface {
-	CreateMessage(ctx context.Context, message models.Message) error
+	CreateMessages(ctx context.Context, messages []models.Message) ([]*models.Message, []error)
 }
 
+// MessageWriter stores messages through a durable queue shared by the writers of all nodes.
+// Workers store the queued messages in batches and report each result to the writer that queued it.
 type MessageWriter struct {
-	queue   chan models.Message
-	nWorker int
-	uc      UseCase
+	queue       chat.MessageQueue
+	uc          UseCase
+	origin      string
+	nWorker     int
+	batchSize   int
+	maxAttempts int
+
+	mu      sync.Mutex
+	waiting map[string]func(stored *models.Message, err error)
+
+	// stop ends reading from the queue; workers finish the batch at hand
+	stop    context.CancelFunc
+	stopped context.Context
+	wg      sync.WaitGroup
 }
 
-func NewMessageWriter(uc UseCase, workerNum int, queueSize int) *MessageWriter {
+func NewMessageWriter(cfg *config.Config, uc UseCase, queue chat.MessageQueue) (*MessageWriter, error) {
 	mw := &MessageWriter{
-		queue:   make(chan models.Message, queueSize),
-		nWorker: workerNum,
-		uc:      uc,
+		queue:       queue,
+		uc:          uc,
+		origin:      uuid.New().String(),
+		nWorker:     cfg.Chat.WriterWorkers,
+		batchSize:   cfg.Chat.WriterBatchSize,
+		maxAttempts: cfg.Chat.WriterMaxAttempts,
+		waiting:     make(map[string]func(*models.Message, error)),
 	}
+	if mw.nWorker <= 0 {
+		mw.nWorker = defaultWriterWorkers
+	}
+	if mw.batchSize <= 0 {
+		mw.batchSize = defaultWriterBatchSize
+	}
+	if mw.maxAttempts <= 0 {
+		mw.maxAttempts = defaultWriterMaxAttempts
+	}
+	mw.stopped, mw.stop = context.WithCancel(context.Background())
+
+	results, err := queue.SubscribeResults(mw.stopped, mw.origin)
+	if err != nil {
+		return nil, err
+	}
+	go mw.receiveResults(results)
+
 	for i := 0; i < mw.nWorker; i++ {
-		go mw.worker()
+		mw.wg.Add(1)
+		go mw.worker(fmt.Sprintf("%s-%d", mw.origin, i))
 	}
-	return mw
+	mw.wg.Add(1)
+	go mw.claimer(mw.origin + "-claim")
+	go mw.reportStats()
+	return mw, nil
 }
 
-func (mw *MessageWriter) worker() {
-	for msg := range mw.queue {
-		if err := mw.uc.CreateMessage(context.Background(), msg); err != nil {
-			log.Printf("[DB Worker] Failed to save message: %v", err)
+func (mw *MessageWriter) Enqueue(msg models.Message, done func(stored *models.Message, err error)) error {
+	queued := &chat.QueuedMessage{Message: msg, Origin: mw.origin}
+	if done != nil {
+		queued.RequestID = uuid.New().String()
+		mw.mu.Lock()
+		mw.waiting[queued.RequestID] = done
+		mw.mu.Unlock()
+	}
+	if err := mw.queue.Add(context.Background(), queued); err != nil {
+		mw.take(queued.RequestID)
+		return err
+	}
+	return nil
+}
+
+// Close stops reading from the queue and waits until the workers stored the batches at hand,
+// or until ctx is done. Entries not read yet stay queued for the other writers or the next start.
+func (mw *MessageWriter) Close(ctx context.Context) error {
+	mw.stop()
+	drained := make(chan struct{})
+	go func() {
+		mw.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (mw *MessageWriter) worker(consumer string) {
+	defer mw.wg.Done()
+	for mw.stopped.Err() == nil {
+		entries, err := mw.queue.Read(mw.stopped, consumer, mw.batchSize, writerReadBlock)
+		if err != nil {
+			if mw.stopped.Err() == nil {
+				log.Printf("[DB Worker] Failed to read message queue: %v", err)
+				time.Sleep(writerRetryDelay)
+			}
+			continue
+		}
+		mw.store(entries)
+	}
+}
+
+// claimer takes over the entries of writers that went away without storing them.
+func (mw *MessageWriter) claimer(consumer string) {
+	defer mw.wg.Done()
+	ticker := time.NewTicker(writerClaimIdle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-mw.stopped.Done():
+			return
+		case <-ticker.C:
+		}
+		entries, err := mw.queue.Claim(mw.stopped, consumer, writerClaimIdle, mw.batchSize)
+		if err != nil {
+			if mw.stopped.Err() == nil {
+				log.Printf("[DB Worker] Failed to claim pending messages: %v", err)
+			}
+			continue
+		}
+		mw.store(entries)
+	}
+}
+
+// store stores a batch of entries and settles each one: acked when stored or rejected,
+// left pending for the claimer after a transient failure, or dead-lettered after the last attempt.
+// Entries left pending keep their place in the queue, ahead of the messages sent after them.
+func (mw *MessageWriter) store(entries []*chat.QueuedMessage) {
+	if len(entries) == 0 {
+		return
+	}
+	// The batch is finished even while shutting down
+	ctx := context.Background()
+	writerBatchSizes.Observe(float64(len(entries)))
+
+	messages := make([]models.Message, len(entries))
+	for i, entry := range entries {
+		messages[i] = entry.Message
+	}
+	stored, errs := mw.uc.CreateMessages(ctx, messages)
+
+	acked := make([]string, 0, len(entries))
+	retried := false
+	for i, entry := range entries {
+		err := errs[i]
+		switch {
+		case err == nil:
+			writerResults.WithLabelValues("stored").Inc()
+			acked = append(acked, entry.EntryID)
+			mw.report(ctx, entry, stored[i], nil)
+		case isPermanentWriteError(err):
+			writerResults.WithLabelValues("rejected").Inc()
+			acked = append(acked, entry.EntryID)
+			mw.report(ctx, entry, nil, err)
+		case entry.Attempts+1 >= mw.maxAttempts:
+			log.Printf("[DB Worker] Giving up on message %s after %d attempts: %v", entry.Message.ID, entry.Attempts+1, err)
+			writerResults.WithLabelValues("dead_lettered").Inc()
+			if err := mw.queue.DeadLetter(ctx, entry, err.Error()); err != nil {
+				log.Printf("[DB Worker] Failed to dead-letter message %s: %v", entry.Message.ID, err)
+			}
+			mw.report(ctx, entry, nil, err)
+		default:
+			log.Printf("[DB Worker] Failed to save message %s, retrying: %v", entry.Message.ID, err)
+			writerResults.WithLabelValues("retried").Inc()
+			retried = true
+		}
+	}
+	if err := mw.queue.Ack(ctx, acked...); err != nil {
+		log.Printf("[DB Worker] Failed to ack %d messages: %v", len(acked), err)
+	}
+	if retried {
+		time.Sleep(writerRetryDelay)
+	}
+}
+
+// report tells the writer that queued an entry about its result.
+func (mw *MessageWriter) report(ctx context.Context, entry *chat.QueuedMessage, stored *models.Message, err error) {
+	if entry.RequestID == "" {
+		return
+	}
+	if entry.Origin == mw.origin {
+		if done := mw.take(entry.RequestID); done != nil {
+			done(stored, err)
+		}
+		return
+	}
+
+	result := &chat.WriteResult{RequestID: entry.RequestID, Message: stored}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if err := mw.queue.PublishResult(ctx, entry.Origin, result); err != nil {
+		log.Printf("[DB Worker] Failed to report result of message %s: %v", entry.Message.ID, err)
+	}
+}
+
+// receiveResults hands the results stored by other writers to the callers waiting here.
+func (mw *MessageWriter) receiveResults(results <-chan *chat.WriteResult) {
+	for result := range results {
+		if done := mw.take(result.RequestID); done != nil {
+			done(result.Message, result.Err())
+		}
+	}
+}
+
+// take removes and returns the callback waiting for a request.
+func (mw *MessageWriter) take(requestID string) func(stored *models.Message, err error) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	done := mw.waiting[requestID]
+	delete(mw.waiting, requestID)
+	return done
+}
+
+func (mw *MessageWriter) reportStats() {
+	ticker := time.NewTicker(writerStatsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-mw.stopped.Done():
+			return
+		case <-ticker.C:
+		}
+		lag, pending, err := mw.queue.Stats(mw.stopped)
+		if err != nil {
+			log.Printf("[DB Worker] Failed to get message queue stats: %v", err)
+			continue
+		}
+		writerQueueEntries.WithLabelValues("lag").Set(float64(lag))
+		writerQueueEntries.WithLabelValues("pending").Set(float64(pending))
+
+		if _, err := mw.queue.Trim(mw.stopped); err != nil {
+			log.Printf("[DB Worker] Failed to trim message queue: %v", err)
 		}
 	}
 }
 
-func (mw *MessageWriter) Enqueue(msg models.Message) {
-	mw.queue <- msg
+// isPermanentWriteError reports whether storing a message failed because of the message itself,
+// so trying again cannot help.
+func isPermanentWriteError(err error) bool {
+	status, _ := chat.MapError(err)
+	return status < http.StatusInternalServerError
 }
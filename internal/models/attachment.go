@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Attachment represents the attachments table
+// An attachment is uploaded to a conversation first and linked to the message that shares it.
+type Attachment struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:char(36)"`
+	ConversationID string    `json:"conversation_id" gorm:"type:char(36)"`
+	UploaderID     string    `json:"uploader_id" gorm:"type:char(36)"`
+	MessageID      *string   `json:"message_id,omitempty" gorm:"type:char(36)"`
+	StorageKey     string    `json:"-"`
+	FileName       string    `json:"file_name"`
+	ContentType    string    `json:"content_type"`
+	SizeBytes      int64     `json:"size_bytes"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	// Filled in by the media pipeline for images and videos
+	Width       int                               `json:"width,omitempty"`
+	Height      int                               `json:"height,omitempty"`
+	DurationMs  int64                             `json:"duration_ms,omitempty"`
+	BlurHash    string                            `json:"blurhash,omitempty" gorm:"column:blurhash"`
+	Variants    datatypes.JSONSlice[MediaVariant] `json:"variants,omitempty"`
+	ProcessedAt *time.Time                        `json:"processed_at,omitempty"`
+	// MediaFailedAt is set once the pipeline gave up; the original stays withheld from other users
+	MediaFailedAt *time.Time `json:"media_failed_at,omitempty"`
+
+	// Bookkeeping of the media pipeline, which retries failed attachments a few times
+	MediaAttempts    int        `json:"-"`
+	MediaAttemptedAt *time.Time `json:"-"`
+
+	// Signed download URL for the user listing the attachment; not stored
+	URL string `json:"url,omitempty" gorm:"-"`
+}
+
+// MediaVariant is a rendition of an attachment, such as a thumbnail or the poster frame of a video
+type MediaVariant struct {
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url,omitempty"` // signed like Attachment.URL; never stored
+}
+
+// MessageType returns the message type that best describes the attachment
+func (a *Attachment) MessageType() MessageType {
+	switch {
+	case strings.HasPrefix(a.ContentType, "image/"):
+		return MessageTypeImage
+	case strings.HasPrefix(a.ContentType, "video/"):
+		return MessageTypeVideo
+	default:
+		return MessageTypeFile
+	}
+}
+
+// HasMedia reports whether the attachment is an image or video, which the media pipeline processes
+func (a *Attachment) HasMedia() bool {
+	kind := a.MessageType()
+	return kind == MessageTypeImage || kind == MessageTypeVideo
+}
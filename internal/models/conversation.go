@@ -1,17 +1,27 @@
 package models
 
-import (
-	"time"
-
-	"github.com/google/uuid"
-)
+import "time"
 
 // Conversation represents the conversations table
 type Conversation struct {
 	ID        string    `json:"id" gorm:"type:char(36);primary_key"`
-	UUID      uuid.UUID `json:"uuid" gorm:"type:char(36);unique"`
 	IsGroup   bool      `json:"is_group"`
 	Name      *string   `json:"name"`
-	CreatedBy string    `json:"created_by" gorm:"type:char(36)"`
+	CreatedBy *string   `json:"created_by" gorm:"type:char(36)"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// RetentionSeconds is how long messages are kept; nil follows the tenant default and 0 keeps them forever
+	RetentionSeconds *int64 `json:"retention_seconds"`
+
+	// DirectKey identifies the pair of users of a direct conversation; it is unique and nil for groups
+	DirectKey *string `json:"-" gorm:"type:varchar(73)"`
+}
+
+// DirectConversationKey returns the key of the direct conversation between two users,
+// which is the same whichever of them asks.
+func DirectConversationKey(userA, userB string) string {
+	if userB < userA {
+		userA, userB = userB, userA
+	}
+	return userA + ":" + userB
 }
@@ -2,9 +2,57 @@ package models
 
 import "time"
 
+// ParticipantRole is the role of a participant in a group conversation
+type ParticipantRole string
+
+const (
+	// ParticipantRoleOwner may do everything, including deleting the group; a group has exactly one owner
+	ParticipantRoleOwner ParticipantRole = "owner"
+	// ParticipantRoleAdmin may rename the group and manage its members
+	ParticipantRoleAdmin ParticipantRole = "admin"
+	// ParticipantRoleMember may only take part in the conversation
+	ParticipantRoleMember ParticipantRole = "member"
+)
+
+// CanModerate reports whether the role may rename the group and add or remove members
+func (r ParticipantRole) CanModerate() bool {
+	return r == ParticipantRoleOwner || r == ParticipantRoleAdmin
+}
+
+// Outranks reports whether the role is strictly above other, which is required to remove or demote it
+func (r ParticipantRole) Outranks(other ParticipantRole) bool {
+	return r.rank() > other.rank()
+}
+
+func (r ParticipantRole) rank() int {
+	switch r {
+	case ParticipantRoleOwner:
+		return 2
+	case ParticipantRoleAdmin:
+		return 1
+	default:
+		return 0
+	}
+}
+
 // ConversationParticipant represents the conversation_participants table
 type ConversationParticipant struct {
-	ConversationID string    `json:"conversation_id" gorm:"type:char(36);primaryKey"`
-	UserID         string    `json:"user_id" gorm:"type:char(36);primaryKey"`
-	JoinedAt       time.Time `json:"joined_at"`
+	ConversationID string          `json:"conversation_id" gorm:"type:char(36);primaryKey"`
+	UserID         string          `json:"user_id" gorm:"type:char(36);primaryKey"`
+	Role           ParticipantRole `json:"role" gorm:"type:varchar(16);default:member"`
+	JoinedAt       time.Time       `json:"joined_at"`
+
+	// Read position of the user; messages after it count as unread
+	LastReadMessageID *string    `json:"last_read_message_id,omitempty" gorm:"type:char(36)"`
+	LastReadAt        *time.Time `json:"last_read_at,omitempty"`
+
+	// Settings of the conversation for this user only
+	MutedUntil *time.Time `json:"muted_until,omitempty"`
+	PinnedAt   *time.Time `json:"pinned_at,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
+
+// IsMuted reports whether the user muted the conversation at the given time
+func (p *ConversationParticipant) IsMuted(at time.Time) bool {
+	return p.MutedUntil != nil && p.MutedUntil.After(at)
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// MessageMention represents the message_mentions table
+// A user is mentioned at most once per message, by name or by @all.
+type MessageMention struct {
+	MessageID      string    `json:"message_id" gorm:"type:char(36);primaryKey"`
+	UserID         string    `json:"user_id" gorm:"type:char(36);primaryKey"`
+	ConversationID string    `json:"conversation_id" gorm:"type:char(36)"`
+	Everyone       bool      `json:"everyone"` // Mentioned by @all rather than by name
+	CreatedAt      time.Time `json:"created_at"`
+}
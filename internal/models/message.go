@@ -13,6 +13,7 @@ const (
 	MessageTypeImage MessageType = "image"
 	MessageTypeVideo MessageType = "video"
 	MessageTypeFile  MessageType = "file"
+	MessageTypeVoice MessageType = "voice"
 )
 
 // Message represents the messages table
@@ -23,5 +24,45 @@ type Message struct {
 	Content        string         `json:"content"`
 	MessageType    MessageType    `json:"message_type"`
 	Metadata       datatypes.JSON `json:"metadata,omitempty"`
+	ReplyToID      *string        `json:"reply_to_id,omitempty" gorm:"type:char(36)"`
 	CreatedAt      time.Time      `json:"created_at"`
+	EditedAt       *time.Time     `json:"edited_at,omitempty"`
+	DeletedAt      *time.Time     `json:"deleted_at,omitempty"`
+
+	// Seq numbers the messages of a conversation in the order they were stored, starting at 1
+	Seq int64 `json:"seq"`
+
+	// ClientMessageID is chosen by the sending client and unique per sender, so retried sends are stored once
+	ClientMessageID *string `json:"client_message_id,omitempty" gorm:"type:varchar(64)"`
+
+	// Filled in when listing messages; not stored in the messages table
+	ReplyTo     *Message        `json:"reply_to,omitempty" gorm:"-"`
+	ReplyCount  int             `json:"reply_count,omitempty" gorm:"-"`
+	LastReplyAt *time.Time      `json:"last_reply_at,omitempty" gorm:"-"`
+	Reactions   []ReactionCount `json:"reactions,omitempty" gorm:"-"`
+
+	// Attachments shared by the message; linked in the same transaction that stores it
+	Attachments []*Attachment `json:"attachments,omitempty" gorm:"-"`
+}
+
+// ThreadSummary aggregates the replies of a root message
+type ThreadSummary struct {
+	MessageID   string
+	ReplyCount  int
+	LastReplyAt time.Time
+}
+
+// IsDeleted reports whether the message has been replaced by a tombstone
+func (m *Message) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
+// MessageRevision represents the message_revisions table
+// Each row holds the content of a message before one of its edits
+type MessageRevision struct {
+	ID        string    `gorm:"primaryKey;type:char(36)" json:"id"`
+	MessageID string    `json:"message_id" gorm:"type:char(36)"`
+	Content   string    `json:"content"`
+	EditedBy  string    `json:"edited_by" gorm:"type:char(36)"`
+	CreatedAt time.Time `json:"created_at"`
 }
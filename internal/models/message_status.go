@@ -2,10 +2,22 @@ package models
 
 import "time"
 
+type MessageStatusType string
+
+const (
+	MessageStatusSent      MessageStatusType = "sent"
+	MessageStatusDelivered MessageStatusType = "delivered"
+	MessageStatusRead      MessageStatusType = "read"
+)
+
 // MessageStatus represents the message_status table
 type MessageStatus struct {
-	MessageID int       `json:"message_id"`
-	UserID    int       `json:"user_id"`
-	Status    string    `json:"status"`
-	UpdatedAt time.Time `json:"updated_at"`
+	MessageID string            `json:"message_id" gorm:"type:char(36);primaryKey"`
+	UserID    string            `json:"user_id" gorm:"type:char(36);primaryKey"`
+	Status    MessageStatusType `json:"status"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+func (*MessageStatus) TableName() string {
+	return "message_status"
 }
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// OutboxEvent represents the chat_outbox table
+// An event is stored in the transaction of the change it describes and published to the hub afterwards.
+type OutboxEvent struct {
+	ID           int64          `json:"id" gorm:"primaryKey;autoIncrement"`
+	Topic        string         `json:"topic" gorm:"type:varchar(128)"`
+	ExcludeID    string         `json:"exclude_id" gorm:"type:varchar(64)"` // Clients with this ID do not receive the event
+	Payload      datatypes.JSON `json:"payload" gorm:"type:jsonb"`
+	CreatedAt    time.Time      `json:"created_at"`
+	DispatchedAt *time.Time     `json:"dispatched_at,omitempty"`
+}
+
+func (*OutboxEvent) TableName() string {
+	return "chat_outbox"
+}
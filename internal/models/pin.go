@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// PinnedMessage represents the pinned_messages table
+// A message is pinned at most once; the pin goes away when the message is deleted.
+type PinnedMessage struct {
+	MessageID      string    `json:"message_id" gorm:"type:char(36);primaryKey"`
+	ConversationID string    `json:"conversation_id" gorm:"type:char(36)"`
+	PinnedBy       *string   `json:"pinned_by" gorm:"type:char(36)"` // nil once the user is deleted
+	PinnedAt       time.Time `json:"pinned_at"`
+}
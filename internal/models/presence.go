@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceAway    PresenceStatus = "away"
+	PresenceOffline PresenceStatus = "offline"
+)
+
+// UserPresence is the aggregated presence of a user across all of their connections
+type UserPresence struct {
+	UserID   string         `json:"user_id"`
+	Status   PresenceStatus `json:"status"`
+	LastSeen *time.Time     `json:"last_seen,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// MessageReaction represents the message_reactions table
+// A user reacts at most once with each emoji to a message.
+type MessageReaction struct {
+	MessageID string    `json:"message_id" gorm:"type:char(36);primaryKey"`
+	UserID    string    `json:"user_id" gorm:"type:char(36);primaryKey"`
+	Emoji     string    `json:"emoji" gorm:"type:varchar(64);primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReactionCount aggregates the reactions with one emoji on a message
+type ReactionCount struct {
+	MessageID string `json:"-"`
+	Emoji     string `json:"emoji"`
+	Count     int    `json:"count"`
+	Reacted   bool   `json:"reacted"` // Whether the viewing user is among the reactors
+}
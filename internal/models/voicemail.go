@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Voicemail represents the voicemails table
+type Voicemail struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	CallID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"call_id"`
+	SenderID    uuid.UUID `gorm:"type:uuid;not null" json:"sender_id"`
+	RecipientID uuid.UUID `gorm:"type:uuid;not null" json:"recipient_id"`
+	MessageID   string    `gorm:"type:uuid" json:"message_id"`
+	StorageKey  string    `gorm:"not null" json:"-"`
+	ContentType string    `gorm:"not null" json:"content_type"`
+	SizeBytes   int64     `gorm:"not null" json:"size_bytes"`
+	DurationMs  int64     `gorm:"not null" json:"duration_ms"`
+	CreatedAt   time.Time `gorm:"type:timestamptz;not null;default:now()" json:"created_at"`
+}
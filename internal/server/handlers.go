@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"time"
 
 	conversationHttp "video-call/internal/chat/delivery/http"
 	conversationWs "video-call/internal/chat/delivery/ws"
@@ -11,6 +12,7 @@ import (
 	apiMiddlewares "video-call/internal/middleware"
 
 	"video-call/pkg/metric"
+	"video-call/pkg/storage"
 	"video-call/pkg/websocket"
 
 	"github.com/gin-contrib/requestid"
@@ -47,39 +49,66 @@ func (s *Server) MapHandlers() error {
 
 	metrics.SetSkipPath([]string{"readiness"})
 
-	conversationRepo := conversationRepository.NewRepository(s.db)
+	conversationRepo := conversationRepository.NewRepository(s.db, time.Duration(s.cfg.Chat.DefaultRetentionHours)*time.Hour)
 	conversationRedisRepo := conversationRepository.NewRedisRepo(s.redis)
 	authRepo := authRepository.NewRepository(s.db)
 	authRedisRepo := authRepository.NewRedisRepo(s.redis)
 
-	conversationUC := conversationUseCase.NewUseCase(s.cfg, conversationRepo, conversationRedisRepo, s.logger)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: s.cfg.Redis.Standalone.RedisAddr,
+	})
+
+	redisHub := websocket.NewRedisHub(redisClient)
+	chatPublisher := conversationWs.NewPublisher(redisHub)
+	chatOutbox := conversationRepository.NewOutbox(s.db)
+
+	mediaStore, err := storage.New(&s.cfg.Storage)
+	if err != nil {
+		s.logger.Errorf(ctx, "Storage init Error: %s", err)
+		return err
+	}
+
 	authUC := authUseCase.NewUseCase(s.cfg, authRepo, authRedisRepo, s.logger)
+	conversationUC := conversationUseCase.NewUseCase(s.cfg, conversationRepo, conversationRedisRepo, authUC, chatPublisher, chatOutbox, mediaStore, s.logger)
 
 	authHandlers := authHttp.NewHandlers(s.cfg, authUC, s.logger)
 
 	callRepo := signalingRepo.NewPostgresRepository(s.db)
-	callUC := signalingUC.NewUseCase(s.cfg, callRepo, s.logger)
+	callUC := signalingUC.NewUseCase(s.cfg, callRepo, conversationUC, mediaStore, s.logger)
 	wsNotificationHandler := signalingWs.NewWsNotificationHandler()
-	callREST := signalingHttp.NewHandler(callUC, wsNotificationHandler, s.logger)
+	callREST := signalingHttp.NewHandler(s.cfg, callUC, wsNotificationHandler, s.logger)
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: s.cfg.Redis.Standalone.RedisAddr,
-	})
+	messageQueue, err := conversationRepository.NewMessageQueue(ctx, redisClient)
+	if err != nil {
+		s.logger.Errorf(ctx, "Message queue init Error: %s", err)
+		return err
+	}
+	messageWriter, err := conversationUseCase.NewMessageWriter(s.cfg, conversationUC, messageQueue)
+	if err != nil {
+		s.logger.Errorf(ctx, "Message writer init Error: %s", err)
+		return err
+	}
+	s.onShutdown = append(s.onShutdown, messageWriter.Close)
 
-	redisHub := websocket.NewRedisHub(redisClient)
+	outboxRelay := conversationUseCase.NewOutboxRelay(chatOutbox, redisHub)
+	s.onShutdown = append(s.onShutdown, outboxRelay.Close)
+
+	retentionPurger := conversationUseCase.NewRetentionPurger(s.cfg, conversationUC)
+	s.onShutdown = append(s.onShutdown, retentionPurger.Close)
 
-	messageWriter := conversationUseCase.NewMessageWriter(conversationUC, 4, 1000) // 4 worker, 1000 queue
+	presenceTracker := conversationUseCase.NewPresenceTracker(conversationUC)
+	s.onShutdown = append(s.onShutdown, presenceTracker.Close)
 
 	wsChatHandler := conversationWs.NewWsHandler(redisHub, conversationUC, messageWriter)
 
-	chatHandlers := conversationHttp.NewHandler(conversationUC, s.logger)
+	chatHandlers := conversationHttp.NewHandler(s.cfg, conversationUC, s.logger)
 
 	mw := apiMiddlewares.NewMiddlewareManager(s.cfg, []string{"*"}, s.logger)
 
 	s.gin.Use(requestid.New())
 	s.gin.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -91,7 +120,7 @@ func (s *Server) MapHandlers() error {
 	s.gin.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	v1 := s.gin.Group("/api/v1")
-	v1.GET("/ws", wsChatHandler.ServeWs)
+	v1.GET("/ws", mw.AuthJWTMiddleware(), wsChatHandler.ServeWs)
 
 	// Create auth group and map auth routes
 	authGroup := v1.Group("/auth")
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"video-call/config"
 	"video-call/pkg/cache/redis"
@@ -16,6 +17,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// shutdownTimeout bounds the time spent draining background work on exit
+const shutdownTimeout = 30 * time.Second
+
 // Server struct
 type Server struct {
 	gin    *gin.Engine
@@ -24,6 +28,8 @@ type Server struct {
 	db     *gorm.DB
 	redis  redis.Client
 	logger logger.Logger
+	// onShutdown holds what has to be drained before the process exits
+	onShutdown []func(ctx context.Context) error
 	// hub    *websocket.Hub // Đã chuyển sang RedisHub, không cần trường này nữa
 }
 
@@ -76,6 +82,14 @@ func (s *Server) Run() error {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
+	shutdownCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	for _, shutdown := range s.onShutdown {
+		if err := shutdown(shutdownCtx); err != nil {
+			s.logger.Errorf(ctx, "Shutdown Error: %s", err)
+		}
+	}
+
 	s.logger.Info(ctx, "Server Exited Properly")
 	return nil
 }
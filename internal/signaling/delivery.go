@@ -6,4 +6,6 @@ import (
 
 type Handlers interface {
 	CreateOrJoinCall(c *gin.Context)
-}
\ No newline at end of file
+	LeaveVoicemail(c *gin.Context)
+	GetVoicemail(c *gin.Context)
+}
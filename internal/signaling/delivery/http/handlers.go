@@ -2,8 +2,12 @@ package http
 
 import (
 	"net/http"
+
+	"video-call/config"
 	"video-call/internal/signaling"
 	"video-call/pkg/logger"
+	"video-call/pkg/response"
+	"video-call/pkg/utils"
 
 	signalingWs "video-call/internal/signaling/delivery/ws"
 
@@ -11,16 +15,21 @@ import (
 	"github.com/google/uuid"
 )
 
+// multipartOverhead leaves room for multipart headers and form fields on top of the file itself.
+const multipartOverhead = 1 << 20
+
 // Handler handles HTTP requests for chat features
 type Handler struct {
+	cfg                   *config.Config
 	useCase               signaling.UseCase
 	wsNotificationHandler *signalingWs.WsNotificationHandler
 	logger                logger.Logger
 }
 
 // NewHandler creates a new chat HTTP handler
-func NewHandler(useCase signaling.UseCase, wsNotificationHandler *signalingWs.WsNotificationHandler, logger logger.Logger) *Handler {
+func NewHandler(cfg *config.Config, useCase signaling.UseCase, wsNotificationHandler *signalingWs.WsNotificationHandler, logger logger.Logger) *Handler {
 	return &Handler{
+		cfg:                   cfg,
 		useCase:               useCase,
 		wsNotificationHandler: wsNotificationHandler,
 		logger:                logger,
@@ -31,3 +40,82 @@ func (h *Handler) CreateOrJoinCall(c *gin.Context) {
 	roomID := uuid.New().String()
 	c.JSON(http.StatusOK, callResponse{RoomID: roomID})
 }
+
+// LeaveVoicemail uploads a recorded message for a missed or rejected call.
+// The request is multipart/form-data with a "file" part; its duration is read from the recording.
+func (h *Handler) LeaveVoicemail(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		response.WithError(c, response.ErrUnauthorized)
+		return
+	}
+	senderID, err := uuid.Parse(user.ID)
+	if err != nil {
+		response.WithError(c, response.ErrUnauthorized)
+		return
+	}
+	callID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	if mb := h.cfg.Media.VoicemailMaxSizeMB; mb > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(mb)<<20+multipartOverhead)
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		h.logger.Errorf(ctx, "Failed to read voicemail upload: %v", err)
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.WithError(c, err)
+		return
+	}
+	defer file.Close()
+
+	voicemail, err := h.useCase.LeaveVoicemail(ctx, callID, senderID, &signaling.VoicemailUpload{
+		Reader: file,
+		Size:   fileHeader.Size,
+	})
+	if err != nil {
+		h.logger.Errorf(ctx, "Failed to leave voicemail for call %s: %v", callID, err)
+		response.WithMappedError(c, err, signaling.MapError)
+		return
+	}
+
+	response.WithCode(c, http.StatusCreated, toVoicemailResponse(voicemail))
+}
+
+// GetVoicemail streams a voicemail recording to its sender or recipient.
+func (h *Handler) GetVoicemail(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		response.WithError(c, response.ErrUnauthorized)
+		return
+	}
+	userID, err := uuid.Parse(user.ID)
+	if err != nil {
+		response.WithError(c, response.ErrUnauthorized)
+		return
+	}
+	voicemailID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.WithError(c, response.ErrInvalidRequest)
+		return
+	}
+
+	voicemail, rc, err := h.useCase.GetVoicemail(ctx, voicemailID, userID)
+	if err != nil {
+		response.WithMappedError(c, err, signaling.MapError)
+		return
+	}
+	defer rc.Close()
+
+	c.DataFromReader(http.StatusOK, voicemail.SizeBytes, voicemail.ContentType, rc, nil)
+}
@@ -1,5 +1,33 @@
 package http
 
+import (
+	"time"
+
+	"video-call/internal/models"
+)
+
 type callResponse struct {
 	RoomID string `json:"room_id"`
 }
+
+type voicemailResponse struct {
+	ID          string    `json:"id"`
+	CallID      string    `json:"call_id"`
+	MessageID   string    `json:"message_id"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	DurationMs  int64     `json:"duration_ms"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func toVoicemailResponse(v *models.Voicemail) voicemailResponse {
+	return voicemailResponse{
+		ID:          v.ID.String(),
+		CallID:      v.CallID.String(),
+		MessageID:   v.MessageID,
+		ContentType: v.ContentType,
+		SizeBytes:   v.SizeBytes,
+		DurationMs:  v.DurationMs,
+		CreatedAt:   v.CreatedAt,
+	}
+}
@@ -11,4 +11,7 @@ import (
 func MapRoutes(group *gin.RouterGroup, h signaling.Handlers, mw *middleware.MiddlewareManager) {
 	group.POST("/call", h.CreateOrJoinCall)
 
+	group.Use(mw.AuthJWTMiddleware())
+	group.POST("/calls/:id/voicemail", h.LeaveVoicemail)
+	group.GET("/voicemails/:id", h.GetVoicemail)
 }
@@ -1,10 +1,44 @@
 package signaling
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+)
 
 var (
-    ErrCallExists         = errors.New("call already exists between these users")
-    ErrCallNotFound       = errors.New("call not found")
-    ErrInvalidTransition  = errors.New("invalid call state transition")
-    ErrPermissionDenied   = errors.New("permission denied for this call")
-) 
\ No newline at end of file
+	ErrCallExists        = errors.New("call already exists between these users")
+	ErrCallNotFound      = errors.New("call not found")
+	ErrInvalidTransition = errors.New("invalid call state transition")
+	ErrPermissionDenied  = errors.New("permission denied for this call")
+
+	ErrVoicemailNotAllowed  = errors.New("voicemail can only be left by the caller of a missed or rejected call")
+	ErrVoicemailExists      = errors.New("voicemail already left for this call")
+	ErrVoicemailNotFound    = errors.New("voicemail not found")
+	ErrVoicemailTooLarge    = errors.New("voicemail exceeds the maximum size")
+	ErrVoicemailTooLong     = errors.New("voicemail exceeds the maximum duration")
+	ErrInvalidDuration      = errors.New("invalid voicemail duration")
+	ErrUnsupportedMediaType = errors.New("unsupported voicemail media type")
+	ErrVoicemailUnavailable = errors.New("voicemails cannot be checked on this server")
+)
+
+// MapError maps a signaling error to an HTTP status code and message.
+func MapError(err error) (status int, message string) {
+	switch {
+	case errors.Is(err, ErrCallNotFound), errors.Is(err, ErrVoicemailNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, ErrCallExists), errors.Is(err, ErrVoicemailExists):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, ErrPermissionDenied), errors.Is(err, ErrVoicemailNotAllowed):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrInvalidDuration):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, ErrVoicemailTooLarge), errors.Is(err, ErrVoicemailTooLong):
+		return http.StatusRequestEntityTooLarge, err.Error()
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType, err.Error()
+	case errors.Is(err, ErrVoicemailUnavailable):
+		return http.StatusServiceUnavailable, err.Error()
+	default:
+		return http.StatusInternalServerError, "Internal server error"
+	}
+}
@@ -9,8 +9,16 @@ import (
 )
 
 type Repository interface {
+	// Transaction runs fn in a database transaction shared with the chat repository;
+	// repository calls made with the context passed to fn take part in it
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+
 	Create(ctx context.Context, call *models.Call) error
 	UpdateStatus(ctx context.Context, callID uuid.UUID, from, to models.CallStatus, answeredAt, endedAt *time.Time) error
 	GetActiveByUserPair(ctx context.Context, userA, userB uuid.UUID) (*models.Call, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Call, error)
+
+	CreateVoicemail(ctx context.Context, voicemail *models.Voicemail) error
+	GetVoicemailByID(ctx context.Context, id uuid.UUID) (*models.Voicemail, error)
+	GetVoicemailByCallID(ctx context.Context, callID uuid.UUID) (*models.Voicemail, error)
 }
@@ -7,6 +7,7 @@ import (
 
 	"video-call/internal/models"
 	"video-call/internal/signaling"
+	"video-call/pkg/database/postgres"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -70,3 +71,33 @@ func (r *postgresRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Call,
 	}
 	return &call, err
 }
+
+func (r *postgresRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return postgres.Transaction(ctx, r.db, fn)
+}
+
+func (r *postgresRepo) CreateVoicemail(ctx context.Context, voicemail *models.Voicemail) error {
+	err := postgres.Conn(ctx, r.db).Create(voicemail).Error
+	if postgres.IsUniqueViolation(err) {
+		return signaling.ErrVoicemailExists
+	}
+	return err
+}
+
+func (r *postgresRepo) GetVoicemailByID(ctx context.Context, id uuid.UUID) (*models.Voicemail, error) {
+	var voicemail models.Voicemail
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&voicemail).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, signaling.ErrVoicemailNotFound
+	}
+	return &voicemail, err
+}
+
+func (r *postgresRepo) GetVoicemailByCallID(ctx context.Context, callID uuid.UUID) (*models.Voicemail, error) {
+	var voicemail models.Voicemail
+	err := r.db.WithContext(ctx).Where("call_id = ?", callID).First(&voicemail).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, signaling.ErrVoicemailNotFound
+	}
+	return &voicemail, err
+}
@@ -2,6 +2,7 @@ package signaling
 
 import (
 	"context"
+	"io"
 	"time"
 	"video-call/internal/models"
 
@@ -12,4 +13,17 @@ type UseCase interface {
 	CreateOrJoinCall(ctx context.Context, userA, userB uuid.UUID) (*models.Call, string, error)
 	UpdateCallStatus(ctx context.Context, callID uuid.UUID, from, to models.CallStatus, answeredAt, endedAt *time.Time) error
 	GetCallByID(ctx context.Context, id uuid.UUID) (*models.Call, error)
+
+	// LeaveVoicemail stores a recording left by the caller of a missed or rejected call
+	// and posts it into the callee's direct conversation with the caller
+	LeaveVoicemail(ctx context.Context, callID, senderID uuid.UUID, upload *VoicemailUpload) (*models.Voicemail, error)
+	// GetVoicemail opens a voicemail recording for its sender or recipient while the message announcing it
+	// is neither deleted nor expired. The caller must close the reader
+	GetVoicemail(ctx context.Context, voicemailID, userID uuid.UUID) (*models.Voicemail, io.ReadCloser, error)
+}
+
+// VoicemailUpload carries a recorded voicemail submitted by the caller.
+type VoicemailUpload struct {
+	Reader io.Reader
+	Size   int64
 }
@@ -4,9 +4,12 @@ import (
 	"context"
 	"time"
 	"video-call/config"
+	"video-call/internal/chat"
 	"video-call/internal/models"
 	"video-call/internal/signaling"
 	"video-call/pkg/logger"
+	"video-call/pkg/media"
+	"video-call/pkg/storage"
 
 	"github.com/google/uuid"
 )
@@ -15,14 +18,20 @@ import (
 type usecase struct {
 	cfg    *config.Config
 	repo   signaling.Repository
+	chatUC chat.UseCase
+	store  storage.Storage
+	ffmpeg *media.FFmpeg
 	logger logger.Logger
 }
 
 // NewUseCase is the constructor for the chat use case.
-func NewUseCase(cfg *config.Config, repo signaling.Repository, logger logger.Logger) signaling.UseCase {
+func NewUseCase(cfg *config.Config, repo signaling.Repository, chatUC chat.UseCase, store storage.Storage, logger logger.Logger) signaling.UseCase {
 	return &usecase{
 		cfg:    cfg,
 		repo:   repo,
+		chatUC: chatUC,
+		store:  store,
+		ffmpeg: media.NewFFmpeg(cfg.Media.FFmpegPath, cfg.Media.FFprobePath),
 		logger: logger,
 	}
 }
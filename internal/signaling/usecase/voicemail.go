@@ -0,0 +1,221 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"video-call/internal/chat"
+	"video-call/internal/models"
+	"video-call/internal/signaling"
+	"video-call/pkg/media"
+	"video-call/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultVoicemailMaxSizeMB   = 25
+	defaultVoicemailMaxDuration = 60 * time.Second
+)
+
+// voicemailExtensions lists the accepted recording formats and their file extensions.
+var voicemailExtensions = map[string]string{
+	"video/webm": ".webm",
+	"video/mp4":  ".mp4",
+}
+
+func (u *usecase) voicemailMaxSize() int64 {
+	mb := u.cfg.Media.VoicemailMaxSizeMB
+	if mb <= 0 {
+		mb = defaultVoicemailMaxSizeMB
+	}
+	return int64(mb) << 20
+}
+
+func (u *usecase) voicemailMaxDuration() time.Duration {
+	if u.cfg.Media.VoicemailMaxDuration <= 0 {
+		return defaultVoicemailMaxDuration
+	}
+	return time.Duration(u.cfg.Media.VoicemailMaxDuration) * time.Second
+}
+
+func (u *usecase) LeaveVoicemail(ctx context.Context, callID, senderID uuid.UUID, upload *signaling.VoicemailUpload) (*models.Voicemail, error) {
+	call, err := u.repo.GetByID(ctx, callID)
+	if err != nil {
+		return nil, err
+	}
+	if call.CallerID != senderID {
+		return nil, signaling.ErrVoicemailNotAllowed
+	}
+	if call.Status != models.CallStatusMissed && call.Status != models.CallStatusRejected {
+		return nil, signaling.ErrVoicemailNotAllowed
+	}
+	if _, err := u.repo.GetVoicemailByCallID(ctx, callID); err == nil {
+		return nil, signaling.ErrVoicemailExists
+	} else if !errors.Is(err, signaling.ErrVoicemailNotFound) {
+		return nil, err
+	}
+
+	maxSize := u.voicemailMaxSize()
+	if upload.Size > maxSize {
+		return nil, signaling.ErrVoicemailTooLarge
+	}
+
+	body, err := storage.NewUpload(upload.Reader, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	contentType := body.ContentType()
+	ext, ok := voicemailExtensions[contentType]
+	if !ok {
+		return nil, signaling.ErrUnsupportedMediaType
+	}
+
+	// The recording is probed rather than trusting a client reported duration,
+	// so it is spooled to a file for ffprobe first.
+	spool, err := os.CreateTemp("", "voicemail-*"+ext)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	if _, err := io.Copy(spool, body); err != nil {
+		return nil, err
+	}
+	if body.TooLarge() {
+		return nil, signaling.ErrVoicemailTooLarge
+	}
+	duration, err := u.ffmpeg.Duration(ctx, spool.Name())
+	if errors.Is(err, media.ErrToolUnavailable) {
+		return nil, signaling.ErrVoicemailUnavailable
+	}
+	if errors.Is(err, media.ErrNoDuration) {
+		return nil, signaling.ErrInvalidDuration
+	}
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to probe voicemail for call %s: %v", callID, err)
+		return nil, signaling.ErrUnsupportedMediaType
+	}
+	if duration <= 0 {
+		return nil, signaling.ErrInvalidDuration
+	}
+	if duration > u.voicemailMaxDuration() {
+		return nil, signaling.ErrVoicemailTooLong
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	voicemailID := uuid.New()
+	key := fmt.Sprintf("voicemails/%s/%s%s", callID, voicemailID, ext)
+	if err := u.store.Put(ctx, key, spool, body.Size(), contentType); err != nil {
+		u.logger.Errorf(ctx, "Failed to store voicemail for call %s: %v", callID, err)
+		return nil, err
+	}
+
+	conversation, err := u.chatUC.GetOrCreateDirectConversation(ctx, call.CalleeID.String(), call.CallerID.String())
+	if err != nil {
+		u.deleteObject(ctx, key)
+		return nil, err
+	}
+
+	voicemail := &models.Voicemail{
+		ID:          voicemailID,
+		CallID:      callID,
+		SenderID:    call.CallerID,
+		RecipientID: call.CalleeID,
+		MessageID:   uuid.New().String(),
+		StorageKey:  key,
+		ContentType: contentType,
+		SizeBytes:   body.Size(),
+		DurationMs:  duration.Milliseconds(),
+		CreatedAt:   time.Now(),
+	}
+
+	metadata, err := json.Marshal(voicemailMetadata{
+		Kind:        "voicemail",
+		VoicemailID: voicemail.ID.String(),
+		CallID:      callID.String(),
+		URL:         fmt.Sprintf("/api/v1/signaling/voicemails/%s", voicemail.ID),
+		ContentType: contentType,
+		SizeBytes:   voicemail.SizeBytes,
+		DurationMs:  voicemail.DurationMs,
+	})
+	if err != nil {
+		u.deleteObject(ctx, key)
+		return nil, err
+	}
+
+	message := models.Message{
+		ID:             voicemail.MessageID,
+		ConversationID: conversation.ID,
+		SenderID:       call.CallerID.String(),
+		MessageType:    models.MessageTypeVideo,
+		Metadata:       metadata,
+		CreatedAt:      voicemail.CreatedAt,
+	}
+	// Both are stored or neither; a concurrent voicemail for the call fails on the voicemail row.
+	// Recipients are notified of the message once the transaction commits.
+	err = u.repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := u.repo.CreateVoicemail(ctx, voicemail); err != nil {
+			return err
+		}
+		_, err := u.chatUC.CreateMessage(ctx, message)
+		return err
+	})
+	if err != nil {
+		u.logger.Errorf(ctx, "Failed to save voicemail for call %s: %v", callID, err)
+		u.deleteObject(ctx, key)
+		return nil, err
+	}
+
+	return voicemail, nil
+}
+
+func (u *usecase) GetVoicemail(ctx context.Context, voicemailID, userID uuid.UUID) (*models.Voicemail, io.ReadCloser, error) {
+	voicemail, err := u.repo.GetVoicemailByID(ctx, voicemailID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if voicemail.SenderID != userID && voicemail.RecipientID != userID {
+		return nil, nil, signaling.ErrPermissionDenied
+	}
+	// The recording goes with the message announcing it, once deleted or expired
+	if _, err := u.chatUC.GetMessage(ctx, voicemail.MessageID, userID.String()); err != nil {
+		if errors.Is(err, chat.ErrMessageNotFound) || errors.Is(err, chat.ErrMessageDeleted) || errors.Is(err, chat.ErrNotAllowed) {
+			return nil, nil, signaling.ErrVoicemailNotFound
+		}
+		return nil, nil, err
+	}
+
+	rc, err := u.store.Get(ctx, voicemail.StorageKey)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, nil, signaling.ErrVoicemailNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return voicemail, rc, nil
+}
+
+func (u *usecase) deleteObject(ctx context.Context, key string) {
+	if err := u.store.Delete(ctx, key); err != nil {
+		u.logger.Errorf(ctx, "Failed to delete stored object %s: %v", key, err)
+	}
+}
+
+// voicemailMetadata is stored in the Metadata of the message that announces a voicemail.
+type voicemailMetadata struct {
+	Kind        string `json:"kind"`
+	VoicemailID string `json:"voicemail_id"`
+	CallID      string `json:"call_id"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	DurationMs  int64  `json:"duration_ms"`
+}
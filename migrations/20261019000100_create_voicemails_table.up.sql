@@ -0,0 +1,19 @@
+CREATE TABLE voicemails (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    call_id UUID NOT NULL REFERENCES calls(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- The voicemail row is inserted before its message in the same transaction, so that a second
+    -- voicemail for the call fails on the unique index before anything is posted. A voicemail
+    -- disappears with the message announcing it.
+    message_id UUID REFERENCES messages(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+    storage_key VARCHAR(512) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    duration_ms BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A call can carry at most one voicemail
+CREATE UNIQUE INDEX uniq_voicemails_call_id ON voicemails(call_id);
+CREATE INDEX idx_voicemails_recipient_id ON voicemails(recipient_id);
@@ -0,0 +1,2 @@
+-- Supports keyset pagination on (created_at, id) within a conversation
+CREATE INDEX idx_messages_conversation_created_at_id ON messages(conversation_id, created_at DESC, id DESC);
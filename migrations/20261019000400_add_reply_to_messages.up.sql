@@ -0,0 +1,4 @@
+ALTER TABLE messages ADD COLUMN reply_to_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+
+-- Supports thread views and reply counts of root messages
+CREATE INDEX idx_messages_reply_to_id ON messages(reply_to_id, created_at, id) WHERE reply_to_id IS NOT NULL;
@@ -0,0 +1,7 @@
+-- The 'simple' configuration neither stems nor drops stop words, which keeps
+-- search usable for every language people chat in.
+ALTER TABLE messages
+    ADD COLUMN content_tsv TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(content, ''))) STORED;
+
+CREATE INDEX idx_messages_content_tsv ON messages USING GIN (content_tsv);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_attachments_unprocessed;
+ALTER TABLE attachments DROP COLUMN IF EXISTS media_failed_at;
+ALTER TABLE attachments DROP COLUMN IF EXISTS media_attempted_at;
+ALTER TABLE attachments DROP COLUMN IF EXISTS media_attempts;
+ALTER TABLE attachments DROP COLUMN IF EXISTS processed_at;
+ALTER TABLE attachments DROP COLUMN IF EXISTS variants;
+ALTER TABLE attachments DROP COLUMN IF EXISTS blurhash;
+ALTER TABLE attachments DROP COLUMN IF EXISTS duration_ms;
+ALTER TABLE attachments DROP COLUMN IF EXISTS height;
+ALTER TABLE attachments DROP COLUMN IF EXISTS width;
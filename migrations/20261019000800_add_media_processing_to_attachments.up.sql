@@ -0,0 +1,13 @@
+ALTER TABLE attachments ADD COLUMN width INT;
+ALTER TABLE attachments ADD COLUMN height INT;
+ALTER TABLE attachments ADD COLUMN duration_ms BIGINT;
+ALTER TABLE attachments ADD COLUMN blurhash VARCHAR(64);
+ALTER TABLE attachments ADD COLUMN variants JSONB;
+ALTER TABLE attachments ADD COLUMN processed_at TIMESTAMP;
+ALTER TABLE attachments ADD COLUMN media_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE attachments ADD COLUMN media_attempted_at TIMESTAMP;
+ALTER TABLE attachments ADD COLUMN media_failed_at TIMESTAMP;
+
+-- The media pipeline claims shared attachments that are neither processed nor given up on
+CREATE INDEX idx_attachments_unprocessed ON attachments(created_at)
+    WHERE processed_at IS NULL AND media_failed_at IS NULL AND message_id IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_conversation_participants_user_id;
+DROP INDEX IF EXISTS idx_conversation_participants_owner;
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS role;
+ALTER TABLE conversations DROP COLUMN IF EXISTS created_by;
@@ -0,0 +1,21 @@
+ALTER TABLE conversations ADD COLUMN created_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE conversation_participants ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'member'
+    CHECK (role IN ('owner', 'admin', 'member'));
+
+-- The earliest member of an existing group becomes its owner
+UPDATE conversation_participants cp SET role = 'owner'
+FROM (
+    SELECT DISTINCT ON (p.conversation_id) p.conversation_id, p.user_id
+    FROM conversation_participants p
+    JOIN conversations c ON c.id = p.conversation_id AND c.is_group
+    ORDER BY p.conversation_id, p.joined_at, p.user_id
+) first_member
+WHERE cp.conversation_id = first_member.conversation_id AND cp.user_id = first_member.user_id;
+
+UPDATE conversations c SET created_by = cp.user_id
+FROM conversation_participants cp
+WHERE cp.conversation_id = c.id AND cp.role = 'owner';
+
+CREATE UNIQUE INDEX idx_conversation_participants_owner ON conversation_participants(conversation_id) WHERE role = 'owner';
+CREATE INDEX idx_conversation_participants_user_id ON conversation_participants(user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS last_read_at;
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS last_read_message_id;
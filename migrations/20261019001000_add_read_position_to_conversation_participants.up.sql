@@ -0,0 +1,2 @@
+ALTER TABLE conversation_participants ADD COLUMN last_read_message_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+ALTER TABLE conversation_participants ADD COLUMN last_read_at TIMESTAMP;
@@ -0,0 +1,3 @@
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS pinned_at;
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS muted_until;
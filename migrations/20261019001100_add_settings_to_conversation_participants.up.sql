@@ -0,0 +1,3 @@
+ALTER TABLE conversation_participants ADD COLUMN muted_until TIMESTAMP;
+ALTER TABLE conversation_participants ADD COLUMN pinned_at TIMESTAMP;
+ALTER TABLE conversation_participants ADD COLUMN archived_at TIMESTAMP;
@@ -0,0 +1,19 @@
+-- A direct conversation is keyed by its two participants ordered by ID, so each pair has at most one
+ALTER TABLE conversations ADD COLUMN direct_key VARCHAR(73);
+
+-- Existing duplicates keep their rows; the oldest conversation of a pair gets the key
+UPDATE conversations c SET direct_key = pair.direct_key
+FROM (
+    SELECT DISTINCT ON (direct_key) conversation_id, direct_key
+    FROM (
+        SELECT cp.conversation_id, MIN(cp.user_id::text COLLATE "C") || ':' || MAX(cp.user_id::text COLLATE "C") AS direct_key, MIN(c.created_at) AS created_at
+        FROM conversation_participants cp
+        JOIN conversations c ON c.id = cp.conversation_id AND NOT c.is_group
+        GROUP BY cp.conversation_id
+        HAVING COUNT(*) = 2
+    ) keyed
+    ORDER BY direct_key, created_at, conversation_id
+) pair
+WHERE c.id = pair.conversation_id;
+
+CREATE UNIQUE INDEX idx_conversations_direct_key ON conversations(direct_key);
@@ -0,0 +1,4 @@
+ALTER TABLE messages ADD COLUMN client_message_id VARCHAR(64);
+
+-- A retried send carries the same client message ID and must not store the message twice
+CREATE UNIQUE INDEX idx_messages_sender_client_message_id ON messages(sender_id, client_message_id) WHERE client_message_id IS NOT NULL;
@@ -0,0 +1,23 @@
+-- Messages are numbered per conversation so that reconnecting clients can ask for what they missed
+ALTER TABLE conversations ADD COLUMN last_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN seq BIGINT;
+
+UPDATE messages m
+SET seq = numbered.seq
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY conversation_id ORDER BY created_at, id) AS seq
+    FROM messages
+) numbered
+WHERE m.id = numbered.id;
+
+UPDATE conversations c
+SET last_seq = numbered.last_seq
+FROM (
+    SELECT conversation_id, MAX(seq) AS last_seq
+    FROM messages
+    GROUP BY conversation_id
+) numbered
+WHERE c.id = numbered.conversation_id;
+
+ALTER TABLE messages ALTER COLUMN seq SET NOT NULL;
+CREATE UNIQUE INDEX idx_messages_conversation_seq ON messages(conversation_id, seq);
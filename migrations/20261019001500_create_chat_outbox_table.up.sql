@@ -0,0 +1,12 @@
+CREATE TABLE chat_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(128) NOT NULL,
+    exclude_id VARCHAR(64) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    dispatched_at TIMESTAMP
+);
+
+-- The relay only ever scans the events it has not published yet
+CREATE INDEX idx_chat_outbox_undispatched ON chat_outbox(id) WHERE dispatched_at IS NULL;
+CREATE INDEX idx_chat_outbox_dispatched_at ON chat_outbox(dispatched_at) WHERE dispatched_at IS NOT NULL;
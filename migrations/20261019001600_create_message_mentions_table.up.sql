@@ -0,0 +1,12 @@
+CREATE TABLE message_mentions (
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    -- Whether the user was reached by @all rather than by name
+    everyone BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (message_id, user_id)
+);
+
+-- Recent mentions of a user, newest first
+CREATE INDEX idx_message_mentions_user_created_at ON message_mentions(user_id, created_at DESC, message_id DESC);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_conversations_retention;
+ALTER TABLE conversations DROP COLUMN IF EXISTS retention_seconds;
@@ -0,0 +1,4 @@
+-- NULL follows the tenant default, 0 keeps messages forever
+ALTER TABLE conversations ADD COLUMN retention_seconds BIGINT CHECK (retention_seconds >= 0);
+-- Without a tenant default only the conversations with a retention policy have messages to purge
+CREATE INDEX idx_conversations_retention ON conversations(id) WHERE retention_seconds > 0;
@@ -0,0 +1,9 @@
+CREATE TABLE pinned_messages (
+    -- Hard-deleted messages, e.g. purged after their retention, take their pin with them
+    message_id UUID PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    pinned_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    pinned_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_pinned_messages_conversation_pinned_at ON pinned_messages(conversation_id, pinned_at DESC);
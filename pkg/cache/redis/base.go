@@ -17,6 +17,13 @@ type (
 		Get(ctx context.Context, key string) ([]byte, error)
 		Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 		Del(ctx context.Context, keys ...string) error
+		HSet(ctx context.Context, key, field string, value interface{}) error
+		HGetAll(ctx context.Context, key string) (map[string]string, error)
+		HDel(ctx context.Context, key string, fields ...string) error
+		Expire(ctx context.Context, key string, expiration time.Duration) error
+		ZAdd(ctx context.Context, key string, score float64, member string) error
+		ZRangeByScore(ctx context.Context, key string, max float64, count int64) ([]string, error)
+		ZRem(ctx context.Context, key string, members ...string) (int64, error)
 		Close() error
 		Ping(ctx context.Context) error
 	}
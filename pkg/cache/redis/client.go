@@ -2,6 +2,7 @@ package redis
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"video-call/config"
@@ -49,6 +50,43 @@ func (r *RedisClient) Del(ctx context.Context, keys ...string) error {
 	return r.rdbClient.Del(ctx, keys...).Err()
 }
 
+func (r *RedisClient) HSet(ctx context.Context, key, field string, value interface{}) error {
+	return r.rdbClient.HSet(ctx, key, field, value).Err()
+}
+
+func (r *RedisClient) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return r.rdbClient.HGetAll(ctx, key).Result()
+}
+
+func (r *RedisClient) HDel(ctx context.Context, key string, fields ...string) error {
+	return r.rdbClient.HDel(ctx, key, fields...).Err()
+}
+
+func (r *RedisClient) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return r.rdbClient.Expire(ctx, key, expiration).Err()
+}
+
+func (r *RedisClient) ZAdd(ctx context.Context, key string, score float64, member string) error {
+	return r.rdbClient.ZAdd(ctx, key, &redis.Z{Score: score, Member: member}).Err()
+}
+
+// ZRangeByScore returns up to count members with a score of at most max, lowest first.
+func (r *RedisClient) ZRangeByScore(ctx context.Context, key string, max float64, count int64) ([]string, error) {
+	return r.rdbClient.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatFloat(max, 'f', -1, 64),
+		Count: count,
+	}).Result()
+}
+
+func (r *RedisClient) ZRem(ctx context.Context, key string, members ...string) (int64, error) {
+	args := make([]interface{}, len(members))
+	for i, member := range members {
+		args[i] = member
+	}
+	return r.rdbClient.ZRem(ctx, key, args...).Result()
+}
+
 func (r *RedisClient) Close() error {
 	return r.rdbClient.Close()
 }
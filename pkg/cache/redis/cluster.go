@@ -2,6 +2,7 @@ package redis
 
 import (
 	"context"
+	"strconv"
 	"strings"
 	"time"
 
@@ -48,6 +49,43 @@ func (r *RedisCluster) Del(ctx context.Context, keys ...string) error {
 	return r.rdbCluster.Del(ctx, keys...).Err()
 }
 
+func (r *RedisCluster) HSet(ctx context.Context, key, field string, value interface{}) error {
+	return r.rdbCluster.HSet(ctx, key, field, value).Err()
+}
+
+func (r *RedisCluster) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return r.rdbCluster.HGetAll(ctx, key).Result()
+}
+
+func (r *RedisCluster) HDel(ctx context.Context, key string, fields ...string) error {
+	return r.rdbCluster.HDel(ctx, key, fields...).Err()
+}
+
+func (r *RedisCluster) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return r.rdbCluster.Expire(ctx, key, expiration).Err()
+}
+
+func (r *RedisCluster) ZAdd(ctx context.Context, key string, score float64, member string) error {
+	return r.rdbCluster.ZAdd(ctx, key, &redis.Z{Score: score, Member: member}).Err()
+}
+
+// ZRangeByScore returns up to count members with a score of at most max, lowest first.
+func (r *RedisCluster) ZRangeByScore(ctx context.Context, key string, max float64, count int64) ([]string, error) {
+	return r.rdbCluster.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatFloat(max, 'f', -1, 64),
+		Count: count,
+	}).Result()
+}
+
+func (r *RedisCluster) ZRem(ctx context.Context, key string, members ...string) (int64, error) {
+	args := make([]interface{}, len(members))
+	for i, member := range members {
+		args[i] = member
+	}
+	return r.rdbCluster.ZRem(ctx, key, args...).Result()
+}
+
 func (r *RedisCluster) Close() error {
 	return r.rdbCluster.Close()
 }
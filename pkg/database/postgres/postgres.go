@@ -1,9 +1,11 @@
 package postgres
 
 import (
+	"errors"
 	"time"
 
 	"video-call/config"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -27,3 +29,12 @@ func New(cfg *config.PostgresConfig) (*gorm.DB, error) {
 
 	return db, nil
 }
+
+// uniqueViolation is the PostgreSQL SQLSTATE for unique constraint violations.
+const uniqueViolation = "23505"
+
+// IsUniqueViolation reports whether err was caused by a unique constraint violation.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// txKey is the context key of the transaction started by Transaction.
+type txKey struct{}
+
+// txState is the transaction carried by a context and the work waiting for it to commit.
+type txState struct {
+	tx          *gorm.DB
+	afterCommit []func(ctx context.Context)
+}
+
+// Transaction runs fn in a transaction of db. Queries run through Conn with the context passed
+// to fn take part in it, also those of other repositories sharing db; transactions started
+// inside fn become savepoints of this one. The transaction commits if fn returns nil.
+func Transaction(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error) error {
+	outer, _ := ctx.Value(txKey{}).(*txState)
+	var state *txState
+	err := Conn(ctx, db).Transaction(func(tx *gorm.DB) error {
+		state = &txState{tx: tx}
+		return fn(context.WithValue(ctx, txKey{}, state))
+	})
+	if err != nil {
+		return err
+	}
+	if outer != nil {
+		// A savepoint is only committed along with the transaction around it
+		outer.afterCommit = append(outer.afterCommit, state.afterCommit...)
+		return nil
+	}
+	for _, hook := range state.afterCommit {
+		hook(ctx)
+	}
+	return nil
+}
+
+// AfterCommit runs hook once the transaction carried by ctx commits, or right away outside
+// of one. Hooks of a transaction that rolls back are dropped. The context passed to hook
+// no longer carries the transaction.
+func AfterCommit(ctx context.Context, hook func(ctx context.Context)) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, hook)
+		return
+	}
+	hook(ctx)
+}
+
+// Conn returns the connection to run a query on: the transaction carried by ctx, or db outside of one.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,139 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/png" // frames are extracted as PNG
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrToolUnavailable is returned when ffmpeg or ffprobe cannot be found.
+	ErrToolUnavailable = errors.New("ffmpeg is not available")
+	// ErrNoDuration is returned when the duration of a file cannot be determined.
+	ErrNoDuration = errors.New("media duration is unknown")
+)
+
+// FFmpeg runs ffmpeg and ffprobe on media files.
+type FFmpeg struct {
+	ffmpeg  string
+	ffprobe string
+}
+
+// NewFFmpeg resolves the ffmpeg and ffprobe binaries, defaulting to the ones in PATH.
+func NewFFmpeg(ffmpegPath, ffprobePath string) *FFmpeg {
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+	if ffprobePath == "" {
+		ffprobePath = "ffprobe"
+	}
+	return &FFmpeg{ffmpeg: ffmpegPath, ffprobe: ffprobePath}
+}
+
+// Available reports whether both binaries can be executed.
+func (t *FFmpeg) Available() bool {
+	if _, err := exec.LookPath(t.ffmpeg); err != nil {
+		return false
+	}
+	_, err := exec.LookPath(t.ffprobe)
+	return err == nil
+}
+
+// Duration returns the duration of the media in a file. Files without one in their header,
+// such as WebM recorded by browsers, fall back to their streams and then to decoding them to the end.
+func (t *FFmpeg) Duration(ctx context.Context, path string) (time.Duration, error) {
+	out, err := t.run(ctx, t.ffprobe, "-v", "error", "-show_entries", "format=duration:stream=duration", "-of", "default=noprint_wrappers=1:nokey=1", path)
+	if err != nil {
+		return 0, err
+	}
+	if duration, ok := probedDuration(out); ok {
+		return duration, nil
+	}
+
+	out, err = t.run(ctx, t.ffmpeg, "-v", "error", "-nostats", "-i", path, "-f", "null", "-progress", "pipe:1", "-")
+	if err != nil {
+		return 0, err
+	}
+	if duration, ok := decodedDuration(out); ok {
+		return duration, nil
+	}
+	return 0, ErrNoDuration
+}
+
+// probedDuration returns the longest of the durations ffprobe printed, one per line.
+// Unknown ones are printed as N/A.
+func probedDuration(out []byte) (time.Duration, bool) {
+	var longest float64
+	for _, line := range strings.Split(string(out), "\n") {
+		seconds, err := strconv.ParseFloat(strings.TrimSpace(line), 64)
+		if err == nil && seconds > longest {
+			longest = seconds
+		}
+	}
+	return time.Duration(longest * float64(time.Second)), longest > 0
+}
+
+// decodedDuration returns the last position reported by the -progress output of ffmpeg.
+func decodedDuration(out []byte) (time.Duration, bool) {
+	var position int64
+	for _, line := range strings.Split(string(out), "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "out_time_us=")
+		if !ok {
+			continue
+		}
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil && us > position {
+			position = us
+		}
+	}
+	return time.Duration(position) * time.Microsecond, position > 0
+}
+
+// FirstFrame decodes the first frame of the video in a file.
+func (t *FFmpeg) FirstFrame(ctx context.Context, path string) (image.Image, error) {
+	out, err := t.run(ctx, t.ffmpeg, "-v", "error", "-i", path, "-frames:v", "1", "-f", "image2pipe", "-vcodec", "png", "-")
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(out))
+	return img, err
+}
+
+// StripMetadata copies the streams of a video into dst without container metadata such as GPS tags.
+func (t *FFmpeg) StripMetadata(ctx context.Context, src, dst string) error {
+	_, err := t.run(ctx, t.ffmpeg, "-v", "error", "-y", "-i", src, "-map", "0", "-map_metadata", "-1", "-c", "copy", dst)
+	return err
+}
+
+// DecodePCM decodes the audio in a file to mono signed 16-bit samples at sampleRate.
+func (t *FFmpeg) DecodePCM(ctx context.Context, path string, sampleRate int) ([]int16, error) {
+	out, err := t.run(ctx, t.ffmpeg, "-v", "error", "-i", path, "-vn", "-ac", "1", "-ar", strconv.Itoa(sampleRate), "-f", "s16le", "-")
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]int16, len(out)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(out[2*i:]))
+	}
+	return samples, nil
+}
+
+func (t *FFmpeg) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, ErrToolUnavailable
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New(name + ": " + err.Error() + ": " + strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
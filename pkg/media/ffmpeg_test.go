@@ -0,0 +1,38 @@
+package media
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProbedDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want time.Duration
+		ok   bool
+	}{
+		{name: "format", out: "12.500000\n", want: 12500 * time.Millisecond, ok: true},
+		{name: "streams only", out: "N/A\n3.000000\nN/A\n", want: 3 * time.Second, ok: true},
+		{name: "unknown", out: "N/A\nN/A\n", ok: false},
+		{name: "empty", out: "", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := probedDuration([]byte(tt.out))
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("probedDuration(%q) = %v, %v; want %v, %v", tt.out, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDecodedDuration(t *testing.T) {
+	out := "frame=10\nout_time_us=1000000\nprogress=continue\nout_time_us=4250000\nout_time=00:00:04.250000\nprogress=end\n"
+	if got, ok := decodedDuration([]byte(out)); !ok || got != 4250*time.Millisecond {
+		t.Errorf("decodedDuration = %v, %v; want 4.25s", got, ok)
+	}
+	if _, ok := decodedDuration([]byte("out_time_us=N/A\nprogress=end\n")); ok {
+		t.Errorf("decodedDuration of an empty decode should fail")
+	}
+}
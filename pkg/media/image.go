@@ -0,0 +1,171 @@
+// Package media processes uploaded images and videos: it strips metadata,
+// renders thumbnails and computes blurhash placeholders.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/buckket/go-blurhash"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register the WebP decoder
+)
+
+const (
+	jpegQuality      = 90
+	thumbnailQuality = 80
+	// blurhashSize is the edge of the image a blurhash is computed from; larger inputs only cost time.
+	blurhashSize = 32
+	// maxPixels refuses decompression bombs before allocating their pixels.
+	maxPixels = 50_000_000
+)
+
+// ErrImageTooLarge is returned for images with more pixels than the pipeline accepts.
+var ErrImageTooLarge = errors.New("image dimensions are too large")
+
+// Thumbnail is a JPEG rendition of an image fitting in a Size x Size box.
+type Thumbnail struct {
+	Size   int
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// ImageResult is the outcome of processing an image.
+type ImageResult struct {
+	Width      int
+	Height     int
+	BlurHash   string
+	Thumbnails []Thumbnail
+	// Stripped is the image re-encoded without EXIF and other metadata, upright.
+	// WebP images are not re-encoded but rewritten without their EXIF and XMP chunks.
+	// It is nil for GIF, which is kept as uploaded.
+	Stripped []byte
+}
+
+// ProcessImage decodes an image, applies its EXIF orientation and renders a
+// thumbnail for every size smaller than the image.
+func ProcessImage(r io.Reader, sizes []int) (*ImageResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImageResult{}
+	switch format {
+	case "jpeg":
+		// Re-encoding drops the EXIF segment, so bake its orientation into the pixels first.
+		img = applyOrientation(img, jpegOrientation(data))
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		result.Stripped = buf.Bytes()
+	case "png":
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		result.Stripped = buf.Bytes()
+	case "webp":
+		if result.Stripped, err = stripWebP(data); err != nil {
+			return nil, err
+		}
+	}
+	// GIF animations are kept as uploaded; the format has no EXIF.
+
+	if err := describe(result, img, sizes); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ProcessFrame renders the thumbnails and blurhash of an already decoded image, such as a video frame.
+func ProcessFrame(img image.Image, sizes []int) (*ImageResult, error) {
+	result := &ImageResult{}
+	if err := describe(result, img, sizes); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func describe(result *ImageResult, img image.Image, sizes []int) error {
+	bounds := img.Bounds()
+	result.Width, result.Height = bounds.Dx(), bounds.Dy()
+
+	var err error
+	if result.BlurHash, err = blurHash(img); err != nil {
+		return err
+	}
+	for _, size := range sizes {
+		if size <= 0 || (result.Width <= size && result.Height <= size) {
+			continue
+		}
+		thumbnail, err := renderThumbnail(img, size)
+		if err != nil {
+			return err
+		}
+		result.Thumbnails = append(result.Thumbnails, *thumbnail)
+	}
+	return nil
+}
+
+// EncodeJPEG encodes an image at the quality used for full size renditions.
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func renderThumbnail(img image.Image, size int) (*Thumbnail, error) {
+	width, height := fit(img.Bounds().Dx(), img.Bounds().Dy(), size)
+	dst := resize(img, width, height)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+	return &Thumbnail{Size: size, Width: width, Height: height, Data: buf.Bytes()}, nil
+}
+
+func blurHash(img image.Image) (string, error) {
+	width, height := fit(img.Bounds().Dx(), img.Bounds().Dy(), blurhashSize)
+	return blurhash.Encode(4, 3, resize(img, width, height))
+}
+
+func resize(img image.Image, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+	return dst
+}
+
+// fit scales width x height down to fit in a size x size box, keeping the aspect ratio.
+func fit(width, height, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+	if width >= height {
+		return size, max(1, height*size/width)
+	}
+	return max(1, width*size/height), size
+}
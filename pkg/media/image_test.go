@@ -0,0 +1,87 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestProcessImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ProcessImage(&buf, []int{100, 320, 640})
+	if err != nil {
+		t.Fatalf("ProcessImage: %v", err)
+	}
+	if result.Width != 400 || result.Height != 200 {
+		t.Errorf("size = %dx%d, want 400x200", result.Width, result.Height)
+	}
+	if result.BlurHash == "" || result.Stripped == nil {
+		t.Errorf("missing blurhash or stripped copy")
+	}
+	// The 640 box is larger than the image and is skipped.
+	if len(result.Thumbnails) != 2 {
+		t.Fatalf("got %d thumbnails, want 2", len(result.Thumbnails))
+	}
+	if th := result.Thumbnails[0]; th.Width != 100 || th.Height != 50 {
+		t.Errorf("thumbnail = %dx%d, want 100x50", th.Width, th.Height)
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.White)
+
+	rotated := applyOrientation(img, 6) // 90° clockwise
+	if b := rotated.Bounds(); b.Dx() != 1 || b.Dy() != 2 {
+		t.Fatalf("bounds = %v, want 1x2", b)
+	}
+	if r, _, _, _ := rotated.At(0, 0).RGBA(); r != 0xffff {
+		t.Errorf("top-left pixel should stay at the top after a clockwise rotation")
+	}
+}
+
+func TestStripWebP(t *testing.T) {
+	chunk := func(fourCC string, payload ...byte) []byte {
+		c := append([]byte(fourCC), byte(len(payload)), 0, 0, 0)
+		c = append(c, payload...)
+		if len(payload)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+	vp8x := chunk("VP8X", webpFlagEXIF|webpFlagXMP|0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	bitstream := chunk("VP8L", 1, 2, 3)
+	body := append([]byte("WEBP"), vp8x...)
+	body = append(body, chunk("EXIF", 'G', 'P', 'S')...)
+	body = append(body, bitstream...)
+	body = append(body, chunk("XMP ", 'x', 'm')...)
+	data := append([]byte{'R', 'I', 'F', 'F', byte(len(body)), 0, 0, 0}, body...)
+
+	stripped, err := stripWebP(data)
+	if err != nil {
+		t.Fatalf("stripWebP: %v", err)
+	}
+	want := append([]byte("WEBP"), vp8x...)
+	want[12] = 0x10 // only the alpha flag is left
+	want = append(want, bitstream...)
+	want = append([]byte{'R', 'I', 'F', 'F', byte(len(want)), 0, 0, 0}, want...)
+	if !bytes.Equal(stripped, want) {
+		t.Errorf("stripped = %q, want %q", stripped, want)
+	}
+
+	if _, err := stripWebP(data[:len(data)-4]); err == nil {
+		t.Errorf("truncated file: want an error")
+	}
+}
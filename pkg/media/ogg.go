@@ -0,0 +1,134 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	// opusSampleRate is the rate Ogg Opus granule positions are counted in, whatever the input rate.
+	opusSampleRate = 48000
+	// maxOpusGranule bounds granule positions to a day of audio; larger ones are forged.
+	maxOpusGranule = 24 * 60 * 60 * opusSampleRate
+)
+
+var (
+	// ErrNotOggOpus is returned when a stream is not an Ogg container carrying Opus audio.
+	ErrNotOggOpus = errors.New("not an Ogg Opus stream")
+	// ErrCorruptOgg is returned when the pages of an Ogg stream cannot be parsed.
+	ErrCorruptOgg = errors.New("corrupt Ogg stream")
+)
+
+// OpusInfo describes an Ogg Opus stream.
+type OpusInfo struct {
+	Channels int
+	Duration time.Duration
+	// PacketSizes holds the size of every audio packet in stream order. With Opus' variable
+	// bitrate it roughly follows loudness, which serves as a waveform when decoding is not possible.
+	PacketSizes []int
+}
+
+// ParseOggOpus reads the pages of an Ogg Opus stream and computes its exact duration
+// from the last granule position and the pre-skip of the Opus header.
+func ParseOggOpus(r io.Reader) (*OpusInfo, error) {
+	var (
+		info        OpusInfo
+		serial      uint32
+		preSkip     int64
+		lastGranule int64
+		packet      []byte
+		packets     int
+		page        [27]byte
+	)
+
+	for first := true; ; first = false {
+		if _, err := io.ReadFull(r, page[:]); err != nil {
+			if errors.Is(err, io.EOF) && !first {
+				break
+			}
+			if first {
+				return nil, ErrNotOggOpus
+			}
+			return nil, ErrCorruptOgg
+		}
+		if !bytes.Equal(page[:4], []byte("OggS")) || page[4] != 0 {
+			if first {
+				return nil, ErrNotOggOpus
+			}
+			return nil, ErrCorruptOgg
+		}
+		headerType := page[5]
+		granule := int64(binary.LittleEndian.Uint64(page[6:]))
+		pageSerial := binary.LittleEndian.Uint32(page[14:])
+		if first {
+			if headerType&0x02 == 0 { // the first page must begin the logical stream
+				return nil, ErrNotOggOpus
+			}
+			serial = pageSerial
+		}
+
+		segments := make([]byte, page[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return nil, ErrCorruptOgg
+		}
+		size := 0
+		for _, s := range segments {
+			size += int(s)
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, ErrCorruptOgg
+		}
+		if pageSerial != serial {
+			continue // pages of another multiplexed logical stream
+		}
+		if granule != -1 { // -1 marks pages on which no packet ends
+			if granule < 0 || granule > maxOpusGranule {
+				return nil, ErrCorruptOgg
+			}
+			lastGranule = granule
+		}
+
+		// Reassemble packets from the lacing values; a value of 255 continues the packet.
+		offset := 0
+		for _, s := range segments {
+			packet = append(packet, body[offset:offset+int(s)]...)
+			offset += int(s)
+			if s == 255 {
+				continue
+			}
+			switch packets {
+			case 0:
+				if len(packet) < 19 || !bytes.Equal(packet[:8], []byte("OpusHead")) {
+					return nil, ErrNotOggOpus
+				}
+				info.Channels = int(packet[9])
+				preSkip = int64(binary.LittleEndian.Uint16(packet[10:]))
+			case 1:
+				if !bytes.HasPrefix(packet, []byte("OpusTags")) {
+					return nil, ErrNotOggOpus
+				}
+			default:
+				info.PacketSizes = append(info.PacketSizes, len(packet))
+			}
+			packets++
+			packet = packet[:0]
+		}
+		if headerType&0x04 != 0 { // end of stream
+			break
+		}
+	}
+
+	if packets < 2 {
+		return nil, ErrNotOggOpus
+	}
+	samples := lastGranule - preSkip
+	if samples < 0 {
+		samples = 0
+	}
+	info.Duration = time.Duration(samples/opusSampleRate)*time.Second + time.Duration(samples%opusSampleRate)*time.Second/opusSampleRate
+	return &info, nil
+}
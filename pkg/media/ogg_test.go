@@ -0,0 +1,124 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// oggPage encodes one Ogg page holding whole packets of less than 255 bytes.
+func oggPage(serial uint32, headerType byte, granule int64, packets ...[]byte) []byte {
+	page := make([]byte, 27, 64)
+	copy(page, "OggS")
+	page[5] = headerType
+	binary.LittleEndian.PutUint64(page[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:], serial)
+	page[26] = byte(len(packets))
+	for _, packet := range packets {
+		page = append(page, byte(len(packet)))
+	}
+	for _, packet := range packets {
+		page = append(page, packet...)
+	}
+	return page
+}
+
+func opusHead(channels byte, preSkip uint16) []byte {
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1 // version
+	head[9] = channels
+	binary.LittleEndian.PutUint16(head[10:], preSkip)
+	binary.LittleEndian.PutUint32(head[12:], 48000)
+	return head
+}
+
+func oggStream(pages ...[]byte) []byte {
+	return bytes.Join(pages, nil)
+}
+
+func TestParseOggOpus(t *testing.T) {
+	const serial, foreign = 1, 2
+	head := oggPage(serial, 0x02, 0, opusHead(2, 312))
+	tags := oggPage(serial, 0, 0, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00"))
+	audio := oggPage(serial, 0x04, 48000+312, make([]byte, 40), make([]byte, 60))
+	valid := oggStream(head, tags, audio)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		err      error
+		channels int
+		duration time.Duration
+		sizes    []int
+	}{
+		{
+			name:     "valid",
+			data:     valid,
+			channels: 2,
+			duration: time.Second, // granule minus pre-skip
+			sizes:    []int{40, 60},
+		},
+		{
+			name: "truncated page",
+			data: valid[:len(valid)-10],
+			err:  ErrCorruptOgg,
+		},
+		{
+			name: "wrong magic",
+			data: append([]byte("OggX"), valid[4:]...),
+			err:  ErrNotOggOpus,
+		},
+		{
+			name: "missing OpusTags",
+			data: oggStream(head, audio),
+			err:  ErrNotOggOpus,
+		},
+		{
+			name: "forged granule",
+			data: oggStream(head, tags, oggPage(serial, 0x04, 1<<62, make([]byte, 40))),
+			err:  ErrCorruptOgg,
+		},
+		{
+			name:     "long stream",
+			data:     oggStream(head, tags, oggPage(serial, 0x04, 3*60*60*48000+312+24000, make([]byte, 40))),
+			channels: 2,
+			duration: 3*time.Hour + 500*time.Millisecond,
+			sizes:    []int{40},
+		},
+		{
+			name: "foreign serial",
+			data: oggStream(
+				head,
+				oggPage(foreign, 0x02, 0, []byte("\x80theora")),
+				tags,
+				oggPage(foreign, 0, 96000, make([]byte, 100)),
+				audio,
+			),
+			channels: 2,
+			duration: time.Second,
+			sizes:    []int{40, 60},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParseOggOpus(bytes.NewReader(tt.data))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseOggOpus: %v", err)
+			}
+			if info.Channels != tt.channels || info.Duration != tt.duration || !reflect.DeepEqual(info.PacketSizes, tt.sizes) {
+				t.Errorf("got %d channels, %v, packets %v; want %d channels, %v, packets %v",
+					info.Channels, info.Duration, info.PacketSizes, tt.channels, tt.duration, tt.sizes)
+			}
+		})
+	}
+}
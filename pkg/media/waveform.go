@@ -0,0 +1,64 @@
+package media
+
+// WaveformPeak is the value of the loudest bucket of a waveform.
+const WaveformPeak = 100
+
+// Waveform downsamples magnitudes to buckets peak values scaled to 0..WaveformPeak.
+// Fewer magnitudes than buckets yield a shorter waveform.
+func Waveform(magnitudes []float64, buckets int) []int {
+	if len(magnitudes) == 0 || buckets <= 0 {
+		return []int{}
+	}
+	if len(magnitudes) < buckets {
+		buckets = len(magnitudes)
+	}
+
+	peaks := make([]float64, buckets)
+	loudest := 0.0
+	for i, m := range magnitudes {
+		if m < 0 {
+			m = -m
+		}
+		b := i * buckets / len(magnitudes)
+		if m > peaks[b] {
+			peaks[b] = m
+		}
+		if m > loudest {
+			loudest = m
+		}
+	}
+
+	waveform := make([]int, buckets)
+	if loudest == 0 {
+		return waveform
+	}
+	for i, peak := range peaks {
+		waveform[i] = int(peak/loudest*WaveformPeak + 0.5)
+	}
+	return waveform
+}
+
+// PCMMagnitudes converts 16-bit samples to magnitudes for Waveform.
+func PCMMagnitudes(samples []int16) []float64 {
+	magnitudes := make([]float64, len(samples))
+	for i, s := range samples {
+		magnitudes[i] = float64(s)
+	}
+	return magnitudes
+}
+
+// PacketMagnitudes converts Opus packet sizes to magnitudes for Waveform.
+// The smallest packets are silence, so they are taken as the floor.
+func PacketMagnitudes(sizes []int) []float64 {
+	floor := 0
+	for i, size := range sizes {
+		if i == 0 || size < floor {
+			floor = size
+		}
+	}
+	magnitudes := make([]float64, len(sizes))
+	for i, size := range sizes {
+		magnitudes[i] = float64(size - floor)
+	}
+	return magnitudes
+}
@@ -0,0 +1,21 @@
+package media
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWaveform(t *testing.T) {
+	got := Waveform([]float64{0, 5, -10, 2, 0, 0, 4, 1}, 4)
+	want := []int{50, 100, 0, 40}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Waveform = %v, want %v", got, want)
+	}
+
+	if got := Waveform([]float64{3, 6}, 64); len(got) != 2 {
+		t.Errorf("short input gave %d buckets, want 2", len(got))
+	}
+	if got := Waveform(nil, 64); len(got) != 0 {
+		t.Errorf("empty input gave %v", got)
+	}
+}
@@ -0,0 +1,54 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// VP8X flags announcing the metadata chunks of an extended WebP file.
+const (
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+var errInvalidWebP = errors.New("invalid WebP container")
+
+// stripWebP removes the EXIF and XMP chunks of a WebP file. There is no WebP encoder to
+// re-encode it with, and the image data is left as is: only the RIFF container is rewritten.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errInvalidWebP
+	}
+	end := 8 + int(binary.LittleEndian.Uint32(data[4:8]))
+	if end > len(data) {
+		return nil, errInvalidWebP
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	for i := 12; i < end; {
+		if i+8 > end {
+			return nil, errInvalidWebP
+		}
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		next := i + 8 + size + size&1 // chunks are padded to an even size
+		if size < 0 || next > end {
+			return nil, errInvalidWebP
+		}
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, data[i:next]...)
+			if size > 0 {
+				out[start+8] &^= webpFlagEXIF | webpFlagXMP
+			}
+		default:
+			out = append(out, data[i:next]...)
+		}
+		i = next
+	}
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
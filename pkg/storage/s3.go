@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"time"
+
+	"video-call/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize is the size of the parts of multipart uploads.
+const s3PartSize = 16 << 20
+
+// S3Storage stores objects in a bucket of an S3 compatible service such as MinIO.
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Storage connects to the configured endpoint and creates the bucket if needed.
+func NewS3Storage(cfg *config.StorageConfig) (*S3Storage, error) {
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure: cfg.S3UseSSL,
+		Region: cfg.S3Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, cfg.S3Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.S3Bucket, minio.MakeBucketOptions{Region: cfg.S3Region}); err != nil {
+			return nil, err
+		}
+	}
+	return &S3Storage{client: client, bucket: cfg.S3Bucket}, nil
+}
+
+// Put implements Storage.
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+	// Without a size the client buffers whole parts, so they are kept small.
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    s3PartSize,
+	})
+	return err
+}
+
+// Get implements Storage.
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if key == "" {
+		return nil, ErrInvalidKey
+	}
+	// GetObject is lazy, so stat first to report missing objects up front.
+	if _, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{}); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+}
+
+// Delete implements Storage.
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// PresignGet implements Presigner.
+func (s *S3Storage) PresignGet(ctx context.Context, key string, expiry time.Duration, fileName string) (string, error) {
+	params := url.Values{}
+	if fileName != "" {
+		params.Set("response-content-disposition", contentDisposition(fileName))
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, expiry, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
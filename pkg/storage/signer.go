@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"mime"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature is returned when a signed URL has been tampered with.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrSignatureExpired is returned when a signed URL is used after it expired.
+	ErrSignatureExpired = errors.New("signature expired")
+)
+
+// URLSigner signs and verifies expiring references to stored objects.
+type URLSigner struct {
+	key []byte
+}
+
+// NewURLSigner returns a signer using key as the HMAC secret.
+func NewURLSigner(key string) *URLSigner {
+	return &URLSigner{key: []byte(key)}
+}
+
+// Sign returns the signature granting access to resource until expires.
+func (s *URLSigner) Sign(resource string, expires time.Time) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(resource))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(expires.Unix(), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign for resource and the unix time expires.
+func (s *URLSigner) Verify(resource string, expires int64, signature string) error {
+	expected := s.Sign(resource, time.Unix(expires, 0))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expires {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// contentDisposition returns a header value that downloads an object as fileName.
+func contentDisposition(fileName string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": fileName})
+}
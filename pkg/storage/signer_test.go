@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestURLSignerVerify(t *testing.T) {
+	signer := NewURLSigner("secret")
+	expires := time.Now().Add(time.Minute)
+	signature := signer.Sign("attachment:a:u", expires)
+
+	if err := signer.Verify("attachment:a:u", expires.Unix(), signature); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := signer.Verify("attachment:a:other", expires.Unix(), signature); err != ErrInvalidSignature {
+		t.Fatalf("signature for another resource: got %v, want %v", err, ErrInvalidSignature)
+	}
+	if err := signer.Verify("attachment:a:u", expires.Unix()+60, signature); err != ErrInvalidSignature {
+		t.Fatalf("extended expiry: got %v, want %v", err, ErrInvalidSignature)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	if err := signer.Verify("attachment:a:u", past.Unix(), signer.Sign("attachment:a:u", past)); err != ErrSignatureExpired {
+		t.Fatalf("expired signature: got %v, want %v", err, ErrSignatureExpired)
+	}
+}
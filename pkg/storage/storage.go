@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"video-call/config"
+)
+
+const (
+	driverLocal = "local"
+	driverS3    = "s3"
+)
+
+var (
+	// ErrObjectNotFound is returned when no object is stored under the given key.
+	ErrObjectNotFound = errors.New("object not found")
+	// ErrInvalidKey is returned when a key is empty or escapes the storage root.
+	ErrInvalidKey = errors.New("invalid object key")
+	// ErrUnknownDriver is returned when the configured storage driver is not supported.
+	ErrUnknownDriver = errors.New("unknown storage driver")
+)
+
+// Storage is a blob store for uploaded media such as voicemails and attachments.
+type Storage interface {
+	// Put stores the content of r under key. size is the length of r, or -1 if it is not known.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is implemented by backends that can hand out expiring URLs
+// fetching an object directly from the backend.
+type Presigner interface {
+	// PresignGet returns a URL downloading the object under key as fileName until expiry elapses.
+	PresignGet(ctx context.Context, key string, expiry time.Duration, fileName string) (string, error)
+}
+
+// New returns the storage backend selected by cfg.Driver.
+func New(cfg *config.StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case "", driverLocal:
+		return NewLocalStorage(cfg.LocalDir)
+	case driverS3:
+		return NewS3Storage(cfg)
+	default:
+		return nil, ErrUnknownDriver
+	}
+}
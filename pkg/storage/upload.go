@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// sniffLen is the number of leading bytes http.DetectContentType looks at.
+const sniffLen = 512
+
+// Upload reads a file uploaded by a client. Its content type is sniffed from the first bytes
+// instead of trusting the client supplied Content-Type, and the bytes read are counted.
+// Reading stops one byte past maxSize, so that TooLarge tells an oversized file apart.
+type Upload struct {
+	r           io.Reader
+	contentType string
+	maxSize     int64
+	n           int64
+}
+
+// NewUpload sniffs the content type of r and returns an Upload reading at most maxSize+1 bytes of it.
+func NewUpload(r io.Reader, maxSize int64) (*Upload, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+	contentType := http.DetectContentType(head)
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	return &Upload{
+		r:           io.LimitReader(br, maxSize+1),
+		contentType: contentType,
+		maxSize:     maxSize,
+	}, nil
+}
+
+// ContentType returns the sniffed media type, without parameters such as the charset.
+func (u *Upload) ContentType() string {
+	return u.contentType
+}
+
+// Read implements io.Reader.
+func (u *Upload) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	u.n += int64(n)
+	return n, err
+}
+
+// Size returns the number of bytes read so far.
+func (u *Upload) Size() int64 {
+	return u.n
+}
+
+// TooLarge reports whether the file turned out to be larger than maxSize.
+func (u *Upload) TooLarge() bool {
+	return u.n > u.maxSize
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestUpload(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 600)...)
+
+	upload, err := NewUpload(bytes.NewReader(png), int64(len(png)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := upload.ContentType(); got != "image/png" {
+		t.Fatalf("content type: got %q, want image/png", got)
+	}
+	data, err := io.ReadAll(upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, png) || upload.Size() != int64(len(png)) || upload.TooLarge() {
+		t.Fatalf("read %d bytes, size %d, too large %v", len(data), upload.Size(), upload.TooLarge())
+	}
+
+	upload, err = NewUpload(strings.NewReader("hello world"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := upload.ContentType(); got != "text/plain" {
+		t.Fatalf("content type: got %q, want text/plain without parameters", got)
+	}
+	if _, err := io.Copy(io.Discard, upload); err != nil {
+		t.Fatal(err)
+	}
+	if upload.Size() != 6 || !upload.TooLarge() {
+		t.Fatalf("oversized upload: size %d, too large %v", upload.Size(), upload.TooLarge())
+	}
+}
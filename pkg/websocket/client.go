@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -33,6 +34,19 @@ type Client struct {
 
 	// OnMessage is a callback function that is called when a message is received from the client.
 	OnMessage func(message []byte)
+
+	// Accept is an optional callback deciding whether a message published on a topic is sent to the client.
+	// It runs on the dispatch goroutine of the hub and must not block.
+	Accept func(topic string, message []byte) bool
+
+	// OnDelivered is an optional callback called for every message once it was written to the connection.
+	OnDelivered func(message []byte)
+
+	// OnClose is an optional callback called once the connection has been closed.
+	OnClose func()
+
+	// lagging is set once the hub dropped a message because the send buffer was full
+	lagging atomic.Bool
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -40,6 +54,9 @@ func (c *Client) ReadPump() {
 	defer func() {
 		c.hub.Unregister(c)
 		c.conn.Close()
+		if c.OnClose != nil {
+			c.OnClose()
+		}
 	}()
 	c.conn.SetReadLimit(MAXMESSAGESIZE)
 
@@ -89,16 +106,24 @@ func (c *Client) WritePump() {
 				return
 			}
 			w.Write(message)
+			written := [][]byte{message}
 
 			n := len(c.send)
 			for i := 0; i < n; i++ {
+				next := <-c.send
 				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+				w.Write(next)
+				written = append(written, next)
 			}
 
 			if err := w.Close(); err != nil {
 				return
 			}
+			if c.OnDelivered != nil {
+				for _, m := range written {
+					c.OnDelivered(m)
+				}
+			}
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(WRITEWAIT))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -108,6 +133,36 @@ func (c *Client) WritePump() {
 	}
 }
 
+// Send queues a message for this client only, bypassing the topics.
+// It reports false instead of blocking when the send buffer is full.
+func (c *Client) Send(message []byte) bool {
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// SendWait queues a message for this client only, waiting for room in the send buffer.
+// It reports false if done is closed first.
+func (c *Client) SendWait(message []byte, done <-chan struct{}) bool {
+	select {
+	case c.send <- message:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// Close sends a close frame with the given code and reason and closes the connection.
+// The read pump then stops and unregisters the client.
+func (c *Client) Close(code int, reason string) {
+	deadline := time.Now().Add(WRITEWAIT)
+	_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	c.conn.Close()
+}
+
 // NewClient tạo một client mới. Handler sẽ chịu trách nhiệm tạo và đăng ký nó.
 func NewClient(hub HubIface, conn *websocket.Conn, id string, onMessage func(message []byte)) *Client {
 	return &Client{
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"sync"
 
+	"github.com/gorilla/websocket"
 	redis "github.com/redis/go-redis/v9"
 )
 
@@ -14,6 +16,13 @@ type HubIface interface {
 	Unregister(client *Client)
 }
 
+// envelope wraps a message on its way through Redis.
+// Exclude is set by the publishing server, never taken from the message itself.
+type envelope struct {
+	Exclude string          `json:"exclude,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
 type RedisHub struct {
 	clients          map[*Client]bool
 	clientsMutex     sync.RWMutex
@@ -59,29 +68,73 @@ func (h *RedisHub) SubscribeTopic(topic string) {
 		pubsub := h.redisClient.Subscribe(h.ctx, topic)
 		ch := pubsub.Channel()
 		for msg := range ch {
+			var env envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+				log.Printf("[RedisHub] Dropping malformed message on %s: %v", topic, err)
+				continue
+			}
+			payload := []byte(env.Payload)
+
 			// Forward cho tất cả client local đang theo dõi topic này
+			var lagging []*Client
 			h.clientsMutex.RLock()
 			for client := range h.clients {
 				if client.Topics[topic] {
-					// Kiểm tra sender_id trong message với client.ID
-					var payload map[string]interface{}
-					if err := json.Unmarshal([]byte(msg.Payload), &payload); err == nil {
-						senderID := payload["sender_id"]
-						senderIDStr := fmt.Sprintf("%v", senderID)
-						clientIDStr := fmt.Sprintf("%v", client.ID)
-						if senderIDStr == clientIDStr {
-							continue // Bỏ qua client gửi
-						}
+					if env.Exclude != "" && client.ID == env.Exclude {
+						continue // Bỏ qua client gửi
+					}
+					if client.Accept != nil && !client.Accept(topic, payload) {
+						continue
+					}
+					// A client whose buffer is full would stall every topic; it is disconnected
+					// and catches up on reconnect instead
+					if !client.Send(payload) && client.lagging.CompareAndSwap(false, true) {
+						lagging = append(lagging, client)
 					}
-					client.send <- []byte(msg.Payload)
 				}
 			}
 			h.clientsMutex.RUnlock()
+
+			for _, client := range lagging {
+				log.Printf("[RedisHub] Closing lagging client %s on %s", client.ID, topic)
+				go client.Close(websocket.CloseTryAgainLater, "too many pending frames")
+			}
 		}
 	}()
 }
 
+// SubscribeClient starts forwarding messages of a topic to a local client.
+func (h *RedisHub) SubscribeClient(client *Client, topic string) {
+	h.SubscribeTopic(topic)
+	h.clientsMutex.Lock()
+	client.Topics[topic] = true
+	h.clientsMutex.Unlock()
+}
+
+// IsSubscribed reports whether messages of a topic are forwarded to a local client.
+func (h *RedisHub) IsSubscribed(client *Client, topic string) bool {
+	h.clientsMutex.RLock()
+	defer h.clientsMutex.RUnlock()
+	return client.Topics[topic]
+}
+
+// UnsubscribeClient stops forwarding messages of a topic to a local client.
+func (h *RedisHub) UnsubscribeClient(client *Client, topic string) {
+	h.clientsMutex.Lock()
+	delete(client.Topics, topic)
+	h.clientsMutex.Unlock()
+}
+
 // Publish message lên Redis
 func (h *RedisHub) Publish(topic string, message []byte) error {
-	return h.redisClient.Publish(h.ctx, topic, message).Err()
+	return h.PublishExcept(topic, message, "")
+}
+
+// PublishExcept publishes a JSON message to every subscriber of a topic except the clients with ID exclude.
+func (h *RedisHub) PublishExcept(topic string, message []byte, exclude string) error {
+	payload, err := json.Marshal(envelope{Exclude: exclude, Payload: message})
+	if err != nil {
+		return err
+	}
+	return h.redisClient.Publish(h.ctx, topic, payload).Err()
 }